@@ -5,10 +5,12 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -43,11 +45,13 @@ func (e DuplicateError) Error() string {
 	return fmt.Sprintf("Multiple campaigns found in folder '%s': %s", e.Value, strings.Join(e.Campaigns, ", "))
 }
 
-// DownloadHandler creates a zip archive of a directory and sends it to the client
-func DownloadHandler(baseDir string) http.HandlerFunc {
+// DownloadHandler creates a zip archive of a directory and sends it to the client.
+// If idx is non-nil, duplicate-campaign validation is served from its cached
+// snapshot instead of re-walking store; pass nil to always walk store directly.
+func DownloadHandler(store Store, idx *Index) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// First validate campaigns for duplicates
-		err := validateCampaigns(baseDir)
+		err := checkDuplicateCampaigns(store, idx)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"Campaign validation failed: %s"}`, err), http.StatusConflict)
 			return
@@ -60,35 +64,21 @@ func DownloadHandler(baseDir string) http.HandlerFunc {
 			return
 		}
 
-		// Remove any starting slash for consistency and validate path
-		reqPath = strings.TrimPrefix(reqPath, "/")
-
-		// Clean the path to prevent directory traversal
-		cleanPath := filepath.Clean(reqPath)
-		if strings.Contains(cleanPath, "..") {
-			http.Error(w, `{"error":"Invalid path"}`, http.StatusBadRequest)
-			return
-		}
-
-		// Build the filesystem path
-		fsPath := filepath.Join(baseDir, cleanPath)
-
-		// Ensure the path is within baseDir
-		absBaseDir, _ := filepath.Abs(baseDir)
-		absPath, _ := filepath.Abs(fsPath)
-		if !strings.HasPrefix(absPath, absBaseDir) {
-			http.Error(w, `{"error":"Invalid path"}`, http.StatusBadRequest)
-			return
+		// Remove any starting slash and clean it into an fs.FS-relative path;
+		// fs.FS implementations reject ".." and absolute paths on their own.
+		fsPath := path.Clean(strings.TrimPrefix(reqPath, "/"))
+		if fsPath == "." || fsPath == "" {
+			fsPath = "."
 		}
 
 		// Check if path exists and is a directory
-		info, err := os.Stat(fsPath)
-		if os.IsNotExist(err) {
+		info, err := fs.Stat(store, fsPath)
+		if errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, `{"error":"Path not found"}`, http.StatusNotFound)
 			return
 		}
 		if err != nil {
-			http.Error(w, `{"error":"Error accessing path"}`, http.StatusInternalServerError)
+			http.Error(w, `{"error":"Invalid path"}`, http.StatusBadRequest)
 			return
 		}
 		if !info.IsDir() {
@@ -96,147 +86,183 @@ func DownloadHandler(baseDir string) http.HandlerFunc {
 			return
 		}
 
-		// Create a timestamp for the zip filename
+		// Parse include=/exclude= filters and .templateignore before we walk
+		// anything, so excluded entries are never even read off disk.
+		filter, err := parseExportFilter(r, store)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Invalid filter: %s"}`, err), http.StatusBadRequest)
+			return
+		}
+
+		// Negotiate the archive format (zip/tar/tar.gz/tar.bz2) from the
+		// `format=` query parameter, filename extension, or Accept header.
+		format := NegotiateFormat(r)
+
+		// Create a timestamp for the archive filename
 		timestamp := time.Now().Format("20060102-150405")
 
-		// Get the directory name for the zip file name
+		// Get the directory name for the archive file name
 		dirName := filepath.Base(reqPath)
 		if dirName == "." || dirName == "" {
 			dirName = "templates"
 		}
 
-		// Set filename with directory name and timestamp
-		zipFilename := fmt.Sprintf("%s-%s.zip", dirName, timestamp)
+		// Set filename with directory name, timestamp, and format extension;
+		// reflect that a filter was applied so a filtered download can't be
+		// mistaken for a full one.
+		namePart := dirName
+		if filter.active {
+			namePart = dirName + "-filtered"
+		}
+		archiveFilename := fmt.Sprintf("%s-%s%s", namePart, timestamp, format.Extension())
 
 		// Set headers for file download
-		w.Header().Set("Content-Type", "application/zip")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", zipFilename))
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", archiveFilename))
 
-		// Create a new zip archive writing directly to the response
-		zipWriter := zip.NewWriter(w)
-		defer zipWriter.Close()
-
-		// Walk the directory and add files to the zip
-		err = filepath.Walk(fsPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+		// Create a new archive writer streaming directly to the response
+		archiveWriter, err := NewArchiveWriter(format, w)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Error creating archive: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+		defer archiveWriter.Close()
 
-			// Create a zip header based on the file info
-			header, err := zip.FileInfoHeader(info)
+		// Walk the directory and add files to the archive
+		err = fs.WalkDir(store, fsPath, func(p string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
 
-			// Set the name based on the relative path from the base directory
-			relPath, err := filepath.Rel(fsPath, path)
-			if err != nil {
-				return err
-			}
+			// Set the name based on the relative path from the requested directory
+			relPath := strings.TrimPrefix(strings.TrimPrefix(p, fsPath), "/")
 
-			// Skip the current directory
-			if relPath == "." {
+			// Skip the requested directory itself
+			if relPath == "" {
 				return nil
 			}
 
-			// Ensure forward slashes for compatibility
-			header.Name = filepath.ToSlash(relPath)
-
-			// Set appropriate method for directories or files
-			if info.IsDir() {
-				header.Name += "/"
-				header.Method = zip.Store
-			} else {
-				header.Method = zip.Deflate
-			}
-
-			// Create writer for the file
-			writer, err := zipWriter.CreateHeader(header)
-			if err != nil {
-				return err
-			}
-
-			// If it's a directory, we're done
-			if info.IsDir() {
+			// Check the filter before reading anything off disk: a directory
+			// excluded by .templateignore or exclude= is skipped entirely,
+			// and an excluded or non-matching file is never opened.
+			if d.IsDir() {
+				if filter.excluded(relPath, true) {
+					return fs.SkipDir
+				}
+			} else if !filter.Allowed(relPath, false) {
 				return nil
 			}
 
-			// Open the file for reading
-			file, err := os.Open(path)
+			info, err := d.Info()
 			if err != nil {
 				return err
 			}
-			defer file.Close()
 
-			// Copy file contents to the zip writer
-			_, err = io.Copy(writer, file)
-			return err
+			return archiveWriter.WriteEntry(relPath, info, store, p)
 		})
 
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Error creating zip file: %s"}`, err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf(`{"error":"Error creating archive: %s"}`, err), http.StatusInternalServerError)
 			return
 		}
 	}
 }
 
-// ExportHandler creates a structured zip export with assets and templates
-func ExportHandler(baseDir string) http.HandlerFunc {
+// ExportHandler creates a structured export with assets and templates, as a
+// zip, tar, tar.gz, or tar.bz2 archive depending on NegotiateFormat. The
+// export is deterministic: entries are sorted and mtimes are fixed, so the
+// same inputs always hash to the same manifest. Exports are cached on disk
+// keyed by that manifest hash, and served through http.ServeContent so
+// repeat requests get If-None-Match/304 and Range support for free. If idx
+// is non-nil, duplicate-campaign validation is served from its cached
+// snapshot.
+func ExportHandler(store Store, idx *Index) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// First validate campaigns for duplicates
-		err := validateCampaigns(baseDir)
+		err := checkDuplicateCampaigns(store, idx)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"Campaign validation failed: %s"}`, err), http.StatusConflict)
 			return
 		}
 
-		// Create a timestamp for the zip filename
-		timestamp := time.Now().Format("20060102-150405")
-		zipFilename := fmt.Sprintf("export-%s.zip", timestamp)
+		// Parse include=/exclude= filters and .templateignore before we walk
+		// anything, so excluded entries are never even read off disk.
+		filter, err := parseExportFilter(r, store)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Invalid filter: %s"}`, err), http.StatusBadRequest)
+			return
+		}
 
-		// Set headers for file download
-		w.Header().Set("Content-Type", "application/zip")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", zipFilename))
+		format := NegotiateFormat(r)
 
-		// Create a new zip archive writing directly to the response
-		zipWriter := zip.NewWriter(w)
-		defer zipWriter.Close()
+		manifest, err := buildExportManifest(store, filter)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Error building manifest: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
 
-		// Process assets (check both "assets" and "Assets" directories)
-		assetsPath := filepath.Join(baseDir, "assets")
-		if _, err := os.Stat(assetsPath); err == nil {
-			err = addAssets(zipWriter, assetsPath)
-			if err != nil {
-				http.Error(w, fmt.Sprintf(`{"error":"Error processing assets: %s"}`, err), http.StatusInternalServerError)
-				return
-			}
+		// Name the archive after the manifest hash (not a timestamp) so
+		// identical exports also produce an identical filename, and reflect
+		// whether a filter was applied so a filtered export can't be
+		// mistaken for a complete one.
+		namePart := "export"
+		if filter.active {
+			namePart = "export-filtered"
 		}
+		archiveFilename := fmt.Sprintf("%s-%s%s", namePart, manifest.SHA256[:12], format.Extension())
 
-		// Also check for "Assets" directory (legacy support)
-		assetsPathCap := filepath.Join(baseDir, "Assets")
-		if _, err := os.Stat(assetsPathCap); err == nil {
-			err = addAssets(zipWriter, assetsPathCap)
-			if err != nil {
-				http.Error(w, fmt.Sprintf(`{"error":"Error processing Assets: %s"}`, err), http.StatusInternalServerError)
-				return
-			}
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", archiveFilename))
+		w.Header().Set("X-Content-SHA256", manifest.SHA256)
+		w.Header().Set("ETag", `"`+manifest.SHA256+`"`)
+
+		cachePath := exportCachePath(manifest.SHA256, format)
+		if err := serveExportCache(w, r, cachePath, archiveFilename); err == nil {
+			return
+		}
+
+		if err := os.MkdirAll(exportCacheDir, 0755); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Error preparing export cache: %s"}`, err), http.StatusInternalServerError)
+			return
 		}
 
-		// Process phishing templates
-		err = addPhishingTemplates(zipWriter, baseDir)
+		tmpFile, err := os.CreateTemp(exportCacheDir, "export-*.tmp")
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"Error processing templates: %s"}`, err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf(`{"error":"Error creating export cache file: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+		tmpPath := tmpFile.Name()
+
+		if err := writeExportArchive(tmpFile, format, store, manifest); err != nil {
+			tmpFile.Close()
+			os.Remove(tmpPath)
+			http.Error(w, fmt.Sprintf(`{"error":"Error creating archive: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+		if err := tmpFile.Close(); err != nil {
+			os.Remove(tmpPath)
+			http.Error(w, fmt.Sprintf(`{"error":"Error finalizing export cache: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+		if err := os.Rename(tmpPath, cachePath); err != nil {
+			os.Remove(tmpPath)
+			http.Error(w, fmt.Sprintf(`{"error":"Error finalizing export cache: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := serveExportCache(w, r, cachePath, archiveFilename); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Error reading export cache: %s"}`, err), http.StatusInternalServerError)
 			return
 		}
 	}
 }
 
 // ValidateCampaignsHandler provides an endpoint to validate campaigns for duplicates
-func ValidateCampaignsHandler(baseDir string) http.HandlerFunc {
+func ValidateCampaignsHandler(store Store, idx *Index) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		err := validateCampaigns(baseDir)
+		err := checkDuplicateCampaigns(store, idx)
 		if err != nil {
 			// Return conflict status with detailed error information
 			response := map[string]interface{}{
@@ -264,127 +290,147 @@ func ValidateCampaignsHandler(baseDir string) http.HandlerFunc {
 	}
 }
 
-// addAssets adds all folders from assets/ in the zip
-func addAssets(zipWriter *zip.Writer, assetsPath string) error {
-	return filepath.Walk(assetsPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Get relative path from assets directory
-		relPath, err := filepath.Rel(assetsPath, path)
+// addAssets adds all folders from an assets directory in the store to the
+// archive. filter may be nil, in which case every entry is included.
+func addAssets(aw ArchiveWriter, store Store, assetsPath string, filter *ExportFilter) error {
+	return fs.WalkDir(store, assetsPath, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip the root assets directory
-		if relPath == "." {
+		relPath := strings.TrimPrefix(strings.TrimPrefix(p, assetsPath), "/")
+		if relPath == "" {
 			return nil
 		}
 
 		// Create the path in assets folder
-		zipPath := filepath.Join("assets", relPath)
-		zipPath = filepath.ToSlash(zipPath) // Ensure forward slashes
-
-		// Create zip header
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
+		archivePath := path.Join("assets", relPath)
 
-		header.Name = zipPath
-		if info.IsDir() {
-			header.Name += "/"
-			header.Method = zip.Store
-		} else {
-			header.Method = zip.Deflate
+		if d.IsDir() {
+			if filter.excluded(archivePath, true) {
+				return fs.SkipDir
+			}
+		} else if !filter.Allowed(archivePath, false) {
+			return nil
 		}
 
-		// Create writer for the file
-		writer, err := zipWriter.CreateHeader(header)
+		info, err := d.Info()
 		if err != nil {
 			return err
 		}
 
-		// If it's a directory, we're done
-		if info.IsDir() {
-			return nil
-		}
+		return aw.WriteEntry(archivePath, info, store, p)
+	})
+}
 
-		// Open and copy file contents
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+// writeZipEntry writes a single store entry (file or directory) to zipWriter
+// under zipPath, reading its content from storePath when it is a file.
+func writeZipEntry(zipWriter *zip.Writer, store Store, storePath, zipPath string, info fs.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+
+	// Pin the mtime instead of the source file's own, so archives of the
+	// same inputs are byte-identical regardless of when they're built.
+	header.Modified = deterministicModTime()
+
+	header.Name = zipPath
+	if info.IsDir() {
+		header.Name += "/"
+		header.Method = zip.Store
+	} else {
+		header.Method = zip.Deflate
+	}
 
-		_, err = io.Copy(writer, file)
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
 		return err
-	})
+	}
+
+	if info.IsDir() {
+		return nil
+	}
+
+	content, err := fs.ReadFile(store, storePath)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(content)
+	return err
+}
+
+// checkDuplicateCampaigns validates campaigns using idx's cached snapshot
+// when available, falling back to a live walk of store otherwise.
+func checkDuplicateCampaigns(store Store, idx *Index) error {
+	if idx != nil {
+		return idx.CheckDuplicates()
+	}
+	return validateCampaigns(store)
 }
 
 // validateCampaigns checks for duplicate campaign names and folder conflicts
-func validateCampaigns(baseDir string) error {
+func validateCampaigns(store Store) error {
 	campaigns := make([]CampaignInfo, 0)
 	nameMap := make(map[string][]string)
 	folderMap := make(map[string][]string)
 
+	ignores, err := newIgnoreStack(store)
+	if err != nil {
+		return err
+	}
+
 	// Collect all campaigns
-	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+	err = fs.WalkDir(store, ".", func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip if not a directory
-		if !info.IsDir() {
+		if !d.IsDir() {
 			return nil
 		}
 
 		// Skip the assets directories
-		if strings.Contains(path, "Assets") || strings.Contains(path, "assets") {
-			return filepath.SkipDir
+		if strings.Contains(p, "Assets") || strings.Contains(p, "assets") {
+			return fs.SkipDir
 		}
 
-		// Skip private directories (client-specific content that should not be validated)
-		relPath, err := filepath.Rel(baseDir, path)
-		if err == nil {
-			pathComponents := strings.Split(filepath.ToSlash(relPath), "/")
-			if len(pathComponents) > 0 && strings.ToLower(pathComponents[0]) == "private" {
-				return filepath.SkipDir
-			}
+		// Skip directories excluded by .templateignore (the default
+		// patterns cover "private/"; a repo or per-directory
+		// .templateignore can add more).
+		if err := ignores.enter(p); err != nil {
+			return err
+		}
+		if ignores.excluded(p, true) {
+			return fs.SkipDir
 		}
 
 		// Check if this directory contains any HTML files
-		hasHTML, err := containsHTMLFiles(path)
+		hasHTML, err := storeContainsHTML(store, p)
 		if err != nil {
 			return err
 		}
 
 		// If this directory contains HTML files, it's a campaign directory
 		if hasHTML {
-			relPath, err := filepath.Rel(baseDir, path)
-			if err != nil {
-				return err
-			}
-
 			campaign := CampaignInfo{
-				Path: relPath,
-				Dir:  filepath.Base(path),
+				Path: p,
+				Dir:  path.Base(p),
 			}
 
 			// Try to read campaign name from data.yaml (top-level name field)
-			dataYamlPath := filepath.Join(path, "data.yaml")
-			if _, err := os.Stat(dataYamlPath); err == nil {
-				data, err := os.ReadFile(dataYamlPath)
-				if err == nil {
-					var yamlData struct {
-						Name string `yaml:"name"`
-						// Note: emails and landing_pages sections are ignored for campaign-level validation
-						// as they can have the same names within a single campaign
-					}
-					if yaml.Unmarshal(data, &yamlData) == nil && yamlData.Name != "" {
-						campaign.Name = yamlData.Name
-					}
+			dataYamlPath := path.Join(p, "data.yaml")
+			if data, err := fs.ReadFile(store, dataYamlPath); err == nil {
+				var yamlData struct {
+					Name string `yaml:"name"`
+					// Note: emails and landing_pages sections are ignored for campaign-level validation
+					// as they can have the same names within a single campaign
+				}
+				if yaml.Unmarshal(data, &yamlData) == nil && yamlData.Name != "" {
+					campaign.Name = yamlData.Name
 				}
 			}
 
@@ -453,177 +499,131 @@ func validateCampaigns(baseDir string) error {
 	return nil
 }
 
-// addPhishingTemplates recursively finds template folders (containing *.html files) and adds them to templates/
-func addPhishingTemplates(zipWriter *zip.Writer, baseDir string) error {
+// addPhishingTemplates recursively finds template folders (containing *.html
+// files) and adds them to templates/. filter may be nil, in which case every
+// entry is included.
+func addPhishingTemplates(aw ArchiveWriter, store Store, filter *ExportFilter) error {
 	usedNames := make(map[string]bool)
 
-	return filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+	ignores, err := newIgnoreStack(store)
+	if err != nil {
+		return err
+	}
+
+	return fs.WalkDir(store, ".", func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip if not a directory
-		if !info.IsDir() {
+		if !d.IsDir() {
 			return nil
 		}
 
 		// Skip the assets directories as they're handled separately
-		if strings.Contains(path, "Assets") || strings.Contains(path, "assets") {
-			return filepath.SkipDir
+		if strings.Contains(p, "Assets") || strings.Contains(p, "assets") {
+			return fs.SkipDir
 		}
 
-		// Skip private directories (client-specific content that should not be exported)
-		relPath, err := filepath.Rel(baseDir, path)
-		if err == nil {
-			pathComponents := strings.Split(filepath.ToSlash(relPath), "/")
-			if len(pathComponents) > 0 && strings.ToLower(pathComponents[0]) == "private" {
-				return filepath.SkipDir
-			}
+		// Skip directories excluded by .templateignore (the default
+		// patterns cover "private/"; a repo or per-directory
+		// .templateignore can add more).
+		if err := ignores.enter(p); err != nil {
+			return err
+		}
+		if ignores.excluded(p, true) {
+			return fs.SkipDir
 		}
 
 		// Check if this directory contains any HTML files
-		hasHTML, err := containsHTMLFiles(path)
+		hasHTML, err := storeContainsHTML(store, p)
 		if err != nil {
 			return err
 		}
 
 		// If this directory contains HTML files, it's a template directory
 		if hasHTML {
-			templateName := filepath.Base(path)
+			templateName := path.Base(p)
 
-			// Handle name conflicts by adding a hash suffix
+			// Handle name conflicts by adding a hash suffix. The hash is
+			// derived only from the store path (not the wall clock), so the
+			// same input tree always resolves conflicts the same way.
 			if usedNames[templateName] {
-				// Create a unique hash based on the full path and current time
-				hashInput := fmt.Sprintf("%s-%d", path, time.Now().UnixNano())
 				hasher := sha256.New()
-				hasher.Write([]byte(hashInput))
+				hasher.Write([]byte(p))
 				hash := hex.EncodeToString(hasher.Sum(nil))[:8] // Use first 8 chars
 				templateName = fmt.Sprintf("%s-%s", templateName, hash)
 			}
 			usedNames[templateName] = true
 
-			return addTemplateToZip(zipWriter, path, templateName)
+			return addTemplateToZip(aw, store, p, templateName, filter)
 		}
 
 		return nil
 	})
 }
 
-// containsHTMLFiles checks if a directory contains any *.html files
-func containsHTMLFiles(dirPath string) (bool, error) {
-	entries, err := os.ReadDir(dirPath)
-	if err != nil {
-		return false, err
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".html") {
-			return true, nil
-		}
-	}
-	return false, nil
-}
-
-// addTemplateToZip adds an entire template directory to the templates/ folder in the zip
-func addTemplateToZip(zipWriter *zip.Writer, templatePath, templateName string) error {
-	return filepath.Walk(templatePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Get relative path from template directory
-		relPath, err := filepath.Rel(templatePath, path)
+// addTemplateToZip adds an entire template directory to the templates/
+// folder in the archive. filter may be nil, in which case every entry is
+// included.
+func addTemplateToZip(aw ArchiveWriter, store Store, templatePath, templateName string, filter *ExportFilter) error {
+	return fs.WalkDir(store, templatePath, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip the root template directory
-		if relPath == "." {
+		relPath := strings.TrimPrefix(strings.TrimPrefix(p, templatePath), "/")
+		if relPath == "" {
 			return nil
 		}
 
 		// Create the path in templates folder
-		zipPath := filepath.Join("templates", templateName, relPath)
-		zipPath = filepath.ToSlash(zipPath) // Ensure forward slashes
-
-		// Create zip header
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
+		archivePath := path.Join("templates", templateName, relPath)
 
-		header.Name = zipPath
-		if info.IsDir() {
-			header.Name += "/"
-			header.Method = zip.Store
-		} else {
-			header.Method = zip.Deflate
-		}
-
-		// Create writer for the file
-		writer, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-
-		// If it's a directory, we're done
-		if info.IsDir() {
+		if d.IsDir() {
+			if filter.excluded(archivePath, true) {
+				return fs.SkipDir
+			}
+		} else if !filter.Allowed(archivePath, false) {
 			return nil
 		}
 
-		// Open and copy file contents
-		file, err := os.Open(path)
+		info, err := d.Info()
 		if err != nil {
 			return err
 		}
-		defer file.Close()
 
-		_, err = io.Copy(writer, file)
-		return err
+		return aw.WriteEntry(archivePath, info, store, p)
 	})
 }
 
 // StructureHandler handles API requests for directory structure
-// TODO this looks dangerous! consider using go 1.24's os.Root
-func StructureHandler(baseDir string) http.HandlerFunc {
+func StructureHandler(store Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Set content type
 		w.Header().Set("Content-Type", "application/json")
 
-		// Get requested path from query parameter
-		reqPath := r.URL.Query().Get("path")
-
-		// Clean the path to prevent directory traversal
-		cleanPath := filepath.Clean(reqPath)
-		if strings.Contains(cleanPath, "..") {
-			http.Error(w, `{"error":"Invalid path"}`, http.StatusBadRequest)
-			return
-		}
-
-		// Build the filesystem path
-		fsPath := filepath.Join(baseDir, cleanPath)
-
-		// Ensure the path is within baseDir
-		absBaseDir, _ := filepath.Abs(baseDir)
-		absPath, _ := filepath.Abs(fsPath)
-		if !strings.HasPrefix(absPath, absBaseDir) {
-			http.Error(w, `{"error":"Invalid path"}`, http.StatusBadRequest)
-			return
+		// Get requested path from query parameter, cleaned into an fs.FS-relative
+		// path; fs.FS implementations reject ".." and absolute paths on their own.
+		fsPath := path.Clean(strings.TrimPrefix(r.URL.Query().Get("path"), "/"))
+		if fsPath == "" {
+			fsPath = "."
 		}
 
 		// Check if path exists
-		_, err := os.Stat(fsPath)
-		if os.IsNotExist(err) {
+		_, err := fs.Stat(store, fsPath)
+		if errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, `{"error":"Path not found"}`, http.StatusNotFound)
 			return
 		}
 		if err != nil {
-			http.Error(w, `{"error":"Error accessing path"}`, http.StatusInternalServerError)
+			http.Error(w, `{"error":"Invalid path"}`, http.StatusBadRequest)
 			return
 		}
 
 		// Read directory contents
-		entries, err := os.ReadDir(fsPath)
+		entries, err := fs.ReadDir(store, fsPath)
 		if err != nil {
 			http.Error(w, `{"error":"Failed to read directory"}`, http.StatusInternalServerError)
 			return
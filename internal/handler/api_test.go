@@ -232,7 +232,7 @@ landing_pages:
 			}
 
 			// Run validation
-			err := validateCampaigns(tmpDir)
+			err := validateCampaigns(os.DirFS(tmpDir))
 
 			if tt.expectError {
 				if err == nil {
@@ -337,7 +337,7 @@ func TestValidateCampaignsSkipsPrivate(t *testing.T) {
 	}
 
 	// Validation should pass because private folder is skipped
-	err = validateCampaigns(tmpDir)
+	err = validateCampaigns(os.DirFS(tmpDir))
 	if err != nil {
 		t.Errorf("Expected no error but got: %v (private folder should be skipped)", err)
 	}
@@ -391,7 +391,7 @@ func TestValidateCampaignsSkipsPrivateCaseInsensitive(t *testing.T) {
 			}
 
 			// Validation should pass because private folder is skipped
-			err = validateCampaigns(tmpDir)
+			err = validateCampaigns(os.DirFS(tmpDir))
 			if err != nil {
 				t.Errorf("Expected no error but got: %v (%s folder should be skipped)", err, privateFolder)
 			}
@@ -399,6 +399,148 @@ func TestValidateCampaignsSkipsPrivateCaseInsensitive(t *testing.T) {
 	}
 }
 
+func TestValidateCampaignsHonorsCustomTemplateIgnore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "campaign-templateignore-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, ".templateignore"), []byte("drafts/*\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .templateignore: %v", err)
+	}
+
+	dataYaml := `name: "Client Specific Campaign"`
+	draftDir := filepath.Join(tmpDir, "drafts", "client-company")
+	if err := os.MkdirAll(draftDir, 0755); err != nil {
+		t.Fatalf("Failed to create drafts dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(draftDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write draft data.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(draftDir, "email.html"), []byte("<html>Draft</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write draft email.html: %v", err)
+	}
+
+	publicDir := filepath.Join(tmpDir, "generic-service")
+	if err := os.MkdirAll(publicDir, 0755); err != nil {
+		t.Fatalf("Failed to create public dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write public data.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "email.html"), []byte("<html>Public</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write public email.html: %v", err)
+	}
+
+	if err := validateCampaigns(os.DirFS(tmpDir)); err != nil {
+		t.Errorf("Expected no error but got: %v (drafts/* should be skipped by .templateignore)", err)
+	}
+}
+
+func TestValidateCampaignsTemplateIgnoreNegationOverridesDefault(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "campaign-templateignore-negate-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// "!private/" un-ignores the built-in default, so a duplicate hiding in
+	// private/ should now be caught instead of silently skipped.
+	if err := os.WriteFile(filepath.Join(tmpDir, ".templateignore"), []byte("!private/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .templateignore: %v", err)
+	}
+
+	dataYaml := `name: "Client Specific Campaign"`
+	privateDir := filepath.Join(tmpDir, "private", "client-company")
+	if err := os.MkdirAll(privateDir, 0755); err != nil {
+		t.Fatalf("Failed to create private dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(privateDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write private data.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(privateDir, "email.html"), []byte("<html>Private</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write private email.html: %v", err)
+	}
+
+	publicDir := filepath.Join(tmpDir, "generic-service")
+	if err := os.MkdirAll(publicDir, 0755); err != nil {
+		t.Fatalf("Failed to create public dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write public data.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "email.html"), []byte("<html>Public</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write public email.html: %v", err)
+	}
+
+	if err := validateCampaigns(os.DirFS(tmpDir)); err == nil {
+		t.Error("Expected a duplicate-name error, since !private/ should un-ignore the private folder")
+	}
+}
+
+func TestValidateCampaignsPerSubtreeTemplateIgnoreOnlyAppliesBelow(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "campaign-templateignore-subtree-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dataYaml := `name: "Client Specific Campaign"`
+
+	// client-a/.templateignore ignores "legacy/", but only below client-a.
+	clientADir := filepath.Join(tmpDir, "client-a")
+	if err := os.MkdirAll(clientADir, 0755); err != nil {
+		t.Fatalf("Failed to create client-a dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(clientADir, ".templateignore"), []byte("legacy/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write client-a/.templateignore: %v", err)
+	}
+
+	ignoredDir := filepath.Join(clientADir, "legacy", "campaign-one")
+	if err := os.MkdirAll(ignoredDir, 0755); err != nil {
+		t.Fatalf("Failed to create client-a/legacy dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(ignoredDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write ignored data.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(ignoredDir, "email.html"), []byte("<html>Ignored</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write ignored email.html: %v", err)
+	}
+
+	// A "legacy" folder outside client-a is unaffected by that subtree's
+	// ignore file, so its duplicate name should still be caught.
+	visibleDir := filepath.Join(tmpDir, "legacy", "campaign-two")
+	if err := os.MkdirAll(visibleDir, 0755); err != nil {
+		t.Fatalf("Failed to create top-level legacy dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(visibleDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write visible data.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(visibleDir, "email.html"), []byte("<html>Visible</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write visible email.html: %v", err)
+	}
+
+	publicDir := filepath.Join(tmpDir, "generic-service")
+	if err := os.MkdirAll(publicDir, 0755); err != nil {
+		t.Fatalf("Failed to create public dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write public data.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "email.html"), []byte("<html>Public</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write public email.html: %v", err)
+	}
+
+	err = validateCampaigns(os.DirFS(tmpDir))
+	if err == nil {
+		t.Fatal("Expected a duplicate-name error from the top-level legacy/ campaign, which client-a's .templateignore should not reach")
+	}
+	if _, ok := err.(DuplicateError); !ok {
+		t.Errorf("Expected a DuplicateError, got %T: %v", err, err)
+	}
+}
+
 func TestValidateCampaignsAllowsSameFolderInDifferentOrgDirs(t *testing.T) {
 	// Create temporary directory for test
 	tmpDir, err := os.MkdirTemp("", "campaign-org-dirs-test")
@@ -430,7 +572,7 @@ func TestValidateCampaignsAllowsSameFolderInDifferentOrgDirs(t *testing.T) {
 	}
 
 	// This should pass - same folder names in different organizational directories should be allowed
-	err = validateCampaigns(tmpDir)
+	err = validateCampaigns(os.DirFS(tmpDir))
 	if err != nil {
 		t.Errorf("Expected no error but got: %v (same folder names in different org dirs should be allowed)", err)
 	}
@@ -464,7 +606,7 @@ func TestAutoNumberingResolvesConflicts(t *testing.T) {
 	}
 
 	// Validation should pass now since folder conflicts are auto-resolved during export
-	err = validateCampaigns(tmpDir)
+	err = validateCampaigns(os.DirFS(tmpDir))
 	if err != nil {
 		t.Errorf("Expected no error but got: %v (folder conflicts should be auto-resolved)", err)
 	}
@@ -499,17 +641,20 @@ func TestExportCreatesHashedFolders(t *testing.T) {
 
 	// Create a buffer to capture zip output
 	var buf bytes.Buffer
-	zipWriter := zip.NewWriter(&buf)
+	archiveWriter, err := NewArchiveWriter(FormatZip, &buf)
+	if err != nil {
+		t.Fatalf("Failed to create archive writer: %v", err)
+	}
 
 	// Test the addPhishingTemplates function
-	err = addPhishingTemplates(zipWriter, tmpDir)
+	err = addPhishingTemplates(archiveWriter, os.DirFS(tmpDir), nil)
 	if err != nil {
 		t.Fatalf("addPhishingTemplates failed: %v", err)
 	}
 
-	err = zipWriter.Close()
+	err = archiveWriter.Close()
 	if err != nil {
-		t.Fatalf("Failed to close zip writer: %v", err)
+		t.Fatalf("Failed to close archive writer: %v", err)
 	}
 
 	// Read the zip and verify folder names
@@ -592,7 +737,7 @@ func TestExportSkipsPrivateFolders(t *testing.T) {
 	}
 
 	// Test that private folders are skipped during export validation
-	err = validateCampaigns(tmpDir)
+	err = validateCampaigns(os.DirFS(tmpDir))
 	if err != nil {
 		t.Errorf("Expected no error but got: %v (private folder should be skipped)", err)
 	}
@@ -633,7 +778,7 @@ func TestValidateCampaignsWithAssets(t *testing.T) {
 	}
 
 	// Validation should pass (assets directory should be ignored)
-	err = validateCampaigns(tmpDir)
+	err = validateCampaigns(os.DirFS(tmpDir))
 	if err != nil {
 		t.Errorf("Expected no error but got: %v", err)
 	}
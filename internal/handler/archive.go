@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/dsnet/compress/bzip2"
+)
+
+// ArchiveFormat identifies the container format used for an export/download.
+type ArchiveFormat string
+
+// Supported archive formats. FormatZip is the default for backwards
+// compatibility with existing clients of /api/download and /api/export.
+const (
+	FormatZip    ArchiveFormat = "zip"
+	FormatTar    ArchiveFormat = "tar"
+	FormatTarGz  ArchiveFormat = "tar.gz"
+	FormatTarBz2 ArchiveFormat = "tar.bz2"
+)
+
+// Extension returns the file extension (including the leading dot) used for
+// archives in this format.
+func (f ArchiveFormat) Extension() string {
+	switch f {
+	case FormatTar:
+		return ".tar"
+	case FormatTarGz:
+		return ".tar.gz"
+	case FormatTarBz2:
+		return ".tar.bz2"
+	default:
+		return ".zip"
+	}
+}
+
+// ContentType returns the MIME type to send for this archive format.
+func (f ArchiveFormat) ContentType() string {
+	switch f {
+	case FormatTar:
+		return "application/x-tar"
+	case FormatTarGz:
+		return "application/gzip"
+	case FormatTarBz2:
+		return "application/x-bzip2"
+	default:
+		return "application/zip"
+	}
+}
+
+// NegotiateFormat determines which archive format to produce for a request,
+// preferring an explicit `format=` query parameter, then the extension on
+// the requested filename (`name=` or `path=`), then the Accept header, and
+// finally falling back to zip.
+func NegotiateFormat(r *http.Request) ArchiveFormat {
+	if v := strings.ToLower(r.URL.Query().Get("format")); v != "" {
+		switch v {
+		case "tar":
+			return FormatTar
+		case "tar.gz", "targz", "gz":
+			return FormatTarGz
+		case "tar.bz2", "tarbz2", "bz2":
+			return FormatTarBz2
+		case "zip":
+			return FormatZip
+		}
+	}
+
+	for _, key := range []string{"name", "path"} {
+		name := strings.ToLower(r.URL.Query().Get(key))
+		switch {
+		case strings.HasSuffix(name, ".tar.gz"):
+			return FormatTarGz
+		case strings.HasSuffix(name, ".tar.bz2"):
+			return FormatTarBz2
+		case strings.HasSuffix(name, ".tar"):
+			return FormatTar
+		}
+	}
+
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	switch {
+	case strings.Contains(accept, "application/x-bzip2"):
+		return FormatTarBz2
+	case strings.Contains(accept, "application/gzip"), strings.Contains(accept, "application/x-gzip"):
+		return FormatTarGz
+	case strings.Contains(accept, "application/x-tar"):
+		return FormatTar
+	}
+
+	return FormatZip
+}
+
+// ArchiveWriter is the minimal surface the export walkers need to stream a
+// Store entry (file or directory) into either a zip or a tar-based
+// container, so addAssets/addPhishingTemplates/addTemplateToZip can share
+// one walker regardless of output format.
+type ArchiveWriter interface {
+	// WriteEntry adds a single entry at name. storePath/store are only
+	// consulted for files (info.IsDir() == false) to read their content.
+	WriteEntry(name string, info fs.FileInfo, store Store, storePath string) error
+	Close() error
+}
+
+// NewArchiveWriter returns an ArchiveWriter that writes to w in the given
+// format; format.ContentType() gives the Content-Type to send for it.
+func NewArchiveWriter(format ArchiveFormat, w io.Writer) (ArchiveWriter, error) {
+	switch format {
+	case FormatTar:
+		return &tarArchiveWriter{tw: tar.NewWriter(w)}, nil
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarArchiveWriter{tw: tar.NewWriter(gz), closers: []io.Closer{gz}}, nil
+	case FormatTarBz2:
+		bz, err := bzip2.NewWriter(w, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bzip2 writer: %w", err)
+		}
+		return &tarArchiveWriter{tw: tar.NewWriter(bz), closers: []io.Closer{bz}}, nil
+	default:
+		return &zipArchiveWriter{zw: zip.NewWriter(w)}, nil
+	}
+}
+
+// zipArchiveWriter adapts *zip.Writer to ArchiveWriter.
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchiveWriter) WriteEntry(name string, info fs.FileInfo, store Store, storePath string) error {
+	return writeZipEntry(a.zw, store, storePath, name, info)
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
+
+// tarArchiveWriter adapts *tar.Writer to ArchiveWriter, optionally wrapping a
+// gzip or bzip2 compressor that must be closed after the tar trailer.
+type tarArchiveWriter struct {
+	tw      *tar.Writer
+	closers []io.Closer
+}
+
+func (a *tarArchiveWriter) WriteEntry(name string, info fs.FileInfo, store Store, storePath string) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	// Pin the mtime instead of the source file's own, so archives of the
+	// same inputs are byte-identical regardless of when they're built.
+	header.ModTime = deterministicModTime()
+
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return nil
+	}
+
+	content, err := fs.ReadFile(store, storePath)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.tw.Write(content)
+	return err
+}
+
+func (a *tarArchiveWriter) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	for _, c := range a.closers {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newArchiveHandlerTestDir(t *testing.T) string {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "archive-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	campaignDir := filepath.Join(tmpDir, "campaign-a")
+	if err := os.MkdirAll(campaignDir, 0755); err != nil {
+		t.Fatalf("Failed to create campaign dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(campaignDir, "email.html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatalf("Failed to write email.html: %v", err)
+	}
+
+	return tmpDir
+}
+
+func TestArchiveHandlerRefusesRoot(t *testing.T) {
+	tmpDir := newArchiveHandlerTestDir(t)
+	handler := ArchiveHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/archive/", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != 400 {
+		t.Errorf("expected 400 for root archive request, got %d", rr.Code)
+	}
+}
+
+func TestArchiveHandlerZip(t *testing.T) {
+	tmpDir := newArchiveHandlerTestDir(t)
+	handler := ArchiveHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/archive/campaign-a", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("expected application/zip content type, got %q", ct)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("failed to read zip response: %v", err)
+	}
+
+	var found bool
+	for _, f := range zr.File {
+		if f.Name == "campaign-a/email.html" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected campaign-a/email.html in the archive")
+	}
+}
+
+func TestArchiveHandlerNotFound(t *testing.T) {
+	tmpDir := newArchiveHandlerTestDir(t)
+	handler := ArchiveHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/archive/missing", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != 404 {
+		t.Errorf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestCheckArchiveLimitsAllowsSmallDir(t *testing.T) {
+	tmpDir := newArchiveHandlerTestDir(t)
+	fsys := TemplatesFS(tmpDir)
+
+	if err := checkArchiveLimits(fsys, "campaign-a"); err != nil {
+		t.Errorf("expected small campaign dir to pass limits, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		accept string
+		want   ArchiveFormat
+	}{
+		{name: "default is zip", url: "/api/export", want: FormatZip},
+		{name: "format=tar", url: "/api/export?format=tar", want: FormatTar},
+		{name: "format=tar.gz", url: "/api/export?format=tar.gz", want: FormatTarGz},
+		{name: "format=tar.bz2", url: "/api/export?format=tar.bz2", want: FormatTarBz2},
+		{name: "name extension wins", url: "/api/download?path=foo&name=export.tar.gz", want: FormatTarGz},
+		{name: "accept header", url: "/api/export", accept: "application/gzip", want: FormatTarGz},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			got := NegotiateFormat(req)
+			if got != tt.want {
+				t.Errorf("NegotiateFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchiveWriterRoundTrip(t *testing.T) {
+	for _, format := range []ArchiveFormat{FormatZip, FormatTar, FormatTarGz, FormatTarBz2} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			aw, err := NewArchiveWriter(format, &buf)
+			if err != nil {
+				t.Fatalf("NewArchiveWriter(%s) failed: %v", format, err)
+			}
+
+			if err := aw.Close(); err != nil {
+				t.Fatalf("Close failed for %s: %v", format, err)
+			}
+
+			if buf.Len() == 0 {
+				t.Errorf("expected non-empty output for format %s", format)
+			}
+		})
+	}
+}
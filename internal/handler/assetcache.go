@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// AssetHandlerConfig tunes the caching NewAssetHandlerFSConfig applies on
+// top of a TemplateFS. Any zero-valued field is filled in from
+// DefaultAssetHandlerConfig, so the zero value behaves like
+// DefaultAssetHandlerConfig itself.
+type AssetHandlerConfig struct {
+	// MaxCachedSize is the largest file body assetCache will keep in
+	// memory. A file bigger than this still gets a cached ETag, but is
+	// reopened and streamed from fsys on every request instead of being
+	// served out of memory.
+	MaxCachedSize int64
+
+	// LRUCapacity bounds how many distinct (path, encoding) entries
+	// assetCache keeps at once, evicting the least recently used entry
+	// past that.
+	LRUCapacity int
+}
+
+// DefaultAssetHandlerConfig is what NewAssetHandler and AssetHandler use:
+// cache bodies up to 256KB, across at most 512 entries per handler -
+// enough for a typical campaign's images and stylesheets without letting
+// an unbounded number of large assets sit in memory.
+var DefaultAssetHandlerConfig = AssetHandlerConfig{
+	MaxCachedSize: 256 << 10,
+	LRUCapacity:   512,
+}
+
+// withDefaults fills in any zero-valued field of cfg from
+// DefaultAssetHandlerConfig.
+func (cfg AssetHandlerConfig) withDefaults() AssetHandlerConfig {
+	if cfg.MaxCachedSize <= 0 {
+		cfg.MaxCachedSize = DefaultAssetHandlerConfig.MaxCachedSize
+	}
+	if cfg.LRUCapacity <= 0 {
+		cfg.LRUCapacity = DefaultAssetHandlerConfig.LRUCapacity
+	}
+	return cfg
+}
+
+// precompressedEncodings lists the Content-Encoding variants assetCache
+// will look for next to a plain file, most-preferred first: name+".br",
+// then name+".gz". A variant is only tried when the request's
+// Accept-Encoding says the client can decode it.
+var precompressedEncodings = []struct {
+	encoding string
+	suffix   string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// acceptsEncoding reports whether r's Accept-Encoding header lists
+// encoding. It splits the header on commas and compares each coding name,
+// ignoring any ";q=" parameters, rather than running a full quality-value
+// parser - a client that lists an encoding with q=0 to
+// explicitly refuse it is rare enough, and the cost of guessing wrong is
+// just a same-content response with the "wrong" Content-Encoding, not a
+// correctness bug.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.EqualFold(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]), encoding) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedAsset is one path's cached state: its content digest (for the
+// ETag), its sniffed Content-Type, and - for files at or under the
+// handler's MaxCachedSize - the body itself, so a repeat request (and any
+// Range request against it) never has to reopen fsys.
+type cachedAsset struct {
+	ModTime     time.Time
+	Size        int64
+	ETag        string
+	ContentType string
+	Body        []byte // nil if Size exceeded MaxCachedSize when cached
+}
+
+// assetCache serves files out of fsys, computing each one's ETag and
+// Content-Type once per (path, mtime, size) and keeping small bodies warm
+// in an LRU so repeat and Range requests avoid reopening fsys.
+type assetCache struct {
+	fsys          fs.FS
+	maxCachedSize int64
+	entries       *lru.Cache[string, cachedAsset] // hashicorp/golang-lru is safe for concurrent use
+}
+
+func newAssetCache(fsys fs.FS, cfg AssetHandlerConfig) *assetCache {
+	cfg = cfg.withDefaults()
+	entries, _ := lru.New[string, cachedAsset](cfg.LRUCapacity)
+	return &assetCache{fsys: fsys, maxCachedSize: cfg.MaxCachedSize, entries: entries}
+}
+
+// lookup resolves name plus, when the request's Accept-Encoding allows it,
+// a precompressed sibling (name+".br", then name+".gz"), returning the
+// candidate path actually served and the Content-Encoding to report, or
+// ("", "", false) if neither the plain file nor any acceptable
+// precompressed sibling exists.
+func (c *assetCache) lookup(r *http.Request, name string) (candidate, encoding string, ok bool) {
+	for _, enc := range precompressedEncodings {
+		if !acceptsEncoding(r, enc.encoding) {
+			continue
+		}
+		if _, err := fs.Stat(c.fsys, name+enc.suffix); err == nil {
+			return name + enc.suffix, enc.encoding, true
+		}
+	}
+	if info, err := fs.Stat(c.fsys, name); err == nil && !info.IsDir() {
+		return name, "", true
+	}
+	return "", "", false
+}
+
+// get returns candidate's cached digest/body, (re)computing it if this is
+// the first request for candidate or its mtime/size changed since it was
+// cached. typeHint is the logical asset name (name, not candidate) used to
+// sniff Content-Type, so a precompressed candidate like "style.css.br"
+// still sniffs as text/css instead of by its ".br" extension.
+func (c *assetCache) get(candidate, typeHint string) (cachedAsset, error) {
+	info, err := fs.Stat(c.fsys, candidate)
+	if err != nil {
+		return cachedAsset{}, err
+	}
+
+	if cached, ok := c.entries.Get(candidate); ok {
+		if cached.ModTime.Equal(info.ModTime()) && cached.Size == info.Size() {
+			return cached, nil
+		}
+	}
+
+	f, err := c.fsys.Open(candidate)
+	if err != nil {
+		return cachedAsset{}, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return cachedAsset{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	cached := cachedAsset{
+		ModTime:     info.ModTime(),
+		Size:        info.Size(),
+		ETag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+		ContentType: sniffContentType(typeHint, data),
+	}
+	if cached.Size <= c.maxCachedSize {
+		cached.Body = data
+	}
+	c.entries.Add(candidate, cached)
+	return cached, nil
+}
+
+// sniffContentType determines candidate's Content-Type from its extension
+// first, falling back to sniffing the start of its content - the same
+// order net/http's own FileServer uses - so e.g. a .css file served with
+// no registered mime.types entry still sniffs correctly instead of coming
+// back as application/octet-stream.
+func sniffContentType(candidate string, data []byte) string {
+	if ct := mime.TypeByExtension(path.Ext(candidate)); ct != "" {
+		return ct
+	}
+	sniffLen := 512
+	if len(data) < sniffLen {
+		sniffLen = len(data)
+	}
+	return http.DetectContentType(data[:sniffLen])
+}
+
+// serve resolves name against fsys - including any precompressed variant
+// the request's Accept-Encoding permits - and writes it to w with a
+// content-hash ETag, sniffed Content-Type, and Range support. It reports
+// whether a response was written; on false (no matching file at all),
+// nothing is written and the caller should respond 404 itself.
+func (c *assetCache) serve(w http.ResponseWriter, r *http.Request, name string) bool {
+	candidate, encoding, ok := c.lookup(r, name)
+	if !ok {
+		return false
+	}
+
+	cached, err := c.get(candidate, name)
+	if err != nil {
+		return false
+	}
+
+	etag := cached.ETag
+	if encoding != "" {
+		// A compressed variant has different bytes than the plain file,
+		// so it needs its own ETag - otherwise a cache or proxy that
+		// stored the plain response under this ETag could serve it back
+		// for a client that asked for (and can't decode) gzip/br.
+		etag = etag[:len(etag)-1] + "-" + encoding + `"`
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Header().Set("Etag", etag)
+	w.Header().Set("Content-Type", cached.ContentType)
+
+	if cached.Body != nil {
+		http.ServeContent(w, r, candidate, cached.ModTime, bytes.NewReader(cached.Body))
+		return true
+	}
+
+	f, err := c.fsys.Open(candidate)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return false
+		}
+		rs = bytes.NewReader(data)
+	}
+	http.ServeContent(w, r, candidate, cached.ModTime, rs)
+	return true
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestAssetCacheServesETagAndSupportsIfNoneMatch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"logo.png": {Data: []byte("logo-bytes")},
+	}
+	handler := NewAssetHandlerFS(fsys)
+
+	req := httptest.NewRequest("GET", "/logo.png", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	etag := w.Header().Get("Etag")
+	if etag == "" {
+		t.Fatal("expected an Etag header")
+	}
+
+	req2 := httptest.NewRequest("GET", "/logo.png", nil)
+	req2.Header.Set("If-None-Match", etag)
+	w2 := httptest.NewRecorder()
+	handler(w2, req2)
+
+	if w2.Code != 304 {
+		t.Errorf("expected 304 for a matching If-None-Match, got %d", w2.Code)
+	}
+}
+
+func TestAssetCacheSupportsRangeRequests(t *testing.T) {
+	fsys := fstest.MapFS{
+		"logo.png": {Data: []byte("0123456789")},
+	}
+	handler := NewAssetHandlerFS(fsys)
+
+	req := httptest.NewRequest("GET", "/logo.png", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != 206 {
+		t.Fatalf("expected 206 Partial Content, got %d", w.Code)
+	}
+	if w.Body.String() != "234" {
+		t.Errorf("expected the requested byte range, got %q", w.Body.String())
+	}
+}
+
+func TestAssetCachePrefersBrotliThenGzipWhenAccepted(t *testing.T) {
+	fsys := fstest.MapFS{
+		"style.css":    {Data: []byte("plain")},
+		"style.css.br": {Data: []byte("brotli-body")},
+		"style.css.gz": {Data: []byte("gzip-body")},
+	}
+	handler := NewAssetHandlerFS(fsys)
+
+	req := httptest.NewRequest("GET", "/style.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "br" {
+		t.Errorf("expected Content-Encoding br (preferred over gzip), got %q", got)
+	}
+	if w.Body.String() != "brotli-body" {
+		t.Errorf("expected the brotli variant's body, got %q", w.Body.String())
+	}
+}
+
+func TestAssetCacheFallsBackToPlainFileWithoutAcceptEncoding(t *testing.T) {
+	fsys := fstest.MapFS{
+		"style.css":    {Data: []byte("plain")},
+		"style.css.gz": {Data: []byte("gzip-body")},
+	}
+	handler := NewAssetHandlerFS(fsys)
+
+	req := httptest.NewRequest("GET", "/style.css", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected no Content-Encoding without a matching Accept-Encoding, got %q", got)
+	}
+	if w.Body.String() != "plain" {
+		t.Errorf("expected the plain file's body, got %q", w.Body.String())
+	}
+}
+
+func TestAssetCacheSkipsBodyCacheAboveMaxCachedSize(t *testing.T) {
+	fsys := fstest.MapFS{
+		"big.bin": {Data: []byte("0123456789")},
+	}
+	cache := newAssetCache(fsys, AssetHandlerConfig{MaxCachedSize: 4, LRUCapacity: 8})
+
+	cached, err := cache.get("big.bin", "big.bin")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if cached.Body != nil {
+		t.Error("expected a file over MaxCachedSize to not have its body cached")
+	}
+	if cached.ETag == "" {
+		t.Error("expected an ETag to still be computed for a file over MaxCachedSize")
+	}
+}
+
+func TestAssetCacheRecomputesOnModTimeChange(t *testing.T) {
+	fsys := fstest.MapFS{
+		"logo.png": {Data: []byte("v1"), ModTime: time.Unix(1000, 0)},
+	}
+	cache := newAssetCache(fsys, DefaultAssetHandlerConfig)
+
+	first, err := cache.get("logo.png", "logo.png")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+
+	fsys["logo.png"] = &fstest.MapFile{Data: []byte("v2-longer"), ModTime: time.Unix(2000, 0)}
+	second, err := cache.get("logo.png", "logo.png")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+
+	if first.ETag == second.ETag {
+		t.Error("expected the ETag to change once mtime/size changed")
+	}
+	if string(second.Body) != "v2-longer" {
+		t.Errorf("expected the refreshed body, got %q", second.Body)
+	}
+}
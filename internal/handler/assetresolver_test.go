@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newVendoredAssetBaseDir(t *testing.T) string {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "asset-resolver-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	vendorDir := filepath.Join(tmpDir, "assets", "vendor", "acme-2024")
+	if err := os.MkdirAll(vendorDir, 0755); err != nil {
+		t.Fatalf("Failed to create vendor dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(vendorDir, "logo.png"), []byte("logo"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	return tmpDir
+}
+
+func TestRendererResolveFindsNestedAsset(t *testing.T) {
+	baseDir := newVendoredAssetBaseDir(t)
+	r := NewRenderer(baseDir, nil)
+
+	resolved, ok := r.resolve("campaign-a", "images/logo.png")
+	if !ok {
+		t.Fatal("expected resolve() to find the vendored logo.png")
+	}
+	if resolved != "assets/vendor/acme-2024/logo.png" {
+		t.Errorf("expected resolved path %q, got %q", "assets/vendor/acme-2024/logo.png", resolved)
+	}
+}
+
+func TestRendererResolveCachesMiss(t *testing.T) {
+	baseDir := newVendoredAssetBaseDir(t)
+	r := NewRenderer(baseDir, nil)
+
+	if _, ok := r.resolve("campaign-a", "nonexistent.png"); ok {
+		t.Fatal("expected resolve() to report a miss for a nonexistent asset")
+	}
+
+	key := resolveKey{templateDir: "campaign-a", path: "nonexistent.png"}
+	if _, ok := r.resolved.Load(key); !ok {
+		t.Error("expected the miss to be cached")
+	}
+}
+
+func TestRendererResolveWarnsOnAmbiguousMatch(t *testing.T) {
+	baseDir := newVendoredAssetBaseDir(t)
+	if err := os.WriteFile(filepath.Join(baseDir, "assets", "logo.png"), []byte("other logo"), 0644); err != nil {
+		t.Fatalf("Failed to write second logo.png: %v", err)
+	}
+
+	r := NewRenderer(baseDir, nil)
+	if _, ok := r.resolve("campaign-a", "images/logo.png"); !ok {
+		t.Fatal("expected resolve() to still pick one match when ambiguous")
+	}
+}
+
+func TestConfigureAssetSearchPathsOverridesDefault(t *testing.T) {
+	baseDir := newVendoredAssetBaseDir(t)
+	ConfigureAssetSearchPaths(baseDir, []string{"assets/vendor/**"})
+
+	r := rendererFor(baseDir)
+	resolved, ok := r.resolve("campaign-a", "images/logo.png")
+	if !ok {
+		t.Fatal("expected resolve() to find logo.png via the configured search path")
+	}
+	if resolved != "assets/vendor/acme-2024/logo.png" {
+		t.Errorf("expected resolved path %q, got %q", "assets/vendor/acme-2024/logo.png", resolved)
+	}
+}
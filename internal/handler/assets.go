@@ -1,63 +1,295 @@
 package handler
 
 import (
+	"encoding/json"
+	"html"
+	"io/fs"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
+
+	"github.com/phishingclub/templates/internal/pipeline"
 )
 
-// AssetHandler creates a handler for serving template assets with fallback support
+// AssetHandler serves template assets with fallback support: it looks up
+// reqPath under baseDir first, then - if that misses - falls back to
+// assets/<dir>/<file> and finally assets/<file>, so a campaign can
+// reference a shared logo without duplicating it into every template
+// folder. It's the baseDir-string convenience wrapper NewAssetHandler is
+// built around.
+//
+// A request carrying a ?resize=, ?fit=, ?fill=, or ?minify= query
+// parameter is routed through the same pipeline package templates use
+// (see pipeline_funcs.go): the source asset is transformed, written into
+// baseDir's fingerprinted assets/_gen cache, and served with its content
+// hash as the ETag and a long, immutable Cache-Control, instead of the
+// plain pass-through the rest of this handler gives an unmodified asset.
 func AssetHandler(baseDir string) http.HandlerFunc {
+	root, err := os.OpenRoot(baseDir)
+	if err != nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "templates directory is unavailable", http.StatusInternalServerError)
+		}
+	}
+	plain := NewAssetHandler(root)
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Get the requested path (already stripped of /templates/ prefix)
-		reqPath := r.URL.Path
-		
-		// Clean the path to prevent directory traversal
-		cleanPath := filepath.Clean(reqPath)
-		if strings.Contains(cleanPath, "..") {
-			http.Error(w, "Invalid path", http.StatusBadRequest)
-			return
+		if op, param, ok := resourceOpQuery(r.URL.Query()); ok {
+			if serveTransformedAsset(w, r, baseDir, root.FS(), op, param) {
+				return
+			}
+		}
+		plain(w, r)
+	}
+}
+
+// resourceOpNames are the query parameters AssetHandler recognizes as a
+// request to transform the asset rather than serve it as-is, in the fixed
+// order checked when more than one is present on the same request.
+var resourceOpNames = []string{"resize", "fit", "fill", "minify"}
+
+// resourceOpQuery returns the first recognized resize/fit/fill/minify
+// query parameter in values, along with its value as the op's param.
+func resourceOpQuery(values url.Values) (op, param string, ok bool) {
+	for _, name := range resourceOpNames {
+		if v := values.Get(name); v != "" {
+			return name, v, true
 		}
-		
-		// Build the primary filesystem path
-		primaryPath := filepath.Join(baseDir, cleanPath)
-		
-		// Try to serve the file from the primary location
-		if info, err := os.Stat(primaryPath); err == nil && !info.IsDir() {
-			http.ServeFile(w, r, primaryPath)
+	}
+	return "", "", false
+}
+
+// serveTransformedAsset resolves r's path against fsys, runs it through
+// op/param via the pipeline package, and serves the result with a
+// fingerprinted ETag and a year-long immutable Cache-Control - the
+// transform's own content hash already guarantees a changed source or
+// param produces a different cache entry. It reports false (nothing
+// written) when the source asset can't be resolved, so the caller falls
+// back to the plain handler's own 404 handling.
+func serveTransformedAsset(w http.ResponseWriter, r *http.Request, baseDir string, fsys fs.FS, op, param string) bool {
+	name, pathErr := sanitizeAssetPath(r.URL.Path)
+	if pathErr != nil {
+		writeAssetPathError(w, pathErr)
+		return true
+	}
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return false
+	}
+
+	p := pipeline.New(baseDir, path.Dir(name))
+	transformed, err := p.ApplyOp(pipeline.NewAsset(path.Base(name), data), op, param)
+	if err != nil {
+		http.Error(w, "Failed to transform asset: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+
+	resource, err := p.Fingerprint(transformed)
+	if err != nil {
+		http.Error(w, "Failed to cache transformed asset: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+
+	w.Header().Set("ETag", `"`+path.Base(resource.URL)+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", resource.MIME)
+	w.Write(transformed.Content)
+	return true
+}
+
+// NewAssetHandler is AssetHandler's *os.Root-based form, for callers that
+// already have a Root open on the templates directory and want to share it
+// across handlers instead of opening a second one. It serves
+// DefaultAssetFS(root.FS()) - root.FS()'s own tree, then the historical
+// assets/ fallback - which reproduces AssetHandler's previous behavior
+// while resolving through TemplateFS instead of hand-guessing path
+// segments. Every lookup still goes through root.FS(), so containment to
+// root's directory is enforced by the kernel (openat2) even if a bug crept
+// into path cleaning, or a symlink inside the tree pointed somewhere
+// outside it.
+func NewAssetHandler(root *os.Root) http.HandlerFunc {
+	return NewAssetHandlerFS(DefaultAssetFS(root.FS()))
+}
+
+// NewAssetHandlerFS serves assets out of fsys, trying the full cleaned
+// request path against fsys as-is. Pass a *TemplateFS built with
+// DefaultAssetFS to reproduce AssetHandler's fallback behavior, or compose
+// a custom one - e.g. with a shared brand-assets tree and an embedded
+// default kit as further layers - and pass it here directly. It's
+// NewAssetHandlerFSConfig with DefaultAssetHandlerConfig.
+func NewAssetHandlerFS(fsys fs.FS) http.HandlerFunc {
+	return NewAssetHandlerFSConfig(fsys, DefaultAssetHandlerConfig)
+}
+
+// NewAssetHandlerFSConfig is NewAssetHandlerFS with tuning knobs: how large
+// a file body may be cached in memory, and how many such bodies an LRU
+// keeps warm at once. Every response carries a content-hash ETag (cached
+// per path, invalidated on mtime+size change), a Content-Type sniffed once
+// rather than per request, and - when the request's Accept-Encoding allows
+// it and a precompressed sibling exists in fsys - a gzip/br variant served
+// in its place. Range and conditional (If-None-Match/If-Modified-Since)
+// requests are handled by http.ServeContent once the ETag header is set,
+// reusing the cached body instead of reopening fsys for any file at or
+// under cfg.MaxCachedSize. The request path is run through
+// sanitizeAssetPath first, so an encoding or lookalike-character bypass
+// attempt is rejected with a reason code before fsys ever sees it.
+func NewAssetHandlerFSConfig(fsys fs.FS, cfg AssetHandlerConfig) http.HandlerFunc {
+	cache := newAssetCache(fsys, cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, pathErr := sanitizeAssetPath(r.URL.Path)
+		if pathErr != nil {
+			writeAssetPathError(w, pathErr)
 			return
 		}
-		
-		// Primary location failed, try asset fallback
-		// Check if this looks like a template asset path that should fall back to global assets
-		if strings.Contains(reqPath, "/") {
-			pathParts := strings.Split(strings.TrimPrefix(reqPath, "/"), "/")
-			
-			// For template asset requests like: Microsoft/Emails/Template/microsoft/microsoft-logo.png
-			// We want to extract the asset part (microsoft/microsoft-logo.png) and check in global assets
-			if len(pathParts) >= 2 {
-				// Get the last two parts (directory/filename) as potential asset path
-				assetPath := strings.Join(pathParts[len(pathParts)-2:], "/")
-				fallbackPath := filepath.Join(baseDir, "assets", assetPath)
-				
-				if info, err := os.Stat(fallbackPath); err == nil && !info.IsDir() {
-					http.ServeFile(w, r, fallbackPath)
-					return
-				}
-				
-				// If that doesn't work, try just the filename
-				filename := pathParts[len(pathParts)-1]
-				fallbackPath = filepath.Join(baseDir, "assets", filename)
-				
-				if info, err := os.Stat(fallbackPath); err == nil && !info.IsDir() {
-					http.ServeFile(w, r, fallbackPath)
-					return
-				}
-			}
+
+		if cache.serve(w, r, name) {
+			return
 		}
-		
-		// No fallback worked, return 404
 		http.NotFound(w, r)
 	}
-}
\ No newline at end of file
+}
+
+// assetPathReasonCode identifies which layer of sanitizeAssetPath rejected
+// a request path, so an operator looking at a 400 response can tell a
+// runaway decode loop apart from a plain traversal attempt.
+type assetPathReasonCode string
+
+const (
+	reasonDecodeLimitExceeded assetPathReasonCode = "decode_limit_exceeded"
+	reasonDisallowedByte      assetPathReasonCode = "disallowed_byte"
+	reasonTraversalPattern    assetPathReasonCode = "traversal_pattern"
+	reasonInvalidPath         assetPathReasonCode = "invalid_path"
+)
+
+// assetPathError pairs a reason code with a human-readable message.
+type assetPathError struct {
+	Code    assetPathReasonCode
+	Message string
+}
+
+func (e *assetPathError) Error() string { return e.Message }
+
+// writeAssetPathError responds with a structured 400: a JSON body naming
+// the reason code sanitizeAssetPath rejected the request for, instead of a
+// bare "Invalid path" that gives an operator nothing to search logs for.
+func writeAssetPathError(w http.ResponseWriter, err *assetPathError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(struct {
+		Error  string              `json:"error"`
+		Reason assetPathReasonCode `json:"reason"`
+	}{Error: err.Message, Reason: err.Code})
+}
+
+// maxPercentDecodeIterations bounds how many rounds of percent-decoding
+// sanitizeAssetPath will try before treating a path as suspicious rather
+// than decoding it indefinitely.
+const maxPercentDecodeIterations = 3
+
+// decodePercentUntilStable repeatedly percent-decodes s, stopping as soon
+// as a round leaves it unchanged. It reports false if maxIterations rounds
+// all produced a change without converging - e.g. a deliberately
+// deep-nested %2525652e chain - which sanitizeAssetPath treats as
+// suspicious on its own, independent of whatever the decoded result turns
+// out to contain.
+func decodePercentUntilStable(s string, maxIterations int) (string, bool) {
+	current := s
+	for i := 0; i < maxIterations; i++ {
+		decoded, err := url.QueryUnescape(current)
+		if err != nil || decoded == current {
+			return current, true
+		}
+		current = decoded
+	}
+
+	decoded, err := url.QueryUnescape(current)
+	return current, err != nil || decoded == current
+}
+
+// mapPathLookalikes fixes overlong-UTF-8-encoded '.'/'/' and maps Unicode
+// characters that render as a slash or dot - division slash U+2215,
+// fraction slash U+2044, fullwidth solidus U+FF0F, fullwidth full stop
+// U+FF0E - back to their ASCII equivalents, so a traversal scan downstream
+// can't be fooled by a lookalike a human reviewing the URL wouldn't
+// recognize as different. Unlike sanitizeUTF8 (which shreds any multi-byte
+// sequence down to its lead byte), this maps runes directly, so legitimate
+// non-ASCII filenames (e.g. "café.png") pass through unchanged.
+func mapPathLookalikes(s string) string {
+	s = strings.ReplaceAll(s, "\xc0\xae", ".")
+	s = strings.ReplaceAll(s, "\xc0\xaf", "/")
+
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '∕', '⁄', '／':
+			b.WriteRune('/')
+		case '．':
+			b.WriteRune('.')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// disallowedPathByte reports the first reason s should be rejected for -
+// a NUL byte, another control character below 0x20 (other than the three
+// normally found in free text, which a URL path has no legitimate use for
+// either, but are excluded here to match validatePath's leniency), or a
+// raw backslash - if any, after decoding and lookalike-mapping.
+func disallowedPathByte(s string) (assetPathReasonCode, bool) {
+	if strings.ContainsRune(s, '\\') {
+		return reasonDisallowedByte, true
+	}
+	for _, r := range s {
+		if r == 0 || (r < 0x20 && r != '\t' && r != '\n' && r != '\r') {
+			return reasonDisallowedByte, true
+		}
+	}
+	return "", false
+}
+
+// sanitizeAssetPath runs reqPath through the same decode/normalize
+// pipeline validatePath relies on, laid out as its own reusable steps:
+//
+//  1. repeated percent-decoding, rejected outright if it doesn't converge
+//     within maxPercentDecodeIterations rounds;
+//  2. Unicode slash/dot lookalike and overlong-UTF-8 normalization;
+//  3. rejection of NUL, other control characters, and raw backslashes;
+//  4. numeric/named HTML entity decoding (&#46;, &#x2e;, ...);
+//
+// before finally handing the result to fsRequestPath, which rejects
+// anything fs.ValidPath wouldn't accept - including a literal ".." that
+// survived every earlier step. Running all of this ahead of the TemplateFS
+// lookup (rather than letting os.Root be the only line of defense) is what
+// lets AssetHandler reject an encoded or lookalike traversal attempt with a
+// specific reason code instead of a generic "not found", and gives every
+// TemplateFS layer the same already-cleaned full path to look up.
+func sanitizeAssetPath(reqPath string) (string, *assetPathError) {
+	decoded, stable := decodePercentUntilStable(reqPath, maxPercentDecodeIterations)
+	if !stable {
+		return "", &assetPathError{
+			Code:    reasonDecodeLimitExceeded,
+			Message: "path did not converge after repeated percent-decoding",
+		}
+	}
+
+	normalized := mapPathLookalikes(decoded)
+
+	if code, bad := disallowedPathByte(normalized); bad {
+		return "", &assetPathError{Code: code, Message: "path contains a disallowed byte"}
+	}
+
+	unescaped := html.UnescapeString(normalized)
+	if containsTraversalPattern(unescaped) {
+		return "", &assetPathError{Code: reasonTraversalPattern, Message: "path contains a directory traversal pattern"}
+	}
+
+	name, err := fsRequestPath(unescaped)
+	if err != nil {
+		return "", &assetPathError{Code: reasonInvalidPath, Message: err.Error()}
+	}
+	return name, nil
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssetHandlerServesPrimaryPath(t *testing.T) {
+	baseDir := t.TempDir()
+	campaignDir := filepath.Join(baseDir, "Microsoft", "Emails", "Template")
+	if err := os.MkdirAll(campaignDir, 0755); err != nil {
+		t.Fatalf("Failed to create campaign dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(campaignDir, "logo.png"), []byte("primary"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/Microsoft/Emails/Template/logo.png", nil)
+	w := httptest.NewRecorder()
+	AssetHandler(baseDir)(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w.Body.String() != "primary" {
+		t.Errorf("expected primary file content, got %q", w.Body.String())
+	}
+}
+
+func TestAssetHandlerFallsBackToSharedAssets(t *testing.T) {
+	baseDir := t.TempDir()
+	campaignDir := filepath.Join(baseDir, "Microsoft", "Emails", "Template", "microsoft")
+	if err := os.MkdirAll(campaignDir, 0755); err != nil {
+		t.Fatalf("Failed to create campaign dir: %v", err)
+	}
+
+	assetsDir := filepath.Join(baseDir, "assets", "microsoft")
+	if err := os.MkdirAll(assetsDir, 0755); err != nil {
+		t.Fatalf("Failed to create assets dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(assetsDir, "microsoft-logo.png"), []byte("fallback"), 0644); err != nil {
+		t.Fatalf("Failed to write shared asset: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/Microsoft/Emails/Template/microsoft/microsoft-logo.png", nil)
+	w := httptest.NewRecorder()
+	AssetHandler(baseDir)(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w.Body.String() != "fallback" {
+		t.Errorf("expected fallback asset content, got %q", w.Body.String())
+	}
+}
+
+func TestAssetHandlerMissingFileReturns404(t *testing.T) {
+	baseDir := t.TempDir()
+
+	req := httptest.NewRequest("GET", "/does/not/exist.png", nil)
+	w := httptest.NewRecorder()
+	AssetHandler(baseDir)(w, req)
+
+	if w.Code != 404 {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestAssetHandlerRejectsPathEscape(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(filepath.Dir(baseDir), "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to write sibling secret file: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/../secret.txt", nil)
+	w := httptest.NewRecorder()
+	AssetHandler(baseDir)(w, req)
+
+	if w.Code == 200 {
+		t.Errorf("expected the path escape to be rejected, got 200: %q", w.Body.String())
+	}
+}
+
+func TestSanitizeAssetPathRejectsBypassAttempts(t *testing.T) {
+	tests := []struct {
+		name       string
+		reqPath    string
+		wantReason assetPathReasonCode
+	}{
+		{name: "URLEncodedTraversal", reqPath: "%2e%2e/secret.txt", wantReason: reasonTraversalPattern},
+		{name: "DoubleURLEncodedTraversal", reqPath: "%252e%252e/secret.txt", wantReason: reasonTraversalPattern},
+		{name: "UnicodeDivisionSlash", reqPath: "..∕secret.txt", wantReason: reasonTraversalPattern},
+		{name: "UnicodeFractionSlash", reqPath: "..⁄secret.txt", wantReason: reasonTraversalPattern},
+		{name: "FullwidthDotDot", reqPath: "．．／secret.txt", wantReason: reasonTraversalPattern},
+		{name: "HTMLEntityDotDot", reqPath: "&#46;&#46;/secret.txt", wantReason: reasonTraversalPattern},
+		{name: "HTMLHexEntityDotDot", reqPath: "&#x2e;&#x2e;/secret.txt", wantReason: reasonTraversalPattern},
+		{name: "UTF8OverlongDotDot", reqPath: "\xc0\xae\xc0\xae\xc0\xaf" + "secret.txt", wantReason: reasonTraversalPattern},
+		{name: "NullByte", reqPath: "logo\x00.png", wantReason: reasonDisallowedByte},
+		{name: "RawBackslash", reqPath: "..\\secret.txt", wantReason: reasonDisallowedByte},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sanitizeAssetPath(tt.reqPath)
+			if err == nil {
+				t.Fatalf("expected %q to be rejected", tt.reqPath)
+			}
+			if err.Code != tt.wantReason {
+				t.Errorf("expected reason %q, got %q (%s)", tt.wantReason, err.Code, err.Message)
+			}
+		})
+	}
+}
+
+func TestSanitizeAssetPathDecodeLimitExceeded(t *testing.T) {
+	// Four layers of percent-encoding around "..": with
+	// maxPercentDecodeIterations at 3, this never fully resolves, so it
+	// must be rejected as suspicious on its own rather than silently
+	// decoded down to a traversal pattern.
+	level1 := "%2e%2e"
+	level2 := url.QueryEscape(level1)
+	level3 := url.QueryEscape(level2)
+	level4 := url.QueryEscape(level3)
+
+	_, err := sanitizeAssetPath(level4 + "/secret.txt")
+	if err == nil {
+		t.Fatal("expected the deeply-nested encoding to be rejected")
+	}
+	if err.Code != reasonDecodeLimitExceeded {
+		t.Errorf("expected reason %q, got %q (%s)", reasonDecodeLimitExceeded, err.Code, err.Message)
+	}
+}
+
+func TestSanitizeAssetPathAllowsOrdinaryPaths(t *testing.T) {
+	name, err := sanitizeAssetPath("/Microsoft/Emails/Template/logo.png")
+	if err != nil {
+		t.Fatalf("expected an ordinary path to pass, got reason %q (%s)", err.Code, err.Message)
+	}
+	if name != "Microsoft/Emails/Template/logo.png" {
+		t.Errorf("expected the cleaned relative path, got %q", name)
+	}
+}
+
+func TestAssetHandlerRejectsUnicodeLookalikeTraversal(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(filepath.Dir(baseDir), "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to write sibling secret file: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/..∕..∕secret.txt", nil)
+	w := httptest.NewRecorder()
+	AssetHandler(baseDir)(w, req)
+
+	if w.Code != 400 {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Error  string `json:"error"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a structured JSON error body, got: %s", w.Body.String())
+	}
+	if body.Reason != string(reasonTraversalPattern) {
+		t.Errorf("expected reason %q, got %q", reasonTraversalPattern, body.Reason)
+	}
+}
+
+func TestNewAssetHandlerSharesRoot(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(baseDir, "shared.png"), []byte("shared"), 0644); err != nil {
+		t.Fatalf("Failed to write shared.png: %v", err)
+	}
+
+	root, err := os.OpenRoot(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to open root: %v", err)
+	}
+	defer root.Close()
+
+	req := httptest.NewRequest("GET", "/shared.png", nil)
+	w := httptest.NewRecorder()
+	NewAssetHandler(root)(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w.Body.String() != "shared" {
+		t.Errorf("expected shared.png content, got %q", w.Body.String())
+	}
+}
+
+func TestAssetHandlerResizeQueryParamServesTransformedImage(t *testing.T) {
+	baseDir := t.TempDir()
+	campaignDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(campaignDir, 0755); err != nil {
+		t.Fatalf("Failed to create campaign dir: %v", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(campaignDir, "logo.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/campaign-a/logo.png?resize=20x20", nil)
+	w := httptest.NewRecorder()
+	AssetHandler(baseDir)(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		t.Error("expected a Cache-Control header on a transformed asset")
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("expected an ETag header on a transformed asset")
+	}
+
+	out, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("expected a valid PNG response body: %v", err)
+	}
+	if out.Bounds().Dx() != 20 || out.Bounds().Dy() != 20 {
+		t.Errorf("expected a resized 20x20 image, got %dx%d", out.Bounds().Dx(), out.Bounds().Dy())
+	}
+
+	cacheDir := filepath.Join(baseDir, "assets", "_gen")
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil || len(entries) == 0 {
+		t.Errorf("expected the resized asset to be written to %s", cacheDir)
+	}
+}
+
+func TestAssetHandlerMissingSourceFallsBackTo404(t *testing.T) {
+	baseDir := t.TempDir()
+
+	req := httptest.NewRequest("GET", "/does/not/exist.png?resize=20x20", nil)
+	w := httptest.NewRecorder()
+	AssetHandler(baseDir)(w, req)
+
+	if w.Code != 404 {
+		t.Errorf("expected 404 for a resize request against a missing asset, got %d", w.Code)
+	}
+}
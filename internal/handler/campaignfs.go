@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+)
+
+// ValidateCampaignsFS runs the same checks as validateCampaigns against any
+// fs.FS - a *zip.Reader for an uploaded archive, a tarball-backed FS, an
+// embed.FS, or an fs.Sub overlay of a base tree and a private client
+// directory - not just a directory on disk. validateCampaigns already takes
+// a Store (which is just fs.FS), so this is a thin, exported alias kept
+// around so callers outside the package don't need to know that.
+func ValidateCampaignsFS(fsys fs.FS) error {
+	return validateCampaigns(fsys)
+}
+
+// ValidateCampaigns validates the campaign tree rooted at root on disk. It's
+// the wrapper ValidateCampaignsFS is built around.
+func ValidateCampaigns(root string) error {
+	return ValidateCampaignsFS(os.DirFS(root))
+}
+
+// AddPhishingTemplatesFS walks fsys the same way addPhishingTemplates does -
+// one templates/<name>/ entry per campaign folder, hash-suffixing any name
+// that collides - writing directly to zw. It's the zip-only entry point for
+// callers that already have a *zip.Writer and don't need the
+// ArchiveWriter/format-negotiation machinery DownloadHandler and
+// ExportHandler use, e.g. re-zipping an uploaded archive after validating
+// it.
+func AddPhishingTemplatesFS(zw *zip.Writer, fsys fs.FS) error {
+	return addPhishingTemplates(&zipArchiveWriter{zw: zw}, fsys, nil)
+}
+
+// AddPhishingTemplates adds the campaign tree rooted at root on disk to zw.
+// It's the wrapper AddPhishingTemplatesFS is built around.
+func AddPhishingTemplates(zw *zip.Writer, root string) error {
+	return AddPhishingTemplatesFS(zw, os.DirFS(root))
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateCampaignsFSDetectsDuplicateNamesInMemory(t *testing.T) {
+	dataYaml := []byte(`name: "Chat Beta Invite Campaign"`)
+	fsys := fstest.MapFS{
+		"client-a/chat-beta/data.yaml":  &fstest.MapFile{Data: dataYaml},
+		"client-a/chat-beta/email.html": &fstest.MapFile{Data: []byte("<html>A</html>")},
+		"client-b/chat-beta/data.yaml":  &fstest.MapFile{Data: dataYaml},
+		"client-b/chat-beta/email.html": &fstest.MapFile{Data: []byte("<html>B</html>")},
+	}
+
+	err := ValidateCampaignsFS(fsys)
+	if _, ok := err.(DuplicateError); !ok {
+		t.Fatalf("expected a DuplicateError for the colliding campaign name, got %T: %v", err, err)
+	}
+}
+
+func TestValidateCampaignsFSSkipsPrivateInMemory(t *testing.T) {
+	dataYaml := []byte(`name: "Client Specific Campaign"`)
+	fsys := fstest.MapFS{
+		"private/client-company/data.yaml": &fstest.MapFile{Data: dataYaml},
+		"private/client-company/email.html": &fstest.MapFile{
+			Data: []byte("<html>Private email</html>"),
+		},
+		"generic-service/data.yaml":  &fstest.MapFile{Data: dataYaml},
+		"generic-service/email.html": &fstest.MapFile{Data: []byte("<html>Public email</html>")},
+	}
+
+	if err := ValidateCampaignsFS(fsys); err != nil {
+		t.Errorf("expected validation to pass with the private folder skipped, got: %v", err)
+	}
+}
+
+func TestAddPhishingTemplatesFSCreatesHashedFoldersInMemory(t *testing.T) {
+	dataYaml := []byte(`name: "Chat Beta Invite Campaign"`)
+	fsys := fstest.MapFS{
+		"Contoso/Emails/Chat beta invite/data.yaml":         &fstest.MapFile{Data: dataYaml},
+		"Contoso/Emails/Chat beta invite/email.html":        &fstest.MapFile{Data: []byte("<html>Content</html>")},
+		"Contoso/Landing Pages/Chat beta invite/data.yaml":  &fstest.MapFile{Data: dataYaml},
+		"Contoso/Landing Pages/Chat beta invite/email.html": &fstest.MapFile{Data: []byte("<html>Content</html>")},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	if err := AddPhishingTemplatesFS(zw, fsys); err != nil {
+		t.Fatalf("AddPhishingTemplatesFS failed: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to create zip reader: %v", err)
+	}
+
+	foundFolders := make(map[string]bool)
+	for _, file := range zipReader.File {
+		if strings.HasPrefix(file.Name, "templates/") {
+			parts := strings.Split(file.Name, "/")
+			if len(parts) >= 2 {
+				foundFolders[parts[1]] = true
+			}
+		}
+	}
+
+	if len(foundFolders) != 2 {
+		t.Fatalf("Expected exactly 2 folders, but found %d: %v", len(foundFolders), foundFolders)
+	}
+
+	hasOriginal, hasHashed := false, false
+	for folderName := range foundFolders {
+		if folderName == "Chat beta invite" {
+			hasOriginal = true
+		} else if strings.HasPrefix(folderName, "Chat beta invite-") && len(folderName) == len("Chat beta invite")+9 {
+			hasHashed = true
+		}
+	}
+	if !hasOriginal {
+		t.Errorf("Expected original folder 'Chat beta invite' not found. Found folders: %v", foundFolders)
+	}
+	if !hasHashed {
+		t.Errorf("Expected a hash-suffixed folder. Found folders: %v", foundFolders)
+	}
+}
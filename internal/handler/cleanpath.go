@@ -0,0 +1,42 @@
+package handler
+
+import "strings"
+
+// CleanPath collapses runs of multiple slashes in p - but only when asked to
+// - and preserves a trailing slash present in the input. p is otherwise left
+// alone: path.Clean isn't used here because collapsing multiple slashes is
+// one of its lexical rules too, with no way to opt out of it, and a path can
+// legitimately contain repeated slashes before it's been identified as a
+// local asset reference (e.g. the "//cdn.example.com/x" in a
+// protocol-relative URL, where it's the host - not the path - that starts
+// with slashes).
+//
+// Ported from Caddy's path-cleaning helper.
+func CleanPath(p string, collapseSlashes bool) string {
+	if !collapseSlashes || p == "" {
+		return p
+	}
+
+	hadTrailingSlash := len(p) > 1 && strings.HasSuffix(p, "/")
+
+	var b strings.Builder
+	b.Grow(len(p))
+	prevSlash := false
+	for _, r := range p {
+		if r == '/' {
+			if prevSlash {
+				continue
+			}
+			prevSlash = true
+		} else {
+			prevSlash = false
+		}
+		b.WriteRune(r)
+	}
+	cleaned := b.String()
+
+	if hadTrailingSlash && !strings.HasSuffix(cleaned, "/") {
+		cleaned += "/"
+	}
+	return cleaned
+}
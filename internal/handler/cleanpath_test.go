@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		collapseSlashes bool
+		want            string
+	}{
+		{"collapses repeated slashes", "path//to///img.png", true, "path/to/img.png"},
+		{"leaves repeated slashes when not asked", "path//to///img.png", false, "path//to///img.png"},
+		{"preserves trailing slash", "/a/b/", true, "/a/b/"},
+		{"root stays root", "/", true, "/"},
+		{"no trailing slash stays absent", "/a/b", true, "/a/b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CleanPath(tt.path, tt.collapseSlashes)
+			if got != tt.want {
+				t.Errorf("CleanPath(%q, %v) = %q, want %q", tt.path, tt.collapseSlashes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessAssetPathsCollapsesWithinAttributeOnly(t *testing.T) {
+	content := `<img src="path//to///img.png">`
+	got := processAssetPaths(content, "campaign-a/page.html", t.TempDir())
+
+	if want := `src="/templates/campaign-a/path/to/img.png"`; !strings.Contains(got, want) {
+		t.Errorf("expected collapsed src path %q, got %q", want, got)
+	}
+}
+
+func TestProcessAssetPathsPreservesProtocolRelativeURL(t *testing.T) {
+	content := `<link href="//cdn/x">`
+	got := processAssetPaths(content, "campaign-a/page.html", t.TempDir())
+
+	if want := `href="//cdn/x"`; !strings.Contains(got, want) {
+		t.Errorf("expected untouched protocol-relative URL %q, got %q", want, got)
+	}
+}
+
+func TestProcessAssetPathsKeepsTrailingSlashOnAction(t *testing.T) {
+	content := `<form action="/a/b/">`
+	got := processAssetPaths(content, "campaign-a/page.html", t.TempDir())
+
+	if want := `action="/templates/campaign-a/a/b/"`; !strings.Contains(got, want) {
+		t.Errorf("expected trailing slash preserved on rewritten action %q, got %q", want, got)
+	}
+}
+
+func TestProcessAssetPathsLeavesScriptCommentsUntouched(t *testing.T) {
+	content := "<script>\n// comment\nvar x = 1;\n</script>"
+	got := processAssetPaths(content, "campaign-a/page.html", t.TempDir())
+
+	if got != content {
+		t.Errorf("expected script block untouched, got %q", got)
+	}
+}
@@ -1,12 +1,10 @@
 package handler
 
 import (
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/smtp"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,32 +12,73 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// EmailEntry is one email template's send-time metadata, as listed under
+// data.yaml's emails: section.
+type EmailEntry struct {
+	Name         string `yaml:"name"`
+	File         string `yaml:"file"`
+	EnvelopeFrom string `yaml:"envelope from"`
+	From         string `yaml:"from"`
+	Subject      string `yaml:"subject"`
+
+	// Text, if set, is a path (relative to the template's own directory)
+	// to an explicit plaintext alternative; otherwise one is derived from
+	// the rendered HTML. Attachments are paths resolved the same
+	// local-then-global way as an <img src>. Headers are extra RFC 5322
+	// headers to set verbatim. ReplyTo becomes the Reply-To header.
+	Text        string            `yaml:"text,omitempty"`
+	Attachments []string          `yaml:"attachments,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	ReplyTo     string            `yaml:"reply_to,omitempty"`
+}
+
 // EmailData represents the structure of data.yaml for email templates
 type EmailData struct {
-	Name   string `yaml:"name"`
-	Emails []struct {
-		Name         string `yaml:"name"`
-		File         string `yaml:"file"`
-		EnvelopeFrom string `yaml:"envelope from"`
-		From         string `yaml:"from"`
-		Subject      string `yaml:"subject"`
-	} `yaml:"emails"`
+	Name         string       `yaml:"name"`
+	Emails       []EmailEntry `yaml:"emails"`
 	LandingPages []struct {
 		Name string `yaml:"name"`
 		File string `yaml:"file"`
 	} `yaml:"landing_pages"`
 }
 
-// SendEmailRequest represents the JSON request for sending emails
+// SendEmailRequest represents the JSON request for sending emails. To is
+// used for a single ad-hoc test send; Recipients, if given, switches
+// SendTestEmailHandler into batch mode - one personalized message per
+// recipient, each rendered with its own tracking ID and template
+// variables, mirroring how a real campaign send would look.
 type SendEmailRequest struct {
-	TemplatePath string `json:"templatePath"`
-	To           string `json:"to"`
+	TemplatePath string           `json:"templatePath"`
+	To           string           `json:"to"`
+	Recipients   []BatchRecipient `json:"recipients,omitempty"`
+}
+
+// BatchRecipient is one recipient of a batch/campaign-style test send.
+// RID, if empty, gets a generated tracking ID; CustomVars are bare-keyed
+// (e.g. {"Department": "Sales"}), the same form vars.yaml and the /api/vars
+// endpoint already use.
+type BatchRecipient struct {
+	Email      string            `json:"email"`
+	FirstName  string            `json:"firstName,omitempty"`
+	LastName   string            `json:"lastName,omitempty"`
+	RID        string            `json:"rid,omitempty"`
+	CustomVars map[string]string `json:"customVars,omitempty"`
 }
 
 // SendEmailResponse represents the JSON response
 type SendEmailResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	MessageID string `json:"messageId,omitempty"`
+}
+
+// BatchSendResult is one NDJSON line of a batch send - the per-recipient
+// counterpart to SendEmailResponse.
+type BatchSendResult struct {
+	Email     string `json:"email"`
+	Success   bool   `json:"success"`
+	MessageID string `json:"messageId,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
 // IsEmailTemplate checks if a given template path represents an email template
@@ -75,166 +114,224 @@ func IsEmailTemplate(baseDir, templatePath string) (bool, *EmailData, error) {
 	return false, &emailData, nil
 }
 
-// SendTestEmailHandler handles POST requests to send test emails
-func SendTestEmailHandler(baseDir string, serverAddr string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+// emailTemplateError pairs a resolveEmailTemplate failure with the HTTP
+// status it should produce, so the handler doesn't have to guess from the
+// message text.
+type emailTemplateError struct {
+	status  int
+	message string
+}
 
-		w.Header().Set("Content-Type", "application/json")
+func (e emailTemplateError) Error() string { return e.message }
 
-		// Parse JSON request
-		var req SendEmailRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeErrorResponse(w, "Invalid JSON request", http.StatusBadRequest)
-			return
+// resolveEmailTemplate validates templatePath, confirms it's an email
+// template, and returns its raw content and data.yaml entry - the lookup
+// shared by a single test send and a batch send, since both render and
+// deliver the same template, just to different recipient lists.
+func resolveEmailTemplate(baseDir, templatePath string) (content []byte, emailConfig *EmailEntry, err error) {
+	fsPath, err := validatePath(baseDir, templatePath)
+	if err != nil {
+		return nil, nil, emailTemplateError{http.StatusBadRequest, "Invalid template path"}
+	}
+
+	if _, err := os.Stat(fsPath); os.IsNotExist(err) {
+		return nil, nil, emailTemplateError{http.StatusNotFound, "Template not found"}
+	}
+
+	isEmail, emailData, err := IsEmailTemplate(baseDir, templatePath)
+	if err != nil {
+		return nil, nil, emailTemplateError{http.StatusInternalServerError, "Error reading template data: " + err.Error()}
+	}
+	if !isEmail {
+		return nil, nil, emailTemplateError{http.StatusBadRequest, "Not an email template"}
+	}
+
+	templateFile := filepath.Base(templatePath)
+	for i := range emailData.Emails {
+		if emailData.Emails[i].File == templateFile {
+			emailConfig = &emailData.Emails[i]
+			break
 		}
+	}
+	if emailConfig == nil {
+		return nil, nil, emailTemplateError{http.StatusInternalServerError, "Email configuration not found"}
+	}
 
-		// Validate template path
-		fsPath, err := validatePath(baseDir, req.TemplatePath)
+	content, err = os.ReadFile(fsPath)
+	if err != nil {
+		return nil, nil, emailTemplateError{http.StatusInternalServerError, "Failed to read template: " + err.Error()}
+	}
+
+	return content, emailConfig, nil
+}
+
+// recipientBareVars builds the bare-keyed ("FirstName", not "{{.FirstName}}")
+// variable overrides for one recipient, layering CustomVars on top of its
+// named fields - matching the precedence vars.yaml/*.vars.yaml already use,
+// most specific last.
+func recipientBareVars(recipient BatchRecipient, trackingID string) map[string]string {
+	bare := map[string]string{"rID": trackingID}
+	if recipient.Email != "" {
+		bare["Email"] = recipient.Email
+		bare["To"] = recipient.Email
+	}
+	if recipient.FirstName != "" {
+		bare["FirstName"] = recipient.FirstName
+	}
+	if recipient.LastName != "" {
+		bare["LastName"] = recipient.LastName
+	}
+	for name, value := range recipient.CustomVars {
+		bare[name] = value
+	}
+	return bare
+}
+
+// sendEmailToRecipient renders content for recipient and delivers it
+// through whichever backend EMAIL_BACKEND selects, returning the
+// Message-ID it was sent with so the caller can look it up in Mailpit
+// afterwards.
+func sendEmailToRecipient(baseDir, serverAddr, templatePath string, emailConfig *EmailEntry, content string, recipient BatchRecipient, baseVars map[string]string) (string, error) {
+	trackingID := recipient.RID
+	if trackingID == "" {
+		var err error
+		trackingID, err = newUUID()
 		if err != nil {
-			writeErrorResponse(w, "Invalid template path", http.StatusBadRequest)
-			return
+			return "", fmt.Errorf("failed to generate a tracking ID: %w", err)
 		}
+	}
 
-		// Check if file exists
-		if _, err := os.Stat(fsPath); os.IsNotExist(err) {
-			writeErrorResponse(w, "Template not found", http.StatusNotFound)
-			return
-		}
+	vars := mergeBareVars(baseVars, recipientBareVars(recipient, trackingID))
+	processedContent := processTemplateContentForEmail(content, templatePath, baseDir, serverAddr, trackingID, vars)
 
-		// Check if this is an email template
-		isEmail, emailData, err := IsEmailTemplate(baseDir, req.TemplatePath)
+	sender, err := EmailSenderFromEnv()
+	if err != nil {
+		return "", fmt.Errorf("failed to configure email sender: %w", err)
+	}
+
+	messageID, err := newMessageID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate message ID: %w", err)
+	}
+
+	msg := EmailMessage{
+		From:         emailConfig.From,
+		EnvelopeFrom: emailConfig.EnvelopeFrom,
+		To:           recipient.Email,
+		Subject:      emailConfig.Subject,
+		HTMLBody:     processedContent,
+		ReplyTo:      emailConfig.ReplyTo,
+		Headers:      emailConfig.Headers,
+		Attachments:  emailConfig.Attachments,
+		BaseDir:      baseDir,
+		ReqPath:      templatePath,
+		MessageID:    messageID,
+	}
+	if emailConfig.Text != "" {
+		textPath, err := validatePath(baseDir, filepath.Join(filepath.Dir(templatePath), emailConfig.Text))
 		if err != nil {
-			writeErrorResponse(w, "Error reading template data: "+err.Error(), http.StatusInternalServerError)
-			return
+			return "", fmt.Errorf("invalid text alternative path: %w", err)
 		}
-
-		if !isEmail {
-			writeErrorResponse(w, "Not an email template", http.StatusBadRequest)
-			return
+		textContent, err := os.ReadFile(textPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read text alternative: %w", err)
 		}
+		msg.Text = string(textContent)
+	}
 
-		// Find the email configuration for this template
-		templateFile := filepath.Base(req.TemplatePath)
-		var emailConfig *struct {
-			Name         string `yaml:"name"`
-			File         string `yaml:"file"`
-			EnvelopeFrom string `yaml:"envelope from"`
-			From         string `yaml:"from"`
-			Subject      string `yaml:"subject"`
-		}
+	if rich, ok := sender.(RichEmailSender); ok {
+		err = rich.SendMessage(msg)
+	} else {
+		err = sender.Send(msg.From, msg.To, msg.Subject, msg.HTMLBody)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to send email: %w", err)
+	}
 
-		for i := range emailData.Emails {
-			if emailData.Emails[i].File == templateFile {
-				emailConfig = &emailData.Emails[i]
-				break
-			}
-		}
+	return strings.Trim(messageID, "<>"), nil
+}
 
-		if emailConfig == nil {
-			writeErrorResponse(w, "Email configuration not found", http.StatusInternalServerError)
+// SendTestEmailHandler handles POST requests to send test emails. With a
+// bare "to", it sends a single message and returns a SendEmailResponse.
+// With "recipients", it switches to a batch/campaign-style dry run: one
+// personalized message per recipient, streamed back as NDJSON (one
+// BatchSendResult per line) as each send completes, mirroring how
+// gophish's processCampaign works through a recipient list.
+func SendTestEmailHandler(baseDir string, serverAddr string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Read and process the email template
-		content, err := os.ReadFile(fsPath)
-		if err != nil {
-			writeErrorResponse(w, "Failed to read template: "+err.Error(), http.StatusInternalServerError)
+		var req SendEmailRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorResponse(w, "Invalid JSON request", http.StatusBadRequest)
 			return
 		}
 
-		// Process the template content with variables for email
-		processedContent := processTemplateContentForEmail(string(content), req.TemplatePath, baseDir, serverAddr)
-
-		// Set default recipient
-		to := "test@example.com"
-		if req.To != "" {
-			to = req.To
-		}
-
-		// Send the email
-		err = sendSMTPEmail(emailConfig.From, to, emailConfig.Subject, processedContent)
+		content, emailConfig, err := resolveEmailTemplate(baseDir, req.TemplatePath)
 		if err != nil {
-			writeErrorResponse(w, "Failed to send email: "+err.Error(), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			var templateErr emailTemplateError
+			if errors.As(err, &templateErr) {
+				status = templateErr.status
+			}
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorResponse(w, err.Error(), status)
 			return
 		}
 
-		// Return success response
-		response := SendEmailResponse{
-			Success: true,
-			Message: fmt.Sprintf("Email sent successfully to %s", to),
+		baseVars, err := loadBaseTemplateVars(baseDir)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorResponse(w, "Failed to load template variables: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 
-		json.NewEncoder(w).Encode(response)
-	}
-}
+		if len(req.Recipients) == 0 {
+			to := "test@example.com"
+			if req.To != "" {
+				to = req.To
+			}
 
-// sendSMTPEmail sends an email via SMTP to Mailpit
-func sendSMTPEmail(from, to, subject, htmlBody string) error {
-	// Mailpit SMTP configuration
-	smtpHost := "mailer" // Docker service name
-	smtpPort := "1025"
-
-	// Extract email address from "Name <email@domain.com>" format for SMTP commands
-	fromEmail := extractEmailAddress(from)
-
-	// Create message
-	msg := fmt.Sprintf("From: %s\r\n", from)
-	msg += fmt.Sprintf("To: %s\r\n", to)
-	msg += fmt.Sprintf("Subject: %s\r\n", subject)
-	msg += "MIME-Version: 1.0\r\n"
-	msg += "Content-Type: text/html; charset=UTF-8\r\n"
-	msg += "\r\n"
-	msg += htmlBody
-
-	// Connect to SMTP server
-	conn, err := smtp.Dial(smtpHost + ":" + smtpPort)
-	if err != nil {
-		return fmt.Errorf("failed to connect to SMTP server: %v", err)
-	}
-	defer conn.Close()
+			messageID, err := sendEmailToRecipient(baseDir, serverAddr, req.TemplatePath, emailConfig, string(content), BatchRecipient{Email: to}, baseVars)
 
-	// Start TLS if available (Mailpit supports it)
-	if ok, _ := conn.Extension("STARTTLS"); ok {
-		config := &tls.Config{
-			ServerName:         smtpHost,
-			InsecureSkipVerify: true, // For development only
-		}
-		if err = conn.StartTLS(config); err != nil {
-			// If TLS fails, continue without it (Mailpit accepts both)
+			w.Header().Set("Content-Type", "application/json")
+			if err != nil {
+				writeErrorResponse(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(SendEmailResponse{
+				Success:   true,
+				Message:   fmt.Sprintf("Email sent successfully to %s", to),
+				MessageID: messageID,
+			})
+			return
 		}
-	}
 
-	// Set sender (use extracted email address only)
-	if err := conn.Mail(fromEmail); err != nil {
-		return fmt.Errorf("failed to set sender: %v", err)
-	}
-
-	// Set recipient
-	if err := conn.Rcpt(to); err != nil {
-		return fmt.Errorf("failed to set recipient: %v", err)
-	}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, _ := w.(http.Flusher)
+		encoder := json.NewEncoder(w)
 
-	// Send message
-	w, err := conn.Data()
-	if err != nil {
-		return fmt.Errorf("failed to start data transfer: %v", err)
-	}
+		for _, recipient := range req.Recipients {
+			result := BatchSendResult{Email: recipient.Email}
 
-	_, err = io.WriteString(w, msg)
-	if err != nil {
-		return fmt.Errorf("failed to write message: %v", err)
-	}
+			messageID, err := sendEmailToRecipient(baseDir, serverAddr, req.TemplatePath, emailConfig, string(content), recipient, baseVars)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Success = true
+				result.MessageID = messageID
+			}
 
-	err = w.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close data transfer: %v", err)
+			encoder.Encode(result)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
 	}
-
-	return nil
 }
 
 // extractEmailAddress extracts just the email address from formats like "Name <email@domain.com>"
@@ -297,20 +394,25 @@ func CheckEmailTemplateHandler(baseDir string) http.HandlerFunc {
 	}
 }
 
-// processTemplateContentForEmail processes template content specifically for email sending
-// Uses localhost URLs for working links and assets in Mailpit
-func processTemplateContentForEmail(content, reqPath, baseDir, serverAddr string) string {
+// processTemplateContentForEmail processes template content specifically
+// for email sending, using localhost URLs for working links and assets in
+// Mailpit. trackingID is substituted into {{.TrackingURL}}/{{.Tracker}}
+// instead of a hard-coded placeholder, so a batch send gives each
+// recipient a distinct tracking link; vars supplies the remaining
+// "{{.Name}}" substitutions (recipient fields, customVars, and whatever
+// loadBaseTemplateVars/vars.yaml left in place).
+func processTemplateContentForEmail(content, reqPath, baseDir, serverAddr, trackingID string, vars map[string]string) string {
 	// For emails viewed in Mailpit, use localhost and point to assets directory
 	baseURL := fmt.Sprintf("http://localhost%s/templates/assets", serverAddr)
 
 	// Use direct string replacement with rewritten URLs for email
 	content = strings.Replace(content, "{{.BaseURL}}", baseURL, -1)
 	content = strings.Replace(content, "{{.URL}}", fmt.Sprintf("http://localhost%s/raw/%s", serverAddr, strings.TrimSuffix(reqPath, filepath.Ext(reqPath))+".html"), -1)
-	content = strings.Replace(content, "{{.TrackingURL}}", fmt.Sprintf("http://localhost%s/api/track/clicked/unique-id", serverAddr), -1)
-	content = strings.Replace(content, "{{.Tracker}}", fmt.Sprintf(`<img src="http://localhost%s/api/track/opened/unique-id" alt="" width="1" height="1" border="0" style="height:1px !important;width:1px" />`, serverAddr), -1)
+	content = strings.Replace(content, "{{.TrackingURL}}", fmt.Sprintf("http://localhost%s/api/track/clicked/%s", serverAddr, trackingID), -1)
+	content = strings.Replace(content, "{{.Tracker}}", fmt.Sprintf(`<img src="http://localhost%s/api/track/opened/%s" alt="" width="1" height="1" border="0" style="height:1px !important;width:1px" />`, serverAddr, trackingID), -1)
 
 	// Replace other template variables with their original values
-	for placeholder, value := range templateVars {
+	for placeholder, value := range vars {
 		if placeholder != "{{.BaseURL}}" && placeholder != "{{.URL}}" && placeholder != "{{.TrackingURL}}" && placeholder != "{{.Tracker}}" {
 			content = strings.Replace(content, placeholder, value, -1)
 		}
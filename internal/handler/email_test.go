@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func createTestEmailCampaign(t *testing.T) string {
+	t.Helper()
+	baseDir := t.TempDir()
+	campaignDir := filepath.Join(baseDir, "microsoft-login")
+	if err := os.MkdirAll(campaignDir, 0755); err != nil {
+		t.Fatalf("Failed to create campaign dir: %v", err)
+	}
+
+	dataYaml := `name: "Microsoft Login Alert"
+emails:
+  - name: "Microsoft Login Alert"
+    file: "email.html"
+    envelope from: "security@microsoft.com"
+    from: "Microsoft Security <security@microsoft.com>"
+    subject: "Unusual Login Activity"`
+	if err := os.WriteFile(filepath.Join(campaignDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write data.yaml: %v", err)
+	}
+
+	emailHTML := `<html><body><h1>Hello {{.FirstName}}</h1><p>{{.Tracker}}</p><a href="{{.TrackingURL}}">Review activity</a></body></html>`
+	if err := os.WriteFile(filepath.Join(campaignDir, "email.html"), []byte(emailHTML), 0644); err != nil {
+		t.Fatalf("Failed to write email.html: %v", err)
+	}
+
+	return baseDir
+}
+
+func TestSendTestEmailHandlerSingleRecipient(t *testing.T) {
+	t.Setenv("EMAIL_BACKEND", "fake")
+	baseDir := createTestEmailCampaign(t)
+
+	body, _ := json.Marshal(SendEmailRequest{
+		TemplatePath: "microsoft-login/email.html",
+		To:           "alice@example.com",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/email/send", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	SendTestEmailHandler(baseDir, ":8080")(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp SendEmailResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Success || resp.MessageID == "" {
+		t.Errorf("expected a successful send with a MessageID, got %+v", resp)
+	}
+}
+
+func TestSendTestEmailHandlerBatchStreamsNDJSON(t *testing.T) {
+	t.Setenv("EMAIL_BACKEND", "fake")
+	baseDir := createTestEmailCampaign(t)
+
+	body, _ := json.Marshal(SendEmailRequest{
+		TemplatePath: "microsoft-login/email.html",
+		Recipients: []BatchRecipient{
+			{Email: "alice@example.com", FirstName: "Alice"},
+			{Email: "bob@example.com", FirstName: "Bob", CustomVars: map[string]string{"Department": "Sales"}},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/email/send", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	SendTestEmailHandler(baseDir, ":8080")(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected an NDJSON content type, got %q", ct)
+	}
+
+	var results []BatchSendResult
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	for scanner.Scan() {
+		var result BatchSendResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode NDJSON line %q: %v", scanner.Text(), err)
+		}
+		results = append(results, result)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d: %+v", len(results), results)
+	}
+	for _, result := range results {
+		if !result.Success || result.MessageID == "" {
+			t.Errorf("expected a successful send with a MessageID for %s, got %+v", result.Email, result)
+		}
+	}
+	if results[0].MessageID == results[1].MessageID {
+		t.Errorf("expected distinct Message-IDs per recipient, got %q twice", results[0].MessageID)
+	}
+}
+
+func TestProcessTemplateContentForEmailPersonalizesPerRecipient(t *testing.T) {
+	baseVars := map[string]string{"{{.FirstName}}": "John", "{{.TrackingURL}}": "unused", "{{.Tracker}}": "unused"}
+	content := `<h1>Hello {{.FirstName}}</h1><a href="{{.TrackingURL}}">link</a>{{.Tracker}}`
+
+	aliceVars := mergeBareVars(baseVars, recipientBareVars(BatchRecipient{Email: "alice@example.com", FirstName: "Alice"}, "rid-alice"))
+	aliceRendered := processTemplateContentForEmail(content, "campaign/email.html", "/base", ":8080", "rid-alice", aliceVars)
+
+	if !strings.Contains(aliceRendered, "Hello Alice") {
+		t.Errorf("expected Alice's name to be substituted, got %q", aliceRendered)
+	}
+	if !strings.Contains(aliceRendered, "/api/track/clicked/rid-alice") {
+		t.Errorf("expected Alice's tracking ID in the TrackingURL, got %q", aliceRendered)
+	}
+	if !strings.Contains(aliceRendered, "/api/track/opened/rid-alice") {
+		t.Errorf("expected Alice's tracking ID in the Tracker pixel, got %q", aliceRendered)
+	}
+
+	bobVars := mergeBareVars(baseVars, recipientBareVars(BatchRecipient{Email: "bob@example.com", FirstName: "Bob"}, "rid-bob"))
+	bobRendered := processTemplateContentForEmail(content, "campaign/email.html", "/base", ":8080", "rid-bob", bobVars)
+
+	if !strings.Contains(bobRendered, "Hello Bob") {
+		t.Errorf("expected Bob's name to be substituted, got %q", bobRendered)
+	}
+	if strings.Contains(bobRendered, "rid-alice") {
+		t.Errorf("expected Bob's render not to leak Alice's tracking ID, got %q", bobRendered)
+	}
+}
+
+func TestSendTestEmailHandlerUnknownTemplateReturnsError(t *testing.T) {
+	t.Setenv("EMAIL_BACKEND", "fake")
+	baseDir := createTestEmailCampaign(t)
+
+	body, _ := json.Marshal(SendEmailRequest{TemplatePath: "does-not-exist/email.html", To: "alice@example.com"})
+	req := httptest.NewRequest(http.MethodPost, "/api/email/send", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	SendTestEmailHandler(baseDir, ":8080")(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing template, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// EmailMessage is everything Build needs to compose a real RFC 5322
+// message for SendTestEmailHandler, replacing the old approach of
+// concatenating a bare text/html body with hand-written headers.
+type EmailMessage struct {
+	From         string // header From, e.g. "IT Support <it@example.com>"
+	EnvelopeFrom string // SMTP MAIL FROM; falls back to From's address if empty
+	To           string
+	Subject      string
+	HTMLBody     string
+	Text         string // explicit plaintext alternative; auto-derived from HTMLBody if empty
+	ReplyTo      string
+	Headers      map[string]string
+	Attachments  []string // paths, resolved the same local-then-global way as an <img src>
+
+	// MessageID, if set, is used verbatim as the Message-ID header value
+	// instead of generating a fresh one - so a caller that needs to know
+	// the ID ahead of time (to look the message up in Mailpit afterwards)
+	// can assign it before calling Build.
+	MessageID string
+
+	// BaseDir/ReqPath let Build resolve relative asset and attachment
+	// paths against the template's own directory.
+	BaseDir string
+	ReqPath string
+}
+
+// EnvelopeFromAddress is the address to use as SMTP MAIL FROM: the
+// explicit EnvelopeFrom if set (data.yaml's "envelope from"), else the
+// address portion of From - kept distinct from the From header itself.
+func (m EmailMessage) EnvelopeFromAddress() string {
+	if m.EnvelopeFrom != "" {
+		return extractEmailAddress(m.EnvelopeFrom)
+	}
+	return extractEmailAddress(m.From)
+}
+
+// Build renders m as an RFC 5322 message: multipart/alternative with an
+// auto-generated (or explicit) plaintext part alongside the HTML, any
+// assets/ images the HTML references inlined as cid: parts, any
+// Attachments appended as regular attachments, and Message-ID/Date/RFC
+// 2047-encoded Subject headers set automatically.
+func (m EmailMessage) Build() (string, error) {
+	htmlBody, inline, err := embedInlineImages(m.HTMLBody, m.BaseDir, m.ReqPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to embed inline images: %w", err)
+	}
+
+	text := m.Text
+	if text == "" {
+		text, err = htmlToText(htmlBody)
+		if err != nil {
+			return "", fmt.Errorf("failed to derive a plaintext alternative: %w", err)
+		}
+	}
+
+	bodyContentType, body, err := composeMultipart("multipart/alternative", func(w *multipart.Writer) error {
+		if err := writeQuotedPrintablePart(w, "text/plain; charset=UTF-8", text); err != nil {
+			return err
+		}
+		return writeQuotedPrintablePart(w, "text/html; charset=UTF-8", htmlBody)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(inline) > 0 {
+		bodyContentType, body, err = composeMultipart("multipart/related", func(w *multipart.Writer) error {
+			if err := writeRawPart(w, bodyContentType, body); err != nil {
+				return err
+			}
+			for _, img := range inline {
+				if err := writeBase64Part(w, textproto.MIMEHeader{
+					"Content-Type":              {img.contentType},
+					"Content-ID":                {"<" + img.cid + ">"},
+					"Content-Disposition":       {"inline; filename=" + strconv.Quote(img.filename)},
+					"Content-Transfer-Encoding": {"base64"},
+				}, img.data); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(m.Attachments) > 0 {
+		bodyContentType, body, err = composeMultipart("multipart/mixed", func(w *multipart.Writer) error {
+			if err := writeRawPart(w, bodyContentType, body); err != nil {
+				return err
+			}
+			for _, ref := range m.Attachments {
+				path, err := resolveLocalThenGlobal(m.BaseDir, m.ReqPath, ref)
+				if err != nil {
+					return fmt.Errorf("attachment %q: %w", ref, err)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("attachment %q: %w", ref, err)
+				}
+				filename := filepath.Base(ref)
+				contentType := mime.TypeByExtension(filepath.Ext(filename))
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				if err := writeBase64Part(w, textproto.MIMEHeader{
+					"Content-Type":              {contentType},
+					"Content-Disposition":       {"attachment; filename=" + strconv.Quote(filename)},
+					"Content-Transfer-Encoding": {"base64"},
+				}, data); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	msgID := m.MessageID
+	if msgID == "" {
+		var err error
+		msgID, err = newMessageID()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", m.To)
+	if m.ReplyTo != "" {
+		fmt.Fprintf(&msg, "Reply-To: %s\r\n", m.ReplyTo)
+	}
+	for k, v := range m.Headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", k, v)
+	}
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", m.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: %s\r\n", msgID)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s\r\n", bodyContentType)
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+
+	return msg.String(), nil
+}
+
+// newMessageID returns a Message-ID header value, "<uuid@phishingclub>".
+func newMessageID() (string, error) {
+	id, err := newUUID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate Message-ID: %w", err)
+	}
+	return "<" + id + "@phishingclub>", nil
+}
+
+// composeMultipart writes a multipart body of the given subtype via
+// build, returning a ready-to-use "subtype; boundary=..." Content-Type
+// header value alongside the rendered body.
+func composeMultipart(subtype string, build func(w *multipart.Writer) error) (contentType, body string, err error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := build(w); err != nil {
+		return "", "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%s; boundary=%q", subtype, w.Boundary()), buf.String(), nil
+}
+
+func writeQuotedPrintablePart(w *multipart.Writer, contentType, content string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeBase64Part(w *multipart.Writer, header textproto.MIMEHeader, data []byte) error {
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	const lineLength = 76
+	for i := 0; i < len(encoded); i += lineLength {
+		end := i + lineLength
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		if _, err := part.Write([]byte(encoded[i:end] + "\r\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRawPart(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write([]byte(body))
+	return err
+}
+
+// inlineImage is one <img> embedded as a cid: part by embedInlineImages.
+type inlineImage struct {
+	cid         string
+	contentType string
+	filename    string
+	data        []byte
+}
+
+// embedInlineImages rewrites every <img src="..."> embedInlineImages can
+// resolve to a file under baseDir into a "cid:" reference, returning the
+// rewritten HTML and the inline parts to attach alongside it. Sources it
+// can't resolve locally (absolute http(s) URLs, data: URIs, an unresolvable
+// relative path) are left untouched.
+func embedInlineImages(htmlBody, baseDir, reqPath string) (string, []inlineImage, error) {
+	z := xhtml.NewTokenizer(strings.NewReader(htmlBody))
+	var out strings.Builder
+	var inline []inlineImage
+
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return "", nil, err
+			}
+			break
+		}
+
+		if tt != xhtml.StartTagToken && tt != xhtml.SelfClosingTagToken {
+			out.WriteString(string(z.Raw()))
+			continue
+		}
+
+		tok := z.Token()
+		if tok.Data != "img" {
+			out.WriteString(tok.String())
+			continue
+		}
+
+		for i, attr := range tok.Attr {
+			if attr.Key != "src" {
+				continue
+			}
+			path, err := resolveInlineAssetPath(baseDir, reqPath, attr.Val)
+			if err != nil {
+				continue // unresolvable/external source - leave it as-is
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			cid := fmt.Sprintf("asset%d@phishingclub", len(inline))
+			contentType := mime.TypeByExtension(filepath.Ext(path))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			inline = append(inline, inlineImage{
+				cid:         cid,
+				contentType: contentType,
+				filename:    filepath.Base(path),
+				data:        data,
+			})
+			tok.Attr[i].Val = "cid:" + cid
+		}
+		out.WriteString(tok.String())
+	}
+
+	return out.String(), inline, nil
+}
+
+// resolveInlineAssetPath resolves an <img src> value to a local file path,
+// the same local-then-global precedence resolveAssetAttr uses: the
+// template's own BaseURL-rewritten /templates/assets/ path, or a relative
+// path against the template's own directory falling back to the shared
+// assets/ directory. Absolute external URLs and data: URIs are rejected.
+func resolveInlineAssetPath(baseDir, reqPath, rawSrc string) (string, error) {
+	if rawSrc == "" || strings.HasPrefix(rawSrc, "data:") || strings.HasPrefix(rawSrc, "cid:") {
+		return "", fmt.Errorf("not a local asset")
+	}
+
+	u, err := url.Parse(rawSrc)
+	if err != nil {
+		return "", err
+	}
+
+	if idx := strings.Index(u.Path, "/templates/assets/"); idx != -1 {
+		rel := strings.TrimPrefix(u.Path[idx:], "/templates/assets/")
+		path := filepath.Join(baseDir, "assets", filepath.FromSlash(rel))
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+
+	if u.Scheme != "" || u.Host != "" {
+		return "", fmt.Errorf("not a local asset")
+	}
+
+	return resolveLocalThenGlobal(baseDir, reqPath, u.Path)
+}
+
+// resolveLocalThenGlobal resolves ref against the template's own directory
+// first, falling back to the shared top-level assets/ directory - the same
+// precedence proven by TestProcessTemplateContentAssetFallback.
+func resolveLocalThenGlobal(baseDir, reqPath, ref string) (string, error) {
+	local := filepath.Join(baseDir, filepath.Dir(reqPath), filepath.FromSlash(ref))
+	if _, err := os.Stat(local); err == nil {
+		return local, nil
+	}
+
+	global := filepath.Join(baseDir, "assets", filepath.FromSlash(ref))
+	if _, err := os.Stat(global); err == nil {
+		return global, nil
+	}
+
+	return "", fmt.Errorf("not found in %s or the shared assets/ directory", filepath.Dir(reqPath))
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmailMessageBuildMultipartAlternativeWithAutoText(t *testing.T) {
+	msg := EmailMessage{
+		From:     "Alice <alice@example.com>",
+		To:       "bob@example.com",
+		Subject:  "Hello",
+		HTMLBody: "<p>Hi Bob</p>",
+	}
+
+	raw, err := msg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if !strings.Contains(raw, "multipart/alternative") {
+		t.Errorf("expected a multipart/alternative body, got %q", raw)
+	}
+	if !strings.Contains(raw, "Hi Bob") {
+		t.Errorf("expected the derived plaintext alternative to contain the body text, got %q", raw)
+	}
+	if !strings.Contains(raw, "<p>Hi Bob</p>") {
+		t.Errorf("expected the HTML part to be present, got %q", raw)
+	}
+	if !strings.Contains(raw, "Message-ID: <") {
+		t.Errorf("expected a Message-ID header, got %q", raw)
+	}
+	if !strings.Contains(raw, "Subject: Hello\r\n") {
+		t.Errorf("expected a plain ASCII subject to pass through unencoded, got %q", raw)
+	}
+}
+
+func TestEmailMessageBuildEncodesNonASCIISubject(t *testing.T) {
+	msg := EmailMessage{
+		From:     "alice@example.com",
+		To:       "bob@example.com",
+		Subject:  "Résumé required",
+		HTMLBody: "<p>Hi</p>",
+	}
+
+	raw, err := msg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !strings.Contains(raw, "Subject: =?UTF-8?") {
+		t.Errorf("expected an RFC 2047 encoded subject, got %q", raw)
+	}
+}
+
+func TestEmailMessageBuildInlinesLocalImages(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := filepath.Join(tmpDir, "test-dir")
+	if err := os.Mkdir(testDir, 0755); err != nil {
+		t.Fatalf("Failed to create test dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "logo.png"), []byte("fake-png-bytes"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	msg := EmailMessage{
+		From:     "alice@example.com",
+		To:       "bob@example.com",
+		Subject:  "Logo",
+		HTMLBody: `<body><img src="logo.png"></body>`,
+		BaseDir:  tmpDir,
+		ReqPath:  "test-dir/page.html",
+	}
+
+	raw, err := msg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !strings.Contains(raw, "multipart/related") {
+		t.Errorf("expected a multipart/related wrapper for inline images, got %q", raw)
+	}
+	// The HTML part is quoted-printable encoded, so "=" becomes "=3D" and
+	// src="cid: is split across the "=" - check for the cid: reference alone.
+	if !strings.Contains(raw, `cid:asset0@phishingclub`) {
+		t.Errorf("expected the <img src> to be rewritten to a cid: reference, got %q", raw)
+	}
+	if !strings.Contains(raw, "Content-ID: <") {
+		t.Errorf("expected a Content-ID header for the inlined image, got %q", raw)
+	}
+}
+
+func TestEmailMessageBuildAddsAttachments(t *testing.T) {
+	tmpDir := t.TempDir()
+	testDir := filepath.Join(tmpDir, "test-dir")
+	if err := os.Mkdir(testDir, 0755); err != nil {
+		t.Fatalf("Failed to create test dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "invoice.pdf"), []byte("%PDF-fake"), 0644); err != nil {
+		t.Fatalf("Failed to write invoice.pdf: %v", err)
+	}
+
+	msg := EmailMessage{
+		From:        "alice@example.com",
+		To:          "bob@example.com",
+		Subject:     "Invoice",
+		HTMLBody:    "<p>See attached</p>",
+		Attachments: []string{"invoice.pdf"},
+		BaseDir:     tmpDir,
+		ReqPath:     "test-dir/page.html",
+	}
+
+	raw, err := msg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !strings.Contains(raw, "multipart/mixed") {
+		t.Errorf("expected a multipart/mixed wrapper for attachments, got %q", raw)
+	}
+	if !strings.Contains(raw, `filename="invoice.pdf"`) {
+		t.Errorf("expected the attachment filename to be present, got %q", raw)
+	}
+}
+
+func TestEmailMessageEnvelopeFromFallsBackToFromAddress(t *testing.T) {
+	msg := EmailMessage{From: "Alice <alice@example.com>"}
+	if got := msg.EnvelopeFromAddress(); got != "alice@example.com" {
+		t.Errorf("EnvelopeFromAddress() = %q, want alice@example.com", got)
+	}
+
+	msg.EnvelopeFrom = "bounces@example.com"
+	if got := msg.EnvelopeFromAddress(); got != "bounces@example.com" {
+		t.Errorf("EnvelopeFromAddress() = %q, want the explicit EnvelopeFrom", got)
+	}
+}
+
+func TestEmailMessageBuildProducesParseableMessage(t *testing.T) {
+	msg := EmailMessage{
+		From:     "alice@example.com",
+		To:       "bob@example.com",
+		Subject:  "Hello",
+		HTMLBody: "<p>Hi</p>",
+		ReplyTo:  "support@example.com",
+		Headers:  map[string]string{"X-Campaign-ID": "42"},
+	}
+
+	raw, err := msg.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("the built message did not parse as a valid RFC 5322 message: %v", err)
+	}
+	if got := parsed.Header.Get("Reply-To"); got != "support@example.com" {
+		t.Errorf("Reply-To header = %q, want support@example.com", got)
+	}
+	if got := parsed.Header.Get("X-Campaign-ID"); got != "42" {
+		t.Errorf("X-Campaign-ID header = %q, want 42", got)
+	}
+}
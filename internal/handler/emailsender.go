@@ -0,0 +1,403 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// EmailSender is the send path SendTestEmailHandler goes through: something
+// that can deliver an HTML email, whether that's a real SMTP relay, a
+// transactional provider's API, the local sendmail binary, or (for tests
+// and offline development) nothing at all. Matching the EMAIL_BACKEND
+// switch used by projects like dex and jfa-go, EmailSenderFromEnv picks the
+// implementation so deployments can point at whatever they actually use
+// without recompiling.
+type EmailSender interface {
+	Send(from, to, subject, htmlBody string) error
+}
+
+// RichEmailSender is an optional, additional interface a backend can
+// implement to send a fully-built EmailMessage (plaintext alternative,
+// inline images, attachments, extra headers) instead of a bare
+// from/to/subject/html tuple. SendTestEmailHandler uses it when the
+// configured backend supports it, falling back to plain Send otherwise -
+// added this way, rather than widening EmailSender itself, so backends
+// that only ever need the simple case aren't forced to implement more.
+type RichEmailSender interface {
+	SendMessage(msg EmailMessage) error
+}
+
+// EmailSenderFromEnv builds the EmailSender EMAIL_BACKEND selects: "smtp"
+// (the default, preserving this package's original Mailpit-in-Docker
+// behavior when no other SMTP_* variables are set), "mailgun", "sendmail",
+// or "fake".
+func EmailSenderFromEnv() (EmailSender, error) {
+	switch backend := strings.ToLower(os.Getenv("EMAIL_BACKEND")); backend {
+	case "", "smtp":
+		return &SMTPSender{
+			Host:     envOrDefault("SMTP_HOST", "mailer"),
+			Port:     envOrDefault("SMTP_PORT", "1025"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			AuthMode: SMTPAuthMode(envOrDefault("SMTP_AUTH", "none")),
+			TLSMode:  SMTPTLSMode(envOrDefault("SMTP_TLS", "none")),
+		}, nil
+	case "mailgun":
+		apiKey := os.Getenv("MAILGUN_API_KEY")
+		domain := os.Getenv("MAILGUN_DOMAIN")
+		if apiKey == "" || domain == "" {
+			return nil, fmt.Errorf("EMAIL_BACKEND=mailgun requires MAILGUN_API_KEY and MAILGUN_DOMAIN")
+		}
+		return &MailgunSender{
+			APIKey:  apiKey,
+			Domain:  domain,
+			BaseURL: envOrDefault("MAILGUN_BASE_URL", "https://api.mailgun.net/v3"),
+		}, nil
+	case "sendmail":
+		return &SendmailSender{Path: envOrDefault("SENDMAIL_PATH", "sendmail")}, nil
+	case "fake":
+		return &FakeEmailSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown EMAIL_BACKEND %q (want smtp, mailgun, sendmail, or fake)", backend)
+	}
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// buildEmailMessage renders from/to/subject/htmlBody as an RFC 5322
+// message, shared by every backend that speaks raw SMTP-style messages
+// (SMTPSender and SendmailSender).
+func buildEmailMessage(from, to, subject, htmlBody string) string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(htmlBody)
+	return msg.String()
+}
+
+// SMTPAuthMode selects how SMTPSender authenticates, if at all.
+type SMTPAuthMode string
+
+const (
+	SMTPAuthNone    SMTPAuthMode = "none"
+	SMTPAuthPlain   SMTPAuthMode = "plain"
+	SMTPAuthLogin   SMTPAuthMode = "login"
+	SMTPAuthCRAMMD5 SMTPAuthMode = "crammd5"
+)
+
+// SMTPTLSMode selects how SMTPSender secures its connection.
+type SMTPTLSMode string
+
+const (
+	// SMTPTLSNone speaks plaintext SMTP - the right choice for a local
+	// Mailpit instance, wrong for anything reachable over a real network.
+	SMTPTLSNone SMTPTLSMode = "none"
+	// SMTPTLSStartTLS upgrades a plaintext connection via STARTTLS before
+	// authenticating or sending, failing if the server doesn't offer it.
+	SMTPTLSStartTLS SMTPTLSMode = "starttls"
+	// SMTPTLSTLS dials straight over TLS (the "implicit TLS" / smtps
+	// convention, typically port 465).
+	SMTPTLSTLS SMTPTLSMode = "tls"
+)
+
+// SMTPSender delivers mail over SMTP with a configurable auth mode, TLS
+// mode, and host/port/credentials - the generalized replacement for the
+// hard-coded, unauthenticated plaintext connection to Mailpit this package
+// used to make. Certificates are verified normally in both TLS modes;
+// nothing here ever sets InsecureSkipVerify.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	AuthMode SMTPAuthMode
+	TLSMode  SMTPTLSMode
+}
+
+func (s *SMTPSender) auth() smtp.Auth {
+	switch s.AuthMode {
+	case SMTPAuthPlain:
+		return smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	case SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(s.Username, s.Password)
+	case SMTPAuthLogin:
+		return &loginAuth{username: s.Username, password: s.Password}
+	default:
+		return nil
+	}
+}
+
+// Send implements EmailSender.
+func (s *SMTPSender) Send(from, to, subject, htmlBody string) error {
+	return s.sendRaw(extractEmailAddress(from), to, buildEmailMessage(from, to, subject, htmlBody))
+}
+
+// SendMessage implements RichEmailSender.
+func (s *SMTPSender) SendMessage(msg EmailMessage) error {
+	raw, err := msg.Build()
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	return s.sendRaw(msg.EnvelopeFromAddress(), msg.To, raw)
+}
+
+// sendRaw delivers a pre-built RFC 5322 message, shared by Send and
+// SendMessage so the connection/auth/TLS handling only lives in one place.
+func (s *SMTPSender) sendRaw(envelopeFrom, to, rawMessage string) error {
+	addr := net.JoinHostPort(s.Host, s.Port)
+
+	var client *smtp.Client
+	if s.TLSMode == SMTPTLSTLS {
+		tlsConn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: s.Host})
+		if err != nil {
+			return fmt.Errorf("failed to dial SMTP server over TLS: %w", err)
+		}
+		client, err = smtp.NewClient(tlsConn, s.Host)
+		if err != nil {
+			return fmt.Errorf("failed to start SMTP session: %w", err)
+		}
+	} else {
+		var err error
+		client, err = smtp.Dial(addr)
+		if err != nil {
+			return fmt.Errorf("failed to connect to SMTP server: %w", err)
+		}
+	}
+	defer client.Close()
+
+	if s.TLSMode == SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	if auth := s.auth(); auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(envelopeFrom); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("failed to set recipient: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start data transfer: %w", err)
+	}
+	if _, err := io.WriteString(w, rawMessage); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to close data transfer: %w", err)
+	}
+	return client.Quit()
+}
+
+// loginAuth implements the AUTH LOGIN mechanism, which net/smtp doesn't
+// provide a helper for (only PLAIN and CRAM-MD5 ship built in).
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(_ *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSuffix(string(fromServer), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected AUTH LOGIN challenge: %q", fromServer)
+	}
+}
+
+// MailgunSender delivers mail via Mailgun's HTTP API instead of SMTP.
+type MailgunSender struct {
+	APIKey  string
+	Domain  string
+	BaseURL string // e.g. https://api.mailgun.net/v3, or the EU region's base
+}
+
+// Send implements EmailSender.
+func (m *MailgunSender) Send(from, to, subject, htmlBody string) error {
+	form := url.Values{}
+	form.Set("from", from)
+	form.Set("to", to)
+	form.Set("subject", subject)
+	form.Set("html", htmlBody)
+
+	return m.post(strings.NewReader(form.Encode()), "application/x-www-form-urlencoded")
+}
+
+// SendMessage implements RichEmailSender, using Mailgun's native "text",
+// "attachment", and "h:"-prefixed header fields rather than handing it a
+// pre-built raw MIME message.
+func (m *MailgunSender) SendMessage(msg EmailMessage) error {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	fields := map[string]string{
+		"from":    msg.From,
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"html":    msg.HTMLBody,
+	}
+	if msg.EnvelopeFrom != "" {
+		fields["h:Sender"] = msg.EnvelopeFromAddress()
+	}
+	if msg.ReplyTo != "" {
+		fields["h:Reply-To"] = msg.ReplyTo
+	}
+	if msg.Text != "" {
+		fields["text"] = msg.Text
+	}
+	for k, v := range msg.Headers {
+		fields["h:"+k] = v
+	}
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return fmt.Errorf("failed to build Mailgun request: %w", err)
+		}
+	}
+
+	for _, ref := range msg.Attachments {
+		path, err := resolveLocalThenGlobal(msg.BaseDir, msg.ReqPath, ref)
+		if err != nil {
+			return fmt.Errorf("attachment %q: %w", ref, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("attachment %q: %w", ref, err)
+		}
+		part, err := w.CreateFormFile("attachment", filepath.Base(ref))
+		if err != nil {
+			return fmt.Errorf("failed to build Mailgun request: %w", err)
+		}
+		if _, err := part.Write(data); err != nil {
+			return fmt.Errorf("failed to build Mailgun request: %w", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to build Mailgun request: %w", err)
+	}
+
+	return m.post(&buf, w.FormDataContentType())
+}
+
+func (m *MailgunSender) post(body io.Reader, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(m.BaseURL, "/")+"/"+m.Domain+"/messages", body)
+	if err != nil {
+		return fmt.Errorf("failed to build Mailgun request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.SetBasicAuth("api", m.APIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Mailgun: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mailgun returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// SendmailSender delivers mail by piping an RFC 5322 message to the local
+// sendmail(1) binary - the usual escape hatch on hosts that already have
+// an MTA configured and don't want this package to know about it.
+type SendmailSender struct {
+	Path string // binary name or path; defaults to "sendmail" on $PATH
+}
+
+// Send implements EmailSender.
+func (s *SendmailSender) Send(from, to, subject, htmlBody string) error {
+	return s.sendRaw(extractEmailAddress(from), buildEmailMessage(from, to, subject, htmlBody))
+}
+
+// SendMessage implements RichEmailSender.
+func (s *SendmailSender) SendMessage(msg EmailMessage) error {
+	raw, err := msg.Build()
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	return s.sendRaw(msg.EnvelopeFromAddress(), raw)
+}
+
+// sendRaw pipes a pre-built RFC 5322 message to the sendmail binary.
+func (s *SendmailSender) sendRaw(envelopeFrom, rawMessage string) error {
+	path := s.Path
+	if path == "" {
+		path = "sendmail"
+	}
+
+	cmd := exec.Command(path, "-t", "-f", envelopeFrom)
+	cmd.Stdin = strings.NewReader(rawMessage)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sendmail failed: %w (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// FakeSentEmail is one message FakeEmailSender recorded instead of sending.
+type FakeSentEmail struct {
+	From, To, Subject, HTMLBody string
+}
+
+// FakeEmailSender records every Send call instead of delivering anything -
+// EMAIL_BACKEND=fake, for tests and offline development.
+type FakeEmailSender struct {
+	Sent         []FakeSentEmail
+	SentMessages []EmailMessage
+}
+
+// Send implements EmailSender.
+func (f *FakeEmailSender) Send(from, to, subject, htmlBody string) error {
+	f.Sent = append(f.Sent, FakeSentEmail{From: from, To: to, Subject: subject, HTMLBody: htmlBody})
+	return nil
+}
+
+// SendMessage implements RichEmailSender.
+func (f *FakeEmailSender) SendMessage(msg EmailMessage) error {
+	f.SentMessages = append(f.SentMessages, msg)
+	return nil
+}
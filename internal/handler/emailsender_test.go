@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmailSenderFromEnvDefaultsToSMTP(t *testing.T) {
+	t.Setenv("EMAIL_BACKEND", "")
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("SMTP_PORT", "")
+
+	sender, err := EmailSenderFromEnv()
+	if err != nil {
+		t.Fatalf("EmailSenderFromEnv() error = %v", err)
+	}
+	smtpSender, ok := sender.(*SMTPSender)
+	if !ok {
+		t.Fatalf("expected a *SMTPSender, got %T", sender)
+	}
+	if smtpSender.Host != "mailer" || smtpSender.Port != "1025" {
+		t.Errorf("expected the original Mailpit defaults, got host=%q port=%q", smtpSender.Host, smtpSender.Port)
+	}
+	if smtpSender.AuthMode != SMTPAuthNone || smtpSender.TLSMode != SMTPTLSNone {
+		t.Errorf("expected no auth/TLS by default, got auth=%q tls=%q", smtpSender.AuthMode, smtpSender.TLSMode)
+	}
+}
+
+func TestEmailSenderFromEnvSMTPReadsOverrides(t *testing.T) {
+	t.Setenv("EMAIL_BACKEND", "smtp")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_PORT", "587")
+	t.Setenv("SMTP_USERNAME", "alice")
+	t.Setenv("SMTP_PASSWORD", "hunter2")
+	t.Setenv("SMTP_AUTH", "login")
+	t.Setenv("SMTP_TLS", "starttls")
+
+	sender, err := EmailSenderFromEnv()
+	if err != nil {
+		t.Fatalf("EmailSenderFromEnv() error = %v", err)
+	}
+	smtpSender := sender.(*SMTPSender)
+	if smtpSender.Host != "smtp.example.com" || smtpSender.Port != "587" {
+		t.Errorf("unexpected host/port: %q %q", smtpSender.Host, smtpSender.Port)
+	}
+	if smtpSender.AuthMode != SMTPAuthLogin || smtpSender.TLSMode != SMTPTLSStartTLS {
+		t.Errorf("unexpected auth/TLS mode: %q %q", smtpSender.AuthMode, smtpSender.TLSMode)
+	}
+}
+
+func TestEmailSenderFromEnvFake(t *testing.T) {
+	t.Setenv("EMAIL_BACKEND", "fake")
+
+	sender, err := EmailSenderFromEnv()
+	if err != nil {
+		t.Fatalf("EmailSenderFromEnv() error = %v", err)
+	}
+	fake, ok := sender.(*FakeEmailSender)
+	if !ok {
+		t.Fatalf("expected a *FakeEmailSender, got %T", sender)
+	}
+	if err := fake.Send("a@example.com", "b@example.com", "Hi", "<p>Hi</p>"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(fake.Sent) != 1 || fake.Sent[0].Subject != "Hi" {
+		t.Errorf("expected the message to be recorded, got %+v", fake.Sent)
+	}
+}
+
+func TestFakeEmailSenderSendMessageImplementsRichEmailSender(t *testing.T) {
+	fake := &FakeEmailSender{}
+	var sender EmailSender = fake
+
+	rich, ok := sender.(RichEmailSender)
+	if !ok {
+		t.Fatalf("expected *FakeEmailSender to implement RichEmailSender")
+	}
+
+	msg := EmailMessage{From: "a@example.com", To: "b@example.com", Subject: "Hi", HTMLBody: "<p>Hi</p>"}
+	if err := rich.SendMessage(msg); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if len(fake.SentMessages) != 1 || fake.SentMessages[0].Subject != "Hi" {
+		t.Errorf("expected the message to be recorded, got %+v", fake.SentMessages)
+	}
+}
+
+func TestEmailSenderFromEnvMailgunRequiresCredentials(t *testing.T) {
+	t.Setenv("EMAIL_BACKEND", "mailgun")
+	t.Setenv("MAILGUN_API_KEY", "")
+	t.Setenv("MAILGUN_DOMAIN", "")
+
+	if _, err := EmailSenderFromEnv(); err == nil {
+		t.Error("expected an error when MAILGUN_API_KEY/MAILGUN_DOMAIN are unset")
+	}
+}
+
+func TestEmailSenderFromEnvUnknownBackend(t *testing.T) {
+	t.Setenv("EMAIL_BACKEND", "carrier-pigeon")
+
+	if _, err := EmailSenderFromEnv(); err == nil {
+		t.Error("expected an error for an unrecognized EMAIL_BACKEND")
+	}
+}
+
+func TestBuildEmailMessageIncludesHeadersAndBody(t *testing.T) {
+	msg := buildEmailMessage("Alice <alice@example.com>", "bob@example.com", "Hello", "<p>Hi Bob</p>")
+	if !strings.Contains(msg, "From: Alice <alice@example.com>\r\n") {
+		t.Errorf("expected a From header, got %q", msg)
+	}
+	if !strings.Contains(msg, "Subject: Hello\r\n") {
+		t.Errorf("expected a Subject header, got %q", msg)
+	}
+	if !strings.Contains(msg, "<p>Hi Bob</p>") {
+		t.Errorf("expected the HTML body, got %q", msg)
+	}
+}
+
+func TestLoginAuthRespondsToUsernameAndPasswordChallenges(t *testing.T) {
+	auth := &loginAuth{username: "alice", password: "hunter2"}
+
+	if _, _, err := auth.Start(nil); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	resp, err := auth.Next([]byte("Username:"), true)
+	if err != nil || string(resp) != "alice" {
+		t.Errorf("Next(Username:) = %q, %v", resp, err)
+	}
+	resp, err = auth.Next([]byte("Password:"), true)
+	if err != nil || string(resp) != "hunter2" {
+		t.Errorf("Next(Password:) = %q, %v", resp, err)
+	}
+	if _, err := auth.Next([]byte("Nonsense:"), true); err == nil {
+		t.Error("expected an error for an unrecognized challenge")
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exporter builds a filtered, manifested, optionally signed export archive
+// from a Store, using the same ArchiveWriter/ExportFilter/Manifest
+// machinery ExportHandler serves over HTTP - the CLI's -export flag (see
+// performExport in main) is the other caller.
+type Exporter struct {
+	Store  Store
+	Format ArchiveFormat
+	Filter *ExportFilter
+
+	// SigningKey, if set, is used by Export to produce a detached Ed25519
+	// signature of manifest.json.
+	SigningKey ed25519.PrivateKey
+}
+
+// NewExporter returns an Exporter over store in format, with includes/
+// excludes (gitignore-style globs, or "re:"-prefixed regexes - see
+// newMatchPattern) building its filter the same way parseExportFilter does
+// for an HTTP request's include=/exclude= query parameters.
+func NewExporter(store Store, format ArchiveFormat, includes, excludes []string) (*Exporter, error) {
+	filter, err := newExportFilter(includes, excludes, store)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{Store: store, Format: format, Filter: filter}, nil
+}
+
+// LoadSigningKey reads a base64-encoded Ed25519 seed from path and sets it
+// as e.SigningKey.
+func (e *Exporter) LoadSigningKey(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read signing key %q: %w", path, err)
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return fmt.Errorf("signing key %q is not valid base64: %w", path, err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("signing key %q must be a base64-encoded %d-byte Ed25519 seed, got %d bytes", path, ed25519.SeedSize, len(seed))
+	}
+	e.SigningKey = ed25519.NewKeyFromSeed(seed)
+	return nil
+}
+
+// Export builds the manifest for e.Filter and writes the archive in
+// e.Format to archiveW. If e.SigningKey is set, it also writes a detached
+// base64 Ed25519 signature of manifest.json to sigW, which must be non-nil
+// in that case.
+func (e *Exporter) Export(archiveW io.Writer, sigW io.Writer) (*Manifest, error) {
+	manifest, err := buildExportManifest(e.Store, e.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("error building manifest: %w", err)
+	}
+
+	if err := writeExportArchive(archiveW, e.Format, e.Store, manifest); err != nil {
+		return nil, fmt.Errorf("error creating archive: %w", err)
+	}
+
+	if e.SigningKey != nil {
+		if sigW == nil {
+			return manifest, fmt.Errorf("a signing key is set but no signature destination was given")
+		}
+		manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return manifest, err
+		}
+		sig := ed25519.Sign(e.SigningKey, manifestJSON)
+		if _, err := io.WriteString(sigW, base64.StdEncoding.EncodeToString(sig)); err != nil {
+			return manifest, fmt.Errorf("error writing manifest signature: %w", err)
+		}
+	}
+
+	return manifest, nil
+}
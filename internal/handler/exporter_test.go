@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewExporterFiltersAndArchives(t *testing.T) {
+	store := newManifestTestStore(t)
+
+	exporter, err := NewExporter(store, FormatZip, []string{"assets/*"}, nil)
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+
+	var archive bytes.Buffer
+	manifest, err := exporter.Export(&archive, nil)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if manifest.SchemaVersion != manifestSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", manifest.SchemaVersion, manifestSchemaVersion)
+	}
+	if len(manifest.Entries) != 1 || manifest.Entries[0].Path != "assets/logo.png" {
+		t.Errorf("expected only assets/logo.png, got %+v", manifest.Entries)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("expected a valid zip archive: %v", err)
+	}
+	if _, err := zr.Open("manifest.json"); err != nil {
+		t.Errorf("expected manifest.json in the archive: %v", err)
+	}
+	if _, err := zr.Open("assets/logo.png"); err != nil {
+		t.Errorf("expected assets/logo.png in the archive: %v", err)
+	}
+}
+
+func TestExporterLoadSigningKeySignsManifest(t *testing.T) {
+	store := newManifestTestStore(t)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey failed: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "export.key")
+	seed := base64.StdEncoding.EncodeToString(priv.Seed())
+	if err := os.WriteFile(keyPath, []byte(seed), 0600); err != nil {
+		t.Fatalf("Failed to write signing key: %v", err)
+	}
+
+	exporter, err := NewExporter(store, FormatZip, nil, nil)
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+	if err := exporter.LoadSigningKey(keyPath); err != nil {
+		t.Fatalf("LoadSigningKey failed: %v", err)
+	}
+
+	var archive, sig bytes.Buffer
+	manifest, err := exporter.Export(&archive, &sig)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal manifest: %v", err)
+	}
+
+	sigBytes, err := base64.StdEncoding.DecodeString(sig.String())
+	if err != nil {
+		t.Fatalf("Failed to decode signature: %v", err)
+	}
+	if !ed25519.Verify(pub, manifestJSON, sigBytes) {
+		t.Error("expected the signature to verify against the exported manifest")
+	}
+}
+
+func TestExporterExportWithSigningKeyRequiresSigWriter(t *testing.T) {
+	store := newManifestTestStore(t)
+
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey failed: %v", err)
+	}
+
+	exporter, err := NewExporter(store, FormatZip, nil, nil)
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+	exporter.SigningKey = priv
+
+	var archive bytes.Buffer
+	if _, err := exporter.Export(&archive, nil); err == nil {
+		t.Error("expected an error when a signing key is set but no signature destination is given")
+	}
+}
+
+func TestExporterLoadSigningKeyRejectsInvalidSeed(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "export.key")
+	if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString([]byte("too-short"))), 0600); err != nil {
+		t.Fatalf("Failed to write signing key: %v", err)
+	}
+
+	exporter := &Exporter{}
+	if err := exporter.LoadSigningKey(keyPath); err == nil {
+		t.Error("expected an error for a seed of the wrong length")
+	}
+}
@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// templateIgnoreFile is the repo-level ignore file, checked for at the root
+// of a Store and honored by ExportHandler/DownloadHandler in addition to any
+// include/exclude query parameters. A .templateignore placed deeper in the
+// tree (loaded via ignoreStack) applies the same way, scoped to its own
+// subtree.
+const templateIgnoreFile = ".templateignore"
+
+// matchPattern is a single include/exclude/ignore pattern: either a
+// gitignore-style glob, or a regular expression when prefixed with "re:".
+type matchPattern struct {
+	raw    string
+	negate bool
+
+	// caseInsensitive is only set by defaultIgnorePatterns - .templateignore
+	// itself is matched case-sensitively, same as a real .gitignore.
+	caseInsensitive bool
+
+	regex   *regexp.Regexp // set for "re:"-prefixed patterns
+	glob    *regexp.Regexp // set otherwise, compiled by compileGlob
+	dirOnly bool           // raw ended in "/": only matches directories
+}
+
+// newMatchPattern parses raw as a gitignore-style glob, or as a regular
+// expression if it starts with "re:".
+func newMatchPattern(raw string) (matchPattern, error) {
+	if rest, ok := strings.CutPrefix(raw, "re:"); ok {
+		re, err := regexp.Compile(rest)
+		if err != nil {
+			return matchPattern{}, err
+		}
+		return matchPattern{raw: raw, regex: re}, nil
+	}
+
+	dirOnly := strings.HasSuffix(raw, "/")
+	glob, err := compileGlob(strings.TrimSuffix(raw, "/"))
+	if err != nil {
+		return matchPattern{}, err
+	}
+	return matchPattern{raw: raw, glob: glob, dirOnly: dirOnly}, nil
+}
+
+// compileGlob converts a gitignore-style glob into a regular expression
+// matched against a "/"-separated relative path. "*" and "?" match within a
+// single path segment; "**" matches any number of segments, including none.
+// A pattern with no "/" of its own (once a trailing one is stripped) matches
+// at any depth, as if it had been written "**/pattern" - the same rule a
+// real .gitignore uses for a bare name like "private".
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 3
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			i++
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// match reports whether relPath (always "/"-separated) matches the pattern.
+// isDir must be true for relPath to satisfy a directory-only ("trailing /")
+// pattern.
+func (p matchPattern) match(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.caseInsensitive {
+		relPath = strings.ToLower(relPath)
+	}
+	if p.regex != nil {
+		return p.regex.MatchString(relPath)
+	}
+	return p.glob.MatchString(relPath)
+}
+
+// defaultIgnorePatterns are the patterns every ignoreStack starts with,
+// regardless of whether a .templateignore exists: a single, case-insensitive
+// "private/" rule, preserving the behavior validateCampaigns and
+// addPhishingTemplates used to hardcode before .templateignore could
+// express it as just another pattern.
+func defaultIgnorePatterns() []matchPattern {
+	p, err := newMatchPattern("private/")
+	if err != nil {
+		// "private/" is a fixed, known-valid pattern; this can't happen.
+		panic(err)
+	}
+	p.caseInsensitive = true
+	return []matchPattern{p}
+}
+
+// ExportFilter controls which entries DownloadHandler/ExportHandler include
+// in an archive, combining query-parameter include/exclude patterns with a
+// repo-level .templateignore file. A nil *ExportFilter allows everything.
+type ExportFilter struct {
+	includes []matchPattern
+	excludes []matchPattern
+	ignore   []matchPattern // gitignore-style, "!" negates; last match wins
+
+	// active is true when the caller supplied at least one include or
+	// exclude pattern, so handlers can reflect that in the archive filename.
+	active bool
+}
+
+// parseExportFilter builds an ExportFilter from the request's repeatable
+// `include=` and `exclude=` query parameters (a gitignore-style glob, or a
+// `re:`-prefixed regex) plus store's .templateignore file, if any.
+func parseExportFilter(r *http.Request, store Store) (*ExportFilter, error) {
+	return newExportFilter(r.URL.Query()["include"], r.URL.Query()["exclude"], store)
+}
+
+// newExportFilter builds an ExportFilter from explicit include/exclude
+// pattern lists (each a gitignore-style glob, or a `re:`-prefixed regex)
+// plus store's .templateignore file, if any. It's what parseExportFilter
+// and the CLI's -include/-exclude flags (see Exporter) both build on, so a
+// filtered export behaves identically regardless of which one supplied it.
+func newExportFilter(includes, excludes []string, store Store) (*ExportFilter, error) {
+	f := &ExportFilter{}
+
+	for _, raw := range includes {
+		p, err := newMatchPattern(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", raw, err)
+		}
+		f.includes = append(f.includes, p)
+	}
+	for _, raw := range excludes {
+		p, err := newMatchPattern(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", raw, err)
+		}
+		f.excludes = append(f.excludes, p)
+	}
+	f.active = len(f.includes) > 0 || len(f.excludes) > 0
+
+	ignore, err := loadTemplateIgnore(store, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", templateIgnoreFile, err)
+	}
+	f.ignore = ignore
+
+	return f, nil
+}
+
+// loadTemplateIgnore reads and parses a gitignore-style .templateignore file
+// from dir (store-relative; "." for the root of store). A missing file is
+// not an error: it just means no ignore patterns apply at that level.
+func loadTemplateIgnore(store Store, dir string) ([]matchPattern, error) {
+	data, err := fs.ReadFile(store, path.Join(dir, templateIgnoreFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []matchPattern
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		negate := false
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			negate = true
+			line = rest
+		}
+
+		p, err := newMatchPattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in %s: %w", line, templateIgnoreFile, err)
+		}
+		p.negate = negate
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// excluded reports whether relPath is blocked by .templateignore or an
+// exclude pattern, without considering include patterns. It is used to
+// decide whether to skip a directory entirely (fs.SkipDir) before reading
+// anything underneath it.
+func (f *ExportFilter) excluded(relPath string, isDir bool) bool {
+	if f == nil {
+		return false
+	}
+
+	ignored := false
+	for _, p := range f.ignore {
+		if p.match(relPath, isDir) {
+			ignored = !p.negate
+		}
+	}
+	if ignored {
+		return true
+	}
+
+	for _, p := range f.excludes {
+		if p.match(relPath, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether relPath should be written to the archive: it must
+// not be excluded, and if any include patterns were given, it must match at
+// least one of them.
+func (f *ExportFilter) Allowed(relPath string, isDir bool) bool {
+	if f == nil {
+		return true
+	}
+	if f.excluded(relPath, isDir) {
+		return false
+	}
+	if len(f.includes) == 0 {
+		return true
+	}
+	for _, p := range f.includes {
+		if p.match(relPath, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreLayer is one .templateignore's patterns, scoped to base (a
+// store-relative directory path, "" for the root/default layer).
+type ignoreLayer struct {
+	base     string
+	patterns []matchPattern
+}
+
+// ignoreStack layers .templateignore files encountered during a top-down
+// fs.WalkDir traversal: defaultIgnorePatterns plus the root .templateignore
+// always apply, and each subdirectory's own .templateignore additionally
+// applies to that subtree only, for as long as the walk stays under it -
+// the per-subtree behavior validateCampaigns and addPhishingTemplates need
+// that a single request-scoped ExportFilter doesn't, since they walk the
+// whole store rather than a pre-filtered archive path.
+type ignoreStack struct {
+	store  Store
+	layers []ignoreLayer
+}
+
+// newIgnoreStack seeds an ignoreStack with defaultIgnorePatterns and store's
+// root .templateignore, if any.
+func newIgnoreStack(store Store) (*ignoreStack, error) {
+	root, err := loadTemplateIgnore(store, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", templateIgnoreFile, err)
+	}
+	patterns := append(defaultIgnorePatterns(), root...)
+	return &ignoreStack{store: store, layers: []ignoreLayer{{base: "", patterns: patterns}}}, nil
+}
+
+// enter pops any layers whose subtree dir has left, then loads dir's own
+// .templateignore (if any) as a new layer scoped to dir. Call this once per
+// directory as a top-down walk visits it, before deciding whether to skip
+// it via excluded.
+func (s *ignoreStack) enter(dir string) error {
+	for len(s.layers) > 1 {
+		top := s.layers[len(s.layers)-1]
+		if dir == top.base || strings.HasPrefix(dir, top.base+"/") {
+			break
+		}
+		s.layers = s.layers[:len(s.layers)-1]
+	}
+
+	if dir == "." {
+		return nil
+	}
+
+	patterns, err := loadTemplateIgnore(s.store, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s/%s: %w", dir, templateIgnoreFile, err)
+	}
+	if len(patterns) > 0 {
+		s.layers = append(s.layers, ignoreLayer{base: dir, patterns: patterns})
+	}
+	return nil
+}
+
+// excluded reports whether relPath is ignored by any currently active
+// layer - gitignore-style, last match across all layers (root to most
+// specific) wins.
+func (s *ignoreStack) excluded(relPath string, isDir bool) bool {
+	ignored := false
+	for _, layer := range s.layers {
+		for _, p := range layer.patterns {
+			if p.match(relPath, isDir) {
+				ignored = !p.negate
+			}
+		}
+	}
+	return ignored
+}
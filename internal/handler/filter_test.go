@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportFilterAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		includes []string
+		excludes []string
+		path     string
+		want     bool
+	}{
+		{name: "no filter allows everything", path: "campaign/emails/body.html", want: true},
+		{name: "include glob matches", includes: []string{"*/emails/*"}, path: "campaign/emails/body.html", want: true},
+		{name: "include glob does not match", includes: []string{"*/emails/*"}, path: "campaign/assets/logo.png", want: false},
+		{name: "exclude glob blocks", excludes: []string{"*.psd"}, path: "campaign/art.psd", want: false},
+		{name: "exclude wins over include", includes: []string{"campaign/*"}, excludes: []string{"*.psd"}, path: "campaign/art.psd", want: false},
+		{name: "regex include", includes: []string{"re:^campaign/emails/.*\\.html$"}, path: "campaign/emails/body.html", want: true},
+		{name: "regex include no match", includes: []string{"re:^campaign/emails/.*\\.html$"}, path: "campaign/pages/index.html", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &ExportFilter{}
+			for _, raw := range tt.includes {
+				p, err := newMatchPattern(raw)
+				if err != nil {
+					t.Fatalf("newMatchPattern(%q) failed: %v", raw, err)
+				}
+				f.includes = append(f.includes, p)
+			}
+			for _, raw := range tt.excludes {
+				p, err := newMatchPattern(raw)
+				if err != nil {
+					t.Fatalf("newMatchPattern(%q) failed: %v", raw, err)
+				}
+				f.excludes = append(f.excludes, p)
+			}
+
+			if got := f.Allowed(tt.path, false); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTemplateIgnore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "templateignore-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ignoreContent := "# comment\n*.psd\n\n!keep.psd\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, ".templateignore"), []byte(ignoreContent), 0644); err != nil {
+		t.Fatalf("Failed to write .templateignore: %v", err)
+	}
+
+	store := os.DirFS(tmpDir)
+	f := &ExportFilter{}
+	f.ignore, err = loadTemplateIgnore(store, ".")
+	if err != nil {
+		t.Fatalf("loadTemplateIgnore failed: %v", err)
+	}
+
+	if f.Allowed("art.psd", false) {
+		t.Error("expected art.psd to be ignored")
+	}
+	if !f.Allowed("keep.psd", false) {
+		t.Error("expected keep.psd to be un-ignored by the negated pattern")
+	}
+	if !f.Allowed("index.html", false) {
+		t.Error("expected index.html to be unaffected by the ignore file")
+	}
+}
+
+func TestLoadTemplateIgnoreMissing(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "templateignore-missing-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	patterns, err := loadTemplateIgnore(os.DirFS(tmpDir), ".")
+	if err != nil {
+		t.Fatalf("expected no error for missing .templateignore, got: %v", err)
+	}
+	if patterns != nil {
+		t.Errorf("expected nil patterns for missing .templateignore, got: %v", patterns)
+	}
+}
+
+func TestParseExportFilterQueryParams(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "parse-filter-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	req := httptest.NewRequest("GET", "/api/export?include=*/emails/*&exclude=*.psd", nil)
+	f, err := parseExportFilter(req, os.DirFS(tmpDir))
+	if err != nil {
+		t.Fatalf("parseExportFilter failed: %v", err)
+	}
+	if !f.active {
+		t.Error("expected filter to be active when include/exclude params are set")
+	}
+	if !f.Allowed("campaign/emails/body.html", false) {
+		t.Error("expected campaign/emails/body.html to be allowed")
+	}
+	if f.Allowed("campaign/art.psd", false) {
+		t.Error("expected campaign/art.psd to be excluded")
+	}
+}
+
+func TestParseExportFilterInvalidRegex(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "parse-filter-invalid-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	req := httptest.NewRequest("GET", "/api/export?include=re:(unterminated", nil)
+	if _, err := parseExportFilter(req, os.DirFS(tmpDir)); err == nil {
+		t.Error("expected an error for an invalid regex include pattern")
+	}
+}
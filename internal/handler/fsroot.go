@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"io/fs"
+	"os"
+)
+
+// openRootFS opens baseDir via os.OpenRoot. The returned *os.Root enforces
+// containment at the OS level (via openat2) for every subsequent lookup
+// through its FS() view, including symlinks that try to point outside
+// baseDir - a guarantee plain os.DirFS does not make. The Root is opened
+// once here, at handler-constructor time, and kept open for the lifetime of
+// the handler it backs.
+//
+// This requires Go 1.24 or later; there is no older-toolchain fallback -
+// every caller that needs containment against baseDir goes through this.
+func openRootFS(baseDir string) (fs.FS, error) {
+	root, err := os.OpenRoot(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return root.FS(), nil
+}
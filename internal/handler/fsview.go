@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// TemplatesFS returns the fs.FS backing baseDir. It is the thin adapter the
+// baseDir-string handler constructors below use internally, so the handler
+// bodies themselves work unchanged against any fs.FS. It opens an *os.Root
+// once (see fsroot.go, which requires Go 1.24) so every lookup the handlers
+// make is kernel-contained to baseDir, including against symlink escapes.
+func TemplatesFS(baseDir string) fs.FS {
+	fsys, err := openRootFS(baseDir)
+	if err != nil {
+		// baseDir couldn't be opened (e.g. doesn't exist yet); fall back to
+		// the same os.DirFS view handlers used before this function existed
+		// and let the first Stat/ReadFile against it surface the real error.
+		return os.DirFS(baseDir)
+	}
+	return fsys
+}
+
+// overlayFS layers override on top of base: a lookup that override
+// satisfies wins outright, and one it doesn't fall back to base. Directory
+// listings merge both layers' entries (override's entry wins on a name
+// collision) rather than picking one layer over the other, since a partial
+// override - say, one campaign's logo.png replacing the base kit's - should
+// still show every other file the base kit ships alongside it.
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+// OverlayFS returns an fs.FS that looks up a path in override first and
+// falls back to base if override doesn't have it - e.g. a user-supplied
+// templates directory layered over the built-in default kit, so a user can
+// override just the files they care about without forking the whole kit.
+func OverlayFS(override, base fs.FS) fs.FS {
+	return overlayFS{override: override, base: base}
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.override.Open(name); err == nil {
+		return f, nil
+	}
+	return o.base.Open(name)
+}
+
+func (o overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if info, err := fs.Stat(o.override, name); err == nil {
+		return info, nil
+	}
+	return fs.Stat(o.base, name)
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	overrideEntries, overrideErr := fs.ReadDir(o.override, name)
+	baseEntries, baseErr := fs.ReadDir(o.base, name)
+	if overrideErr != nil && baseErr != nil {
+		return nil, overrideErr
+	}
+
+	byName := make(map[string]fs.DirEntry, len(overrideEntries)+len(baseEntries))
+	for _, entry := range baseEntries {
+		byName[entry.Name()] = entry
+	}
+	for _, entry := range overrideEntries {
+		byName[entry.Name()] = entry
+	}
+
+	merged := make([]fs.DirEntry, 0, len(byName))
+	for _, entry := range byName {
+		merged = append(merged, entry)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+	return merged, nil
+}
+
+// fsRequestPath turns a URL-derived request path (already stripped of its
+// route prefix) into the relative path fs.FS expects. An fs.FS rooted at
+// baseDir refuses by construction to resolve anything outside its root, so
+// this no longer needs validatePath's manual decode/normalize/traversal
+// checks - it only has to reject paths fs.FS itself wouldn't accept.
+func fsRequestPath(reqPath string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(strings.ReplaceAll(reqPath, "\\", "/"), "/"))
+	if clean == "." || clean == "" {
+		return ".", nil
+	}
+	if !fs.ValidPath(clean) {
+		return "", fmt.Errorf("invalid path")
+	}
+	return clean, nil
+}
+
+// contentTypeForExt maps a file extension (as returned by filepath.Ext) to
+// the Content-Type PreviewHandler and RawViewHandler serve non-HTML files
+// with.
+func contentTypeForExt(ext string) string {
+	switch ext {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".svg":
+		return "image/svg+xml"
+	case ".css":
+		return "text/css"
+	case ".js":
+		return "application/javascript"
+	case ".pdf":
+		return "application/pdf"
+	case ".txt":
+		return "text/plain"
+	case ".yaml":
+		return "text/plain"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFsRequestPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		reqPath string
+		want    string
+		wantErr bool
+	}{
+		{name: "root", reqPath: "", want: "."},
+		{name: "simple", reqPath: "test-dir/test.html", want: "test-dir/test.html"},
+		{name: "leading slash", reqPath: "/test-dir", want: "test-dir"},
+		{name: "traversal rejected", reqPath: "../etc/passwd", wantErr: true},
+		{name: "backslash traversal rejected", reqPath: "..\\etc\\passwd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fsRequestPath(tt.reqPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fsRequestPath(%q) error = %v, wantErr %v", tt.reqPath, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("fsRequestPath(%q) = %q, want %q", tt.reqPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginalContentHandlerFSMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"test-dir/test.html": {Data: []byte("<p>{{.FirstName}}</p>")},
+	}
+
+	handler := OriginalContentHandlerFS(fsys)
+
+	req := httptest.NewRequest("GET", "/original/test-dir/test.html", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr.Body.String() != "<p>{{.FirstName}}</p>" {
+		t.Errorf("expected unprocessed content, got %q", rr.Body.String())
+	}
+}
+
+func TestOriginalContentHandlerFSNotFound(t *testing.T) {
+	fsys := fstest.MapFS{}
+	handler := OriginalContentHandlerFS(fsys)
+
+	req := httptest.NewRequest("GET", "/original/missing.html", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestRawViewHandlerFSNonHTML(t *testing.T) {
+	fsys := fstest.MapFS{
+		"test-dir/image.png": {Data: []byte("fake-png-bytes")},
+	}
+
+	handler := RawViewHandlerFS(fsys, "")
+
+	req := httptest.NewRequest("GET", "/raw/test-dir/image.png", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("expected image/png content type, got %q", rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestOverlayFSOverrideWins(t *testing.T) {
+	base := fstest.MapFS{
+		"page.html": {Data: []byte("base")},
+	}
+	override := fstest.MapFS{
+		"page.html": {Data: []byte("override")},
+	}
+
+	data, err := fs.ReadFile(OverlayFS(override, base), "page.html")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "override" {
+		t.Errorf("ReadFile() = %q, want %q", data, "override")
+	}
+}
+
+func TestOverlayFSFallsBackToBase(t *testing.T) {
+	base := fstest.MapFS{
+		"page.html": {Data: []byte("base")},
+	}
+	override := fstest.MapFS{}
+
+	data, err := fs.ReadFile(OverlayFS(override, base), "page.html")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "base" {
+		t.Errorf("ReadFile() = %q, want %q", data, "base")
+	}
+}
+
+func TestOverlayFSMissingInBothLayers(t *testing.T) {
+	overlay := OverlayFS(fstest.MapFS{}, fstest.MapFS{})
+	if _, err := fs.ReadFile(overlay, "missing.html"); err == nil {
+		t.Error("expected an error for a path missing from both layers")
+	}
+}
+
+func TestOverlayFSReadDirMergesAndOverrides(t *testing.T) {
+	base := fstest.MapFS{
+		"kit/logo.png":  {Data: []byte("base-logo")},
+		"kit/page.html": {Data: []byte("base-page")},
+	}
+	override := fstest.MapFS{
+		"kit/logo.png": {Data: []byte("override-logo")},
+	}
+
+	overlay := OverlayFS(override, base)
+	entries, err := fs.ReadDir(overlay, "kit")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 merged entries, got %d: %+v", len(entries), entries)
+	}
+
+	data, err := fs.ReadFile(overlay, "kit/logo.png")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "override-logo" {
+		t.Errorf("expected the overridden logo.png content, got %q", data)
+	}
+}
+
+func TestBuildNavigationTreeMapFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/one.html": {Data: []byte("one")},
+		"b/two.html": {Data: []byte("two")},
+		"b/c/d.html": {Data: []byte("d")},
+	}
+
+	navTree, expandedDirs := buildNavigationTree(fsys, "b/c")
+
+	if !expandedDirs[""] || !expandedDirs["b"] || !expandedDirs["b/c"] {
+		t.Errorf("expected root, b, and b/c expanded, got %+v", expandedDirs)
+	}
+	if len(navTree) != 2 {
+		t.Fatalf("expected 2 top-level dirs, got %d: %+v", len(navTree), navTree)
+	}
+}
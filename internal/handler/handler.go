@@ -1,26 +1,32 @@
 // Package handler provides HTTP handlers with path traversal protection.
-// TODO: Replace validatePath with os.Root when Go 1.24 is available.
 package handler
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"html/template"
+	"io/fs"
 
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
+
+	xhtml "golang.org/x/net/html"
 )
 
 // validatePath prevents directory traversal attacks through multiple encoding bypass detection.
-// TODO: Replace with os.Root in Go 1.24.
+// See the comment a few lines below this function for why it's still used
+// directly by email.go instead of an *os.Root.
 func validatePath(baseDir, reqPath string) (string, error) {
 	// Reject absolute paths immediately (Unix-style and Windows-style)
 	if filepath.IsAbs(reqPath) {
@@ -65,6 +71,13 @@ func validatePath(baseDir, reqPath string) (string, error) {
 		return "", fmt.Errorf("path traversal attempt detected")
 	}
 
+	// Reject Windows-reserved device basenames (CON, COM1, ...) and any
+	// segment ending in "." or " " - not just on Windows, since templates
+	// are portable artifacts that may get synced to or served from one.
+	if containsWindowsUnsafeSegment(cleanPath) {
+		return "", fmt.Errorf("path contains a reserved or unsafe name")
+	}
+
 	// Additional check for empty or suspicious paths
 	if cleanPath == "" || cleanPath == "." {
 		cleanPath = ""
@@ -93,15 +106,17 @@ func validatePath(baseDir, reqPath string) (string, error) {
 	return absFullPath, nil
 }
 
-// TODO: Go 1.24 replacement with os.Root:
-// func validatePathWithOSRoot(baseDir, reqPath string) (string, error) {
-//     root := os.Root(baseDir)
-//     _, err := root.Stat(filepath.Clean(reqPath))
-//     if err != nil && !os.IsNotExist(err) {
-//         return "", fmt.Errorf("invalid path: %v", err)
-//     }
-//     return filepath.Join(baseDir, reqPath), nil
-// }
+// The os.Root replacement sketched here previously is now implemented, but
+// not as a drop-in for validatePath itself: IndexHandler, PreviewHandler,
+// RawViewHandler, OriginalContentHandler and AssetHandler/NewAssetHandler
+// (assets.go) no longer call validatePath at all - they're fs.FS- or
+// *os.Root-based, and enforce containment at the kernel level for every
+// lookup they make (see fsview.go and fsroot.go, which requires Go 1.24;
+// there is no older-toolchain fallback). validatePath itself is left as-is
+// because email.go's SendTestEmailHandler and CheckEmailTemplateHandler
+// still call it directly against plain os.* calls, not through an os.Root -
+// stripping its decode/normalize/traversal-pattern checks would remove
+// their only protection against traversal.
 
 // decodeMultipleLayers performs multiple rounds of decoding to handle nested encoding
 func decodeMultipleLayers(input string) (string, error) {
@@ -260,16 +275,59 @@ func containsTraversalPattern(path string) bool {
 	return false
 }
 
+// windowsReservedBasenames are the device names Windows reserves regardless
+// of extension or case - CON.html, com1.txt and CON are all unusable as a
+// real file on that OS, and some hosting platforms choke on them even when
+// running on Linux/macOS. Templates are synced cross-platform, so
+// validatePath rejects these basenames on every OS rather than only when
+// actually running on Windows.
+var windowsReservedBasenames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true,
+	"com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true,
+	"lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// containsWindowsUnsafeSegment reports whether cleanPath has a path segment
+// that Windows would refuse to create: a reserved device basename (matched
+// case-insensitively, and before any extension - "CON", "con.html" and
+// "Com1.TXT" all match), or a segment ending in "." or " ", which Windows
+// silently strips, potentially producing a file at a different path than
+// the one requested.
+func containsWindowsUnsafeSegment(cleanPath string) bool {
+	for _, segment := range strings.Split(cleanPath, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		if strings.HasSuffix(segment, ".") || strings.HasSuffix(segment, " ") {
+			return true
+		}
+		base := segment
+		if idx := strings.IndexByte(segment, '.'); idx >= 0 {
+			base = segment[:idx]
+		}
+		if windowsReservedBasenames[strings.ToLower(base)] {
+			return true
+		}
+	}
+	return false
+}
+
 // Template data model for directory listing view
 type DirListData struct {
-	Path         string
-	Dirs         []DirEntry
-	Files        []DirEntry
-	IsRoot       bool
-	ParentPath   string
-	NavTree      []NavTreeItem
-	CurrentPath  string
-	ExpandedDirs map[string]bool
+	Path           string
+	Dirs           []DirEntry
+	Files          []DirEntry
+	IsRoot         bool
+	ParentPath     string
+	NavTree        []NavTreeItem
+	CurrentPath    string
+	ExpandedDirs   map[string]bool
+	Sort           string
+	Order          string
+	ItemsLimitedTo int
+	Readme         template.HTML
 }
 
 // NavTreeItem represents an item in the navigation tree
@@ -284,9 +342,174 @@ type NavTreeItem struct {
 
 // Entry for files and directories in the listing view
 type DirEntry struct {
-	Name  string
-	Path  string
-	IsDir bool
+	Name      string
+	Path      string
+	IsDir     bool
+	Size      int64
+	HumanSize string
+	ModTime   time.Time
+	Ext       string
+}
+
+// sortCookieName and orderCookieName persist the listing sort choice across
+// navigation, the same way ?sort=/?order= query parameters set it for a
+// single request.
+const (
+	sortCookieName  = "dirSort"
+	orderCookieName = "dirOrder"
+)
+
+// dirEntryComparator returns the "less" function for sortBy, defaulting to
+// the existing natural-sort-by-name behavior for unrecognized values.
+func dirEntryComparator(sortBy string) func(a, b DirEntry) bool {
+	switch sortBy {
+	case "size":
+		return func(a, b DirEntry) bool { return a.Size < b.Size }
+	case "time":
+		return func(a, b DirEntry) bool { return a.ModTime.Before(b.ModTime) }
+	case "type":
+		return func(a, b DirEntry) bool {
+			if a.Ext != b.Ext {
+				return a.Ext < b.Ext
+			}
+			return NaturalSort(a.Name, b.Name)
+		}
+	default:
+		return func(a, b DirEntry) bool { return NaturalSort(a.Name, b.Name) }
+	}
+}
+
+// sortDirEntries sorts entries in place by sortBy, reversing the comparator
+// when order is "desc".
+func sortDirEntries(entries []DirEntry, sortBy, order string) {
+	less := dirEntryComparator(sortBy)
+	sort.Slice(entries, func(i, j int) bool {
+		if order == "desc" {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+// jsonDirEntry is the stable JSON shape IndexHandler emits for
+// Accept: application/json or ?format=json requests.
+type jsonDirEntry struct {
+	Name      string         `json:"name"`
+	Path      string         `json:"path"`
+	Size      int64          `json:"size"`
+	ModTime   time.Time      `json:"modtime"`
+	Ext       string         `json:"ext,omitempty"`
+	IsSymlink bool           `json:"is_symlink"`
+	Children  []jsonDirEntry `json:"children,omitempty"`
+}
+
+// jsonDirListing is the top-level document IndexHandler's JSON form
+// returns for a directory.
+type jsonDirListing struct {
+	Path  string         `json:"path"`
+	Dirs  []jsonDirEntry `json:"dirs"`
+	Files []jsonDirEntry `json:"files"`
+}
+
+// wantsJSONListing reports whether the client asked IndexHandler for its
+// JSON form (?format=json, or an Accept header naming application/json)
+// instead of the rendered HTML listing.
+func wantsJSONListing(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// writeJSONListing marshals fsPath's directory contents as a jsonDirListing,
+// recursing depthParam additional levels into subdirectories.
+func writeJSONListing(w http.ResponseWriter, fsys fs.FS, fsPath, reqPath string, files []fs.DirEntry, depthParam string) {
+	depth := 0
+	if d, err := strconv.Atoi(depthParam); err == nil && d > 0 {
+		depth = d
+	}
+
+	listing := jsonDirListing{Path: reqPath}
+	for _, file := range files {
+		var relPath string
+		if reqPath == "" {
+			relPath = file.Name()
+		} else {
+			relPath = path.Join(reqPath, file.Name())
+		}
+
+		entry := toJSONDirEntry(fsys, fsPath, relPath, file, depth)
+		if file.IsDir() {
+			listing.Dirs = append(listing.Dirs, entry)
+		} else {
+			listing.Files = append(listing.Files, entry)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(listing)
+}
+
+// toJSONDirEntry converts a single fs.DirEntry into its JSON form,
+// recursing into subdirectories via buildJSONEntries while depth remains.
+func toJSONDirEntry(fsys fs.FS, parentFSPath, relPath string, entry fs.DirEntry, depth int) jsonDirEntry {
+	e := jsonDirEntry{
+		Name:      entry.Name(),
+		Path:      relPath,
+		IsSymlink: entry.Type()&fs.ModeSymlink != 0,
+	}
+	if info, err := entry.Info(); err == nil {
+		e.Size = info.Size()
+		e.ModTime = info.ModTime()
+	}
+
+	if !entry.IsDir() {
+		e.Ext = strings.ToLower(path.Ext(entry.Name()))
+		return e
+	}
+
+	if depth > 0 {
+		e.Children = buildJSONEntries(fsys, path.Join(parentFSPath, entry.Name()), relPath, depth-1)
+	}
+	return e
+}
+
+// buildJSONEntries lists fsPath (directories first, then natural-sorted by
+// name, matching the HTML listing's ordering) and converts each entry to
+// its JSON form, recursing depth additional levels into subdirectories.
+func buildJSONEntries(fsys fs.FS, fsPath, relPath string, depth int) []jsonDirEntry {
+	entries, err := fs.ReadDir(fsys, fsPath)
+	if err != nil {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return NaturalSort(entries[i].Name(), entries[j].Name())
+	})
+
+	result := make([]jsonDirEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, toJSONDirEntry(fsys, fsPath, path.Join(relPath, entry.Name()), entry, depth))
+	}
+	return result
+}
+
+// humanSize formats a byte count the way Caddy's browse middleware does,
+// e.g. 1536 -> "1.5 KiB".
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
 // Template data model for preview view
@@ -298,6 +521,11 @@ type PreviewData struct {
 	NavTree      []NavTreeItem
 	CurrentPath  string
 	ExpandedDirs map[string]bool
+
+	// Formats and ActiveFormat drive the preview page's format tabs/links
+	// (?format=txt, ?format=eml, ...) - see OutputFormatNames.
+	Formats      []string
+	ActiveFormat string
 }
 
 // Map of template variables with default values for preview
@@ -340,14 +568,14 @@ var templateVars = map[string]string{
 
 // IndexHandler renders the directory listing view
 func IndexHandler(baseDir string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse the base template
-		tmpl, err := template.New("layout.html").Funcs(TemplateFuncs).ParseFiles("views/layout.html", "views/listing.html", "views/nav_tree.html")
-		if err != nil {
-			http.Error(w, "Failed to load templates: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	return IndexHandlerFS(TemplatesFS(baseDir), baseDir)
+}
 
+// IndexHandlerFS is IndexHandler against an injected fs.FS. baseDir is only
+// still threaded through for processTemplateContent's string-path asset
+// fallback logic; the directory walk and listing itself is fs.FS-only.
+func IndexHandlerFS(fsys fs.FS, baseDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		// Get requested path
 		reqPath := strings.TrimPrefix(r.URL.Path, "/")
 
@@ -356,16 +584,16 @@ func IndexHandler(baseDir string) http.HandlerFunc {
 
 		// No toggle action needed - we'll just expand the current path
 
-		// Validate and build the filesystem path
-		fsPath, err := validatePath(baseDir, reqPath)
+		// Resolve the fs.FS-relative path
+		fsPath, err := fsRequestPath(reqPath)
 		if err != nil {
 			http.Error(w, "Invalid path", http.StatusBadRequest)
 			return
 		}
 
 		// Check if path exists
-		info, err := os.Stat(fsPath)
-		if os.IsNotExist(err) {
+		info, err := fs.Stat(fsys, fsPath)
+		if errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, "Path not found", http.StatusNotFound)
 			return
 		}
@@ -382,25 +610,60 @@ func IndexHandler(baseDir string) http.HandlerFunc {
 		}
 
 		// Get directory contents
-		files, err := os.ReadDir(fsPath)
+		files, err := fs.ReadDir(fsys, fsPath)
 		if err != nil {
 			http.Error(w, "Failed to read directory: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		// Content-negotiated JSON form for programmatic access (CI template
+		// checks, catalog importers): Accept: application/json or
+		// ?format=json, with ?depth=N for recursive listings. Handled before
+		// the HTML templates are parsed so JSON clients don't need views/ on
+		// disk at all.
+		if wantsJSONListing(r) {
+			writeJSONListing(w, fsys, fsPath, reqPath, files, r.URL.Query().Get("depth"))
+			return
+		}
+
+		// Parse the base template
+		tmpl, err := template.New("layout.html").Funcs(TemplateFuncs).ParseFiles("views/layout.html", "views/listing.html", "views/nav_tree.html")
+		if err != nil {
+			http.Error(w, "Failed to load templates: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Render a README.md, if present, as sanitized HTML so authors have a
+		// place to document per-scenario context (target profile, pretext,
+		// provenance, gotchas) that shows up automatically on the listing.
+		readme, err := renderReadme(fsys, fsPath, files)
+		if err != nil {
+			http.Error(w, "Failed to render README: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// .previewignore/.previewonly only affect this listing - a hidden
+		// file is still served normally by the preview/raw/original handlers.
+		vis, err := loadListingVisibility(fsys, fsPath)
+		if err != nil {
+			http.Error(w, "Failed to read listing visibility rules: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		// Build view data
 		data := DirListData{
 			Path:         reqPath,
 			IsRoot:       reqPath == "",
 			CurrentPath:  reqPath,
 			ExpandedDirs: expandedDirsFromCookie,
+			Readme:       readme,
 		}
 
 		// Set parent path
 		if data.IsRoot {
 			data.ParentPath = "/"
 		} else {
-			parentPath := filepath.Dir(reqPath)
+			parentPath := path.Dir(reqPath)
 			if parentPath == "." {
 				data.ParentPath = "/"
 			} else {
@@ -410,11 +673,15 @@ func IndexHandler(baseDir string) http.HandlerFunc {
 
 		// Group entries into directories and files
 		for _, file := range files {
+			if !vis.visible(file.Name()) {
+				continue
+			}
+
 			var entryPath string
 			if reqPath == "" {
 				entryPath = file.Name()
 			} else {
-				entryPath = filepath.ToSlash(filepath.Join(reqPath, file.Name()))
+				entryPath = path.Join(reqPath, file.Name())
 			}
 
 			entry := DirEntry{
@@ -423,25 +690,72 @@ func IndexHandler(baseDir string) http.HandlerFunc {
 				IsDir: file.IsDir(),
 			}
 
+			if fi, err := file.Info(); err == nil {
+				entry.Size = fi.Size()
+				entry.HumanSize = humanSize(fi.Size())
+				entry.ModTime = fi.ModTime()
+			}
+
 			if file.IsDir() {
 				data.Dirs = append(data.Dirs, entry)
 			} else {
 				// Include HTML files in the listing for preview
 				// Other files will be included but handled differently in the template
+				entry.Ext = strings.ToLower(path.Ext(file.Name()))
 				data.Files = append(data.Files, entry)
 			}
 		}
 
-		// Sort directories and files using natural sorting
-		sort.Slice(data.Dirs, func(i, j int) bool {
-			return NaturalSort(data.Dirs[i].Name, data.Dirs[j].Name)
-		})
-		sort.Slice(data.Files, func(i, j int) bool {
-			return NaturalSort(data.Files[i].Name, data.Files[j].Name)
-		})
+		// sort=name|size|time|type and order=asc|desc mirror Caddy's browse
+		// middleware; fall back to a cookie so navigating between
+		// directories keeps the chosen sort stable, then re-set the cookie
+		// so an explicit query parameter updates it.
+		sortBy := r.URL.Query().Get("sort")
+		if sortBy == "" {
+			if c, err := r.Cookie(sortCookieName); err == nil {
+				sortBy = c.Value
+			}
+		}
+		switch sortBy {
+		case "name", "size", "time", "type":
+		default:
+			sortBy = "name"
+		}
+
+		order := r.URL.Query().Get("order")
+		if order == "" {
+			if c, err := r.Cookie(orderCookieName); err == nil {
+				order = c.Value
+			}
+		}
+		if order != "asc" && order != "desc" {
+			order = "asc"
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: sortCookieName, Value: sortBy, Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: orderCookieName, Value: order, Path: "/"})
+
+		data.Sort = sortBy
+		data.Order = order
+
+		sortDirEntries(data.Dirs, sortBy, order)
+		sortDirEntries(data.Files, sortBy, order)
+
+		if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 {
+			total := len(data.Dirs) + len(data.Files)
+			if total > limit {
+				data.ItemsLimitedTo = limit
+				if len(data.Dirs) > limit {
+					data.Dirs = data.Dirs[:limit]
+					data.Files = nil
+				} else {
+					data.Files = data.Files[:limit-len(data.Dirs)]
+				}
+			}
+		}
 
 		// Build navigation tree
-		navTree, expandedDirs := buildNavigationTree(baseDir, reqPath)
+		navTree, expandedDirs := buildNavigationTree(fsys, reqPath)
 
 		// Merge cookie expanded dirs with the calculated ones
 		for dir := range expandedDirsFromCookie {
@@ -460,7 +774,7 @@ func IndexHandler(baseDir string) http.HandlerFunc {
 }
 
 // buildNavigationTree creates a hierarchical tree structure for the sidebar navigation
-func buildNavigationTree(baseDir, currentPath string) ([]NavTreeItem, map[string]bool) {
+func buildNavigationTree(fsys fs.FS, currentPath string) ([]NavTreeItem, map[string]bool) {
 	// Create a map to track expanded directories
 	expandedDirs := make(map[string]bool)
 
@@ -481,7 +795,7 @@ func buildNavigationTree(baseDir, currentPath string) ([]NavTreeItem, map[string
 	}
 
 	// Read the root directory
-	rootEntries, err := os.ReadDir(baseDir)
+	rootEntries, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return nil, expandedDirs
 	}
@@ -501,7 +815,7 @@ func buildNavigationTree(baseDir, currentPath string) ([]NavTreeItem, map[string
 
 			// If this directory should be expanded, add its children
 			if expandedDirs[entry.Name()] {
-				item.Children = getDirectoryChildren(filepath.Join(baseDir, entry.Name()), entry.Name(), expandedDirs, 1)
+				item.Children = getDirectoryChildren(fsys, entry.Name(), entry.Name(), expandedDirs, 1)
 			}
 
 			rootItems = append(rootItems, item)
@@ -585,16 +899,15 @@ func isNumber(s string) bool {
 }
 
 // getDirectoryChildren reads a directory and returns its children as NavTreeItems
-func getDirectoryChildren(dirPath, relativePath string, expandedDirs map[string]bool, level int) []NavTreeItem {
-	entries, err := os.ReadDir(dirPath)
+func getDirectoryChildren(fsys fs.FS, fsPath, relativePath string, expandedDirs map[string]bool, level int) []NavTreeItem {
+	entries, err := fs.ReadDir(fsys, fsPath)
 	if err != nil {
 		return nil
 	}
 
 	children := make([]NavTreeItem, 0)
 	for _, entry := range entries {
-		childRelPath := filepath.Join(relativePath, entry.Name())
-		childRelPath = filepath.ToSlash(childRelPath) // Ensure consistent path format
+		childRelPath := path.Join(relativePath, entry.Name())
 
 		item := NavTreeItem{
 			Name:   entry.Name(),
@@ -607,7 +920,8 @@ func getDirectoryChildren(dirPath, relativePath string, expandedDirs map[string]
 		// If this is a directory and it's expanded, add its children
 		if entry.IsDir() && expandedDirs[childRelPath] {
 			item.Children = getDirectoryChildren(
-				filepath.Join(dirPath, entry.Name()),
+				fsys,
+				path.Join(fsPath, entry.Name()),
 				childRelPath,
 				expandedDirs,
 				level+1,
@@ -630,6 +944,11 @@ func getDirectoryChildren(dirPath, relativePath string, expandedDirs map[string]
 
 // OriginalContentHandler serves the raw template content without any processing
 func OriginalContentHandler(baseDir string) http.HandlerFunc {
+	return OriginalContentHandlerFS(TemplatesFS(baseDir))
+}
+
+// OriginalContentHandlerFS is OriginalContentHandler against an injected fs.FS.
+func OriginalContentHandlerFS(fsys fs.FS) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get requested path
 		reqPath := strings.TrimPrefix(r.URL.Path, "/original/")
@@ -638,16 +957,16 @@ func OriginalContentHandler(baseDir string) http.HandlerFunc {
 			return
 		}
 
-		// Validate and build the filesystem path
-		fsPath, err := validatePath(baseDir, reqPath)
+		// Resolve the fs.FS-relative path
+		fsPath, err := fsRequestPath(reqPath)
 		if err != nil {
 			http.Error(w, "Invalid path", http.StatusBadRequest)
 			return
 		}
 
 		// Check if file exists
-		info, err := os.Stat(fsPath)
-		if os.IsNotExist(err) {
+		info, err := fs.Stat(fsys, fsPath)
+		if errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, "Template not found", http.StatusNotFound)
 			return
 		}
@@ -663,17 +982,23 @@ func OriginalContentHandler(baseDir string) http.HandlerFunc {
 		}
 
 		// Read file content without any processing
-		content, err := os.ReadFile(fsPath)
+		content, err := fs.ReadFile(fsys, fsPath)
 		if err != nil {
 			http.Error(w, "Failed to read template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Set content type based on file extension
+		// Set content type based on file extension. A .md file is
+		// "detected" here only to label it correctly - OriginalContentHandler
+		// exists to serve the literal on-disk bytes with no processing, so
+		// unlike PreviewHandler/RawViewHandler it never runs renderMarkdownFS
+		// against it.
 		ext := strings.ToLower(filepath.Ext(fsPath))
 		switch ext {
 		case ".html":
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		case ".md":
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
 		case ".txt":
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		default:
@@ -687,6 +1012,13 @@ func OriginalContentHandler(baseDir string) http.HandlerFunc {
 
 // RawViewHandler serves the template content directly without wrapping it in the UI
 func RawViewHandler(baseDir string) http.HandlerFunc {
+	return RawViewHandlerFS(TemplatesFS(baseDir), baseDir)
+}
+
+// RawViewHandlerFS is RawViewHandler against an injected fs.FS. baseDir is
+// only still threaded through for processTemplateContent's string-path asset
+// fallback logic.
+func RawViewHandlerFS(fsys fs.FS, baseDir string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get requested path
 		reqPath := strings.TrimPrefix(r.URL.Path, "/raw/")
@@ -695,16 +1027,16 @@ func RawViewHandler(baseDir string) http.HandlerFunc {
 			return
 		}
 
-		// Validate and build the filesystem path
-		fsPath, err := validatePath(baseDir, reqPath)
+		// Resolve the fs.FS-relative path
+		fsPath, err := fsRequestPath(reqPath)
 		if err != nil {
 			http.Error(w, "Invalid path", http.StatusBadRequest)
 			return
 		}
 
 		// Check if file exists
-		info, err := os.Stat(fsPath)
-		if os.IsNotExist(err) {
+		info, err := fs.Stat(fsys, fsPath)
+		if errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, "Template not found", http.StatusNotFound)
 			return
 		}
@@ -719,17 +1051,43 @@ func RawViewHandler(baseDir string) http.HandlerFunc {
 			return
 		}
 
-		// For HTML files, process the template content before serving
-		if filepath.Ext(fsPath) == ".html" || filepath.Ext(fsPath) == ".yaml" {
+		ext := strings.ToLower(filepath.Ext(fsPath))
+
+		// For HTML (and Markdown, see below) files, process the template
+		// content before serving
+		if ext == ".html" || ext == ".yaml" || ext == ".md" {
 			// Read file content
-			content, err := os.ReadFile(fsPath)
+			content, err := fs.ReadFile(fsys, fsPath)
 			if err != nil {
 				http.Error(w, "Failed to read template: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
 
 			// Process the template content (replacing variables)
-			processedContent := processTemplateContent(string(content), reqPath, baseDir)
+			vars, err := effectiveTemplateVarsForFS(fsys, fsPath, baseDir, r)
+			if err != nil {
+				http.Error(w, "Failed to load template variables: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			renderContent := string(content)
+			if ext == ".md" {
+				// Front matter + layout selection happens here; the result
+				// is handed to the same Renderer.Render call an .html
+				// template goes through, so vars/asset-path/SRI processing
+				// stays identical either way.
+				renderContent, vars, err = renderMarkdownFS(fsys, reqPath, content, vars)
+				if err != nil {
+					http.Error(w, "Failed to render markdown template: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			processedContent, err := rendererFor(baseDir).Render(renderContent, reqPath, vars)
+			if err != nil {
+				http.Error(w, "Failed to render "+reqPath+": "+err.Error(), http.StatusInternalServerError)
+				return
+			}
 
 			// Set content type and serve
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -738,12 +1096,41 @@ func RawViewHandler(baseDir string) http.HandlerFunc {
 		}
 
 		// For non-HTML files, serve directly
-		http.ServeFile(w, r, fsPath)
+		content, err := fs.ReadFile(fsys, fsPath)
+		if err != nil {
+			http.Error(w, "Failed to read template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeForExt(ext))
+		w.Write(content)
 	}
 }
 
 // PreviewHandler renders the template preview
 func PreviewHandler(baseDir string) http.HandlerFunc {
+	return PreviewHandlerFS(TemplatesFS(baseDir), baseDir)
+}
+
+// PreviewHandlerFS is PreviewHandler against an injected fs.FS. baseDir is
+// only still threaded through for processTemplateContent's string-path asset
+// fallback logic.
+func PreviewHandlerFS(fsys fs.FS, baseDir string) http.HandlerFunc {
+	return previewHandler(fsys, baseDir, nil)
+}
+
+// PreviewHandlerDev is PreviewHandler with live reload wired in: every
+// rendered HTML/Markdown preview gets LiveReloadScript appended, opening a
+// WebSocket to /ws/reload scoped to the previewed template's directory, so
+// a ReloadHub.Watch running on the same baseDir can push a refresh the
+// moment a file in that directory changes. Use alongside hub.ReloadHandler()
+// mounted at /ws/reload - see main's -dev flag.
+func PreviewHandlerDev(baseDir string, hub *ReloadHub) http.HandlerFunc {
+	return previewHandler(TemplatesFS(baseDir), baseDir, hub)
+}
+
+// previewHandler is the shared implementation PreviewHandlerFS and
+// PreviewHandlerDev build on; hub is nil unless live reload is enabled.
+func previewHandler(fsys fs.FS, baseDir string, hub *ReloadHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse the base template
 		tmpl, err := template.New("layout.html").Funcs(TemplateFuncs).ParseFiles("views/layout.html", "views/preview.html", "views/nav_tree.html")
@@ -761,16 +1148,16 @@ func PreviewHandler(baseDir string) http.HandlerFunc {
 
 		// No toggle functionality in preview handler
 
-		// Validate and build the filesystem path
-		fsPath, err := validatePath(baseDir, reqPath)
+		// Resolve the fs.FS-relative path
+		fsPath, err := fsRequestPath(reqPath)
 		if err != nil {
 			http.Error(w, "Invalid path", http.StatusBadRequest)
 			return
 		}
 
 		// Check if file exists
-		info, err := os.Stat(fsPath)
-		if os.IsNotExist(err) {
+		info, err := fs.Stat(fsys, fsPath)
+		if errors.Is(err, fs.ErrNotExist) {
 			http.Error(w, "Template not found", http.StatusNotFound)
 			return
 		}
@@ -785,58 +1172,75 @@ func PreviewHandler(baseDir string) http.HandlerFunc {
 			return
 		}
 
-		// For non-HTML files, serve them directly
-		if filepath.Ext(fsPath) != ".html" {
-			// Set appropriate content type based on file extension
-			ext := filepath.Ext(fsPath)
-			var contentType string
-			switch strings.ToLower(ext) {
-			case ".jpg", ".jpeg":
-				contentType = "image/jpeg"
-			case ".png":
-				contentType = "image/png"
-			case ".gif":
-				contentType = "image/gif"
-			case ".svg":
-				contentType = "image/svg+xml"
-			case ".css":
-				contentType = "text/css"
-			case ".js":
-				contentType = "application/javascript"
-			case ".pdf":
-				contentType = "application/pdf"
-			case ".txt":
-				contentType = "text/plain"
-			case ".yaml":
-				contentType = "text/plain"
-			default:
-				contentType = "application/octet-stream"
-			}
+		ext := strings.ToLower(filepath.Ext(fsPath))
 
+		// For non-HTML, non-Markdown files, serve them directly
+		if ext != ".html" && ext != ".md" {
 			// Read and serve the file
-			fileData, err := os.ReadFile(fsPath)
+			fileData, err := fs.ReadFile(fsys, fsPath)
 			if err != nil {
 				http.Error(w, "Failed to read file: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Type", contentTypeForExt(ext))
 			w.Write(fileData)
 			return
 		}
 
 		// Read file content
-		content, err := os.ReadFile(fsPath)
+		content, err := fs.ReadFile(fsys, fsPath)
 		if err != nil {
 			http.Error(w, "Failed to read template: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Process the template content
-		processedContent := processTemplateContent(string(content), reqPath, baseDir)
+		vars, err := effectiveTemplateVarsForFS(fsys, fsPath, baseDir, r)
+		if err != nil {
+			http.Error(w, "Failed to load template variables: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		renderContent := string(content)
+		if ext == ".md" {
+			// Front matter + layout selection happens here; the result is
+			// handed to the same Renderer.Render call an .html template
+			// goes through, so vars/asset-path/SRI processing and the
+			// ?format= output transforms below stay identical either way.
+			renderContent, vars, err = renderMarkdownFS(fsys, reqPath, content, vars)
+			if err != nil {
+				http.Error(w, "Failed to render markdown template: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		processedContent, err := rendererFor(baseDir).Render(renderContent, reqPath, vars)
+		if err != nil {
+			http.Error(w, "Failed to render "+reqPath+": "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// A non-default ?format= bypasses the preview page chrome entirely
+		// and serves that OutputFormat's own serialization (plaintext, an
+		// .eml message, ...) with its own content type.
+		if format := r.URL.Query().Get("format"); format != "" && format != "html" {
+			of, ok := OutputFormats[format]
+			if !ok {
+				http.Error(w, "Unknown output format: "+format, http.StatusBadRequest)
+				return
+			}
+			output, err := of.Transform(processedContent)
+			if err != nil {
+				http.Error(w, "Failed to render "+format+": "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", of.MediaType)
+			w.Write(output)
+			return
+		}
 
 		// Build view data
-		parentDir := filepath.Dir(reqPath)
+		parentDir := path.Dir(reqPath)
 		var parentPath string
 		if parentDir == "." {
 			parentPath = "/"
@@ -848,21 +1252,27 @@ func PreviewHandler(baseDir string) http.HandlerFunc {
 		expandedDirsFromCookie := make(map[string]bool)
 
 		// Build navigation tree for the sidebar
-		navTree, expandedDirs := buildNavigationTree(baseDir, parentDir)
+		navTree, expandedDirs := buildNavigationTree(fsys, parentDir)
 
 		// Merge cookie expanded dirs with the calculated ones
 		for dir := range expandedDirsFromCookie {
 			expandedDirs[dir] = true
 		}
 
+		if hub != nil {
+			processedContent += LiveReloadScript(parentDir)
+		}
+
 		data := PreviewData{
 			Path:         reqPath,
-			Filename:     filepath.Base(reqPath),
+			Filename:     path.Base(reqPath),
 			Content:      template.HTML(processedContent),
 			ParentPath:   parentPath,
 			NavTree:      navTree,
 			CurrentPath:  parentDir,
 			ExpandedDirs: expandedDirs,
+			Formats:      OutputFormatNames,
+			ActiveFormat: "html",
 		}
 
 		// Render template
@@ -873,8 +1283,31 @@ func PreviewHandler(baseDir string) http.HandlerFunc {
 	}
 }
 
-// Process template content by replacing GoPhish template variables
-func processTemplateContent(content, reqPath, baseDir string) string {
+// rawHTMLVarNames are vars whose value processTemplateContentStat embeds as
+// template.HTML (unescaped) rather than plain text.
+var rawHTMLVarNames = map[string]bool{
+	"Tracker": true,
+	"Content": true,
+}
+
+// Process template content by replacing GoPhish template variables. vars is
+// the effective "{{.Name}}"-keyed variable set to substitute - callers build
+// it from loadBaseTemplateVars, a per-template loadTemplateOverrideFS and
+// the caller's session cookie overrides, merged in that order. Callers that
+// need to distinguish a LimitError (see SafeExecute) from an ordinary
+// rendering hiccup should go through Renderer.Render instead, which is what
+// RawViewHandlerFS and previewHandler do.
+func processTemplateContent(content, reqPath, baseDir string, vars map[string]string) string {
+	previewCfg, _ := effectivePreviewConfig(baseDir, reqPath)
+	rendered, _ := processTemplateContentStat(content, reqPath, baseDir, vars, os.Stat, previewCfg)
+	return rendered
+}
+
+// processTemplateContentStat returns a non-nil error only when SafeExecute
+// aborts the render under Limits (a *LimitError); an ordinary Parse/Execute
+// error still falls back to string replacement, as before, and is not
+// returned.
+func processTemplateContentStat(content, reqPath, baseDir string, vars map[string]string, stat statFunc, previewCfg *PreviewConfig) (string, error) {
 	// Process BaseURL specially to make it relative to the current template
 	dirPath := filepath.ToSlash(filepath.Dir(reqPath))
 
@@ -892,12 +1325,13 @@ func processTemplateContent(content, reqPath, baseDir string) string {
 	templateData := make(map[string]any)
 
 	// Add all template variables to data (removing the {{. }} wrapper)
-	for placeholder, value := range templateVars {
-		// Extract variable name from {{.VarName}} format
-		if strings.HasPrefix(placeholder, "{{.") && strings.HasSuffix(placeholder, "}}") {
-			varName := strings.TrimPrefix(strings.TrimSuffix(placeholder, "}}"), "{{.")
-			// Special handling for Tracker - it should be rendered as unescaped HTML
-			if varName == "Tracker" {
+	for placeholder, value := range vars {
+		if varName, ok := unwrapVarKey(placeholder); ok {
+			// rawHTMLVarNames are the few trusted vars whose value is itself
+			// markup to embed unescaped: Tracker (a <img> pixel tag) and
+			// Content (a Markdown template's body, rendered to HTML by
+			// renderMarkdownFS) - both written by the template's own author.
+			if rawHTMLVarNames[varName] {
 				templateData[varName] = template.HTML(value)
 			} else {
 				templateData[varName] = value
@@ -908,136 +1342,262 @@ func processTemplateContent(content, reqPath, baseDir string) string {
 	// Add BaseURL to template data (override the static value with computed path)
 	templateData["BaseURL"] = baseURL
 
+	// Layer preview.yaml's extra data-context values under the explicit
+	// vars above, so a session override or vars.yaml entry for the same
+	// name still wins.
+	var previewFuncs template.FuncMap
+	if previewCfg != nil {
+		for k, v := range previewCfg.Vars {
+			if _, exists := templateData[k]; !exists {
+				templateData[k] = v
+			}
+		}
+		previewFuncs = previewFuncMap(previewCfg.Funcs)
+	}
+
 	// Always process templates
-	tmpl, err := template.New("content").Funcs(TemplateFuncs).Parse(content)
+	tmpl, err := template.New("content").Funcs(TemplateFuncs).Funcs(limitFuncs(DefaultLimits)).Funcs(pipelineFuncs(baseDir, reqPath)).Funcs(partialFuncs(baseDir, reqPath, 0)).Funcs(previewFuncs).Parse(content)
 	if err != nil {
 		// If template parsing fails, fall back to string replacement
 		content = strings.Replace(content, "{{.BaseURL}}", baseURL, -1)
-		for placeholder, value := range templateVars {
+		for placeholder, value := range vars {
 			content = strings.Replace(content, placeholder, value, -1)
 		}
-		return processAssetPaths(content, reqPath, baseDir)
+		return processAssetPathsStat(content, reqPath, baseDir, stat), nil
 	}
 
 	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, templateData)
+	err = SafeExecute(tmpl, templateData, &buf, DefaultLimits)
 	if err != nil {
-		// If template execution fails, fall back to string replacement
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			return "", fmt.Errorf("template %s: %w", reqPath, limitErr)
+		}
+		// An ordinary execution error falls back to string replacement, same
+		// as a parse error above.
 		content = strings.Replace(content, "{{.BaseURL}}", baseURL, -1)
-		for placeholder, value := range templateVars {
+		for placeholder, value := range vars {
 			content = strings.Replace(content, placeholder, value, -1)
 		}
-		return processAssetPaths(content, reqPath, baseDir)
+		return processAssetPathsStat(content, reqPath, baseDir, stat), nil
 	}
 
 	content = buf.String()
 
-	return processAssetPaths(content, reqPath, baseDir)
+	return processAssetPathsStat(content, reqPath, baseDir, stat), nil
 }
 
+// statFunc matches os.Stat's signature. processAssetPaths takes one so the
+// render cache (rendercache.go) can substitute a recording wrapper that
+// remembers every path it was asked to check, hit or miss, without
+// processAssetPaths itself knowing the cache exists.
+type statFunc func(name string) (os.FileInfo, error)
+
 // processAssetPaths handles asset path processing for template content
 func processAssetPaths(content, reqPath, baseDir string) string {
-	// Fix any double slashes in paths (except for http:// or https://)
-	content = strings.Replace(content, "src=\"//", "src=\"/", -1)
-	content = strings.Replace(content, "href=\"//", "href=\"/", -1)
-
-	// Fix any occurrences of double slashes in URLs within HTML attributes only
-	// This avoids corrupting JavaScript comments like "// comment"
-	// Use a function to safely replace double slashes while preserving protocols
-	content = regexp.MustCompile(`((?:src|href|action)=["']([^"']*?)["'])`).ReplaceAllStringFunc(content, func(match string) string {
-		// Don't modify URLs that start with http:// or https://
-		if strings.Contains(match, "http://") || strings.Contains(match, "https://") {
-			return match
-		}
-		// Replace multiple slashes with single slash for non-protocol URLs
-		return regexp.MustCompile(`//+`).ReplaceAllString(match, "/")
-	})
+	return processAssetPathsStat(content, reqPath, baseDir, os.Stat)
+}
 
-	// Find and replace any img/script/link/a tags that reference relative paths
+func processAssetPathsStat(content, reqPath, baseDir string, stat statFunc) string {
 	templateDir := filepath.Dir(reqPath)
+	renderer := rendererFor(baseDir)
+
+	// Tokenize rather than regex the whole body: a regex can rewrite an
+	// attribute value in place, but can't reliably insert a brand-new
+	// integrity attribute alongside whatever attributes a tag already has.
+	// Untouched tokens (text, comments, raw <script>/<style> bodies, any
+	// tag we don't rewrite) are re-emitted via z.Raw() - their exact source
+	// bytes - rather than tok.String(), which would re-escape text nodes
+	// and risk mangling a "a && b < c" inside a <script>.
+	z := xhtml.NewTokenizer(strings.NewReader(content))
+	var buf bytes.Buffer
 
-	// Process src attributes with asset fallback logic
-	srcRegex := regexp.MustCompile(`(src|href)=["']([^"']+)["']`)
-	content = srcRegex.ReplaceAllStringFunc(content, func(match string) string {
-		parts := srcRegex.FindStringSubmatch(match)
-		attr := parts[1]
-		path := parts[2]
-
-		// Skip absolute URLs and data URLs
-		if strings.HasPrefix(path, "http://") ||
-			strings.HasPrefix(path, "https://") ||
-			strings.HasPrefix(path, "//") ||
-			strings.HasPrefix(path, "data:") ||
-			strings.HasPrefix(path, "#") {
-			return match
-		}
-
-		// Handle paths that start with /templates/ (already processed BaseURL paths)
-		if strings.HasPrefix(path, "/templates/") {
-			// Check if the file actually exists at this path
-			relativePath := strings.TrimPrefix(path, "/templates/")
-			fullPath := filepath.Join(baseDir, relativePath)
-
-			if _, err := os.Stat(fullPath); err == nil {
-				// File exists at the current path, keep it
-				return match
-			}
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			break
+		}
 
-			// File doesn't exist, try to extract the asset part and check in global assets
-			templatePrefix := templateDir + "/"
-			if strings.HasPrefix(relativePath, templatePrefix) {
-				assetPath := strings.TrimPrefix(relativePath, templatePrefix)
+		if tt != xhtml.StartTagToken && tt != xhtml.SelfClosingTagToken {
+			buf.Write(z.Raw())
+			continue
+		}
 
-				// Check if the assetPath starts with "assets/" - if so, remove it
-				// This handles templates that use {{.BaseURL}}/assets/... pattern
-				if strings.HasPrefix(assetPath, "assets/") {
-					assetPath = strings.TrimPrefix(assetPath, "assets/")
-				}
+		tok := z.Token()
+		if rewriteAssetTag(&tok, templateDir, baseDir, renderer, stat) {
+			buf.WriteString(tok.String())
+		} else {
+			buf.Write(z.Raw())
+		}
+	}
 
-				// Try global assets directory
-				globalAssetsPath := filepath.Join(baseDir, "assets", assetPath)
-				if _, err := os.Stat(globalAssetsPath); err == nil {
-					// File exists in global assets directory
-					newPath := filepath.ToSlash(filepath.Join("/templates/assets", assetPath))
-					newPath = filepath.Clean(newPath)
-					return fmt.Sprintf(`%s="%s"`, attr, newPath)
-				}
-			}
+	return buf.String()
+}
 
-			// Return original if no fallback found
-			return match
+// rewriteAssetTag rewrites tok's src/href/action attribute (if any) to its
+// resolved /templates/... path and, when eligible, injects an SRI
+// integrity/crossorigin pair. It reports whether tok was modified at all -
+// callers should fall back to the tag's raw source bytes when false.
+func rewriteAssetTag(tok *xhtml.Token, templateDir, baseDir string, renderer *Renderer, stat statFunc) bool {
+	changed := false
+	var resolvedAbsPath string
+	hasIntegrity := false
+	isStylesheetLink := false
+
+	for i := range tok.Attr {
+		attr := &tok.Attr[i]
+		switch attr.Key {
+		case "src", "href", "action":
+			newValue, absPath, found, ok := resolveAssetAttr(attr.Val, templateDir, baseDir, renderer, stat)
+			if !ok {
+				continue
+			}
+			if newValue != attr.Val {
+				changed = true
+			}
+			attr.Val = newValue
+			if found {
+				resolvedAbsPath = absPath
+			}
+		case "integrity":
+			hasIntegrity = true
+		case "rel":
+			isStylesheetLink = attr.Val == "stylesheet"
 		}
+	}
 
-		// Handle relative paths (don't start with /)
-		// Try local template directory first
-		localPath := filepath.Join(baseDir, templateDir, path)
-		if _, err := os.Stat(localPath); err == nil {
-			// File exists in template directory, use it
-			newPath := filepath.ToSlash(filepath.Join("/templates", templateDir, path))
-			newPath = filepath.Clean(newPath)
-			if !strings.HasPrefix(newPath, "/templates") {
-				newPath = "/templates/" + strings.TrimPrefix(newPath, "/")
-			}
-			return fmt.Sprintf(`%s="%s"`, attr, newPath)
+	sriEligible := tok.Data == "img" || tok.Data == "script" || (tok.Data == "link" && isStylesheetLink)
+	if renderer.EmitSRI && resolvedAbsPath != "" && !hasIntegrity && sriEligible {
+		if integrity, err := fileIntegrity(resolvedAbsPath); err == nil {
+			tok.Attr = append(tok.Attr,
+				xhtml.Attribute{Key: "integrity", Val: integrity},
+				xhtml.Attribute{Key: "crossorigin", Val: "anonymous"},
+			)
+			changed = true
 		}
+	}
 
-		// Try global assets directory as fallback
-		globalAssetsPath := filepath.Join(baseDir, "assets", path)
-		if _, err := os.Stat(globalAssetsPath); err == nil {
-			// File exists in global assets directory
-			newPath := filepath.ToSlash(filepath.Join("/templates/assets", path))
-			newPath = filepath.Clean(newPath)
-			return fmt.Sprintf(`%s="%s"`, attr, newPath)
+	return changed
+}
+
+// resolveAssetAttr resolves a single src/href/action attribute value to the
+// path processAssetPaths should serve it from. ok is false for values that
+// should be left exactly as written (empty, data:, fragment-only, or
+// unparseable); found reports whether the returned path was confirmed to
+// exist on disk, with absPath set to its absolute location - used to decide
+// SRI eligibility, since an integrity hash over a best-effort guess would be
+// meaningless.
+func resolveAssetAttr(rawValue, templateDir, baseDir string, renderer *Renderer, stat statFunc) (newValue, absPath string, found, ok bool) {
+	if rawValue == "" || strings.HasPrefix(rawValue, "data:") || strings.HasPrefix(rawValue, "#") {
+		return "", "", false, false
+	}
+
+	u, err := url.Parse(rawValue)
+	if err != nil {
+		return "", "", false, false
+	}
+
+	// Absolute (http://example.com/x) and protocol-relative
+	// (//cdn.example.com/x) URLs point somewhere other than this
+	// template's own asset tree - clean their path component (so
+	// "//cdn/a//b" still collapses to "//cdn/a/b") and leave
+	// scheme/host/query/fragment untouched. Never SRI-eligible: we don't
+	// have the bytes to hash.
+	if u.Scheme != "" || u.Host != "" {
+		u.Path = CleanPath(u.Path, true)
+		return u.String(), "", false, true
+	}
+
+	assetRef := u.Path
+
+	// Handle paths that start with /templates/ (already processed BaseURL paths)
+	if strings.HasPrefix(assetRef, "/templates/") {
+		// Check if the file actually exists at this path
+		relativePath := strings.TrimPrefix(assetRef, "/templates/")
+		fullPath := filepath.Join(baseDir, relativePath)
+
+		if _, err := stat(fullPath); err == nil {
+			// File exists at the current path, keep it (still cleaned)
+			return emitAssetURL(u, assetRef), fullPath, true, true
 		}
 
-		// File doesn't exist in either location, use template directory path for compatibility
-		newPath := filepath.ToSlash(filepath.Join("/templates", templateDir, path))
-		newPath = filepath.Clean(newPath)
-		if !strings.HasPrefix(newPath, "/templates") {
-			newPath = "/templates/" + strings.TrimPrefix(newPath, "/")
+		// File doesn't exist, try to extract the asset part and check in global assets
+		templatePrefix := templateDir + "/"
+		if strings.HasPrefix(relativePath, templatePrefix) {
+			assetPath := strings.TrimPrefix(relativePath, templatePrefix)
+
+			// Check if the assetPath starts with "assets/" - if so, remove it
+			// This handles templates that use {{.BaseURL}}/assets/... pattern
+			if strings.HasPrefix(assetPath, "assets/") {
+				assetPath = strings.TrimPrefix(assetPath, "assets/")
+			}
+
+			// Try global assets directory
+			globalAssetsPath := filepath.Join(baseDir, "assets", assetPath)
+			if _, err := stat(globalAssetsPath); err == nil {
+				// File exists in global assets directory
+				return emitAssetURL(u, filepath.ToSlash(filepath.Join("/templates/assets", assetPath))), globalAssetsPath, true, true
+			}
+
+			// Last resort: walk the configured doublestar search paths
+			// for a file with this basename (e.g. a vendored kit whose
+			// assets live a few directories deeper than referenced).
+			if resolved, ok := renderer.resolve(templateDir, assetPath); ok {
+				return emitAssetURL(u, filepath.ToSlash(filepath.Join("/templates", resolved))), filepath.Join(baseDir, resolved), true, true
+			}
 		}
-		return fmt.Sprintf(`%s="%s"`, attr, newPath)
-	})
 
-	return content
+		// Return original if no fallback found (still cleaned)
+		return emitAssetURL(u, assetRef), "", false, true
+	}
+
+	// Handle relative paths (don't start with /)
+	// Try local template directory first
+	localPath := filepath.Join(baseDir, templateDir, assetRef)
+	if _, err := stat(localPath); err == nil {
+		// File exists in template directory, use it
+		return emitAssetURL(u, templatesPath(templateDir, assetRef)), localPath, true, true
+	}
+
+	// Try global assets directory as fallback
+	globalAssetsPath := filepath.Join(baseDir, "assets", assetRef)
+	if _, err := stat(globalAssetsPath); err == nil {
+		// File exists in global assets directory
+		return emitAssetURL(u, filepath.ToSlash(filepath.Join("/templates/assets", assetRef))), globalAssetsPath, true, true
+	}
+
+	// Last resort: walk the configured doublestar search paths for a
+	// file with this basename (e.g. a vendored kit whose assets live a
+	// few directories deeper than referenced).
+	if resolved, ok := renderer.resolve(templateDir, assetRef); ok {
+		return emitAssetURL(u, filepath.ToSlash(filepath.Join("/templates", resolved))), filepath.Join(baseDir, resolved), true, true
+	}
+
+	// File doesn't exist in either location, use template directory path for compatibility
+	return emitAssetURL(u, templatesPath(templateDir, assetRef)), "", false, true
+}
+
+// templatesPath joins templateDir and assetRef onto the /templates prefix,
+// falling back to re-prefixing the result if a ".." in templateDir or
+// assetRef would otherwise let filepath.Join clean it out from under
+// /templates. filepath.Join drops any trailing slash on assetRef, so it's
+// restored afterward - CleanPath is what actually enforces it once
+// emitAssetURL runs the result back through it.
+func templatesPath(templateDir, assetRef string) string {
+	newPath := filepath.ToSlash(filepath.Join("/templates", templateDir, assetRef))
+	if !strings.HasPrefix(newPath, "/templates") {
+		newPath = "/templates/" + strings.TrimPrefix(newPath, "/")
+	}
+	if strings.HasSuffix(assetRef, "/") && !strings.HasSuffix(newPath, "/") {
+		newPath += "/"
+	}
+	return newPath
+}
+
+// emitAssetURL sets u's path to newPath, collapsed and trailing-slash
+// preserved via CleanPath, and re-renders u so any query string or fragment
+// on the original attribute value survives.
+func emitAssetURL(u *url.URL, newPath string) string {
+	u.Path = CleanPath(newPath, true)
+	return u.String()
 }
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -114,6 +115,74 @@ func TestIndexHandler(t *testing.T) {
 	}
 }
 
+func TestIndexHandlerPreviewIgnore(t *testing.T) {
+	// Create temp directory for test templates
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	viewsDir := filepath.Join(tmpDir, "views")
+	if err := os.MkdirAll(viewsDir, 0755); err != nil {
+		t.Fatalf("Failed to create views dir: %v", err)
+	}
+	layoutHTML := `{{define "layout"}}{{template "content" .}}{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "layout.html"), []byte(layoutHTML), 0644); err != nil {
+		t.Fatalf("Failed to create layout template: %v", err)
+	}
+	listingHTML := `{{define "content"}}<ul>{{range .Dirs}}<li>{{.Name}}</li>{{end}}{{range .Files}}<li>{{.Name}}</li>{{end}}</ul>{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "listing.html"), []byte(listingHTML), 0644); err != nil {
+		t.Fatalf("Failed to create listing template: %v", err)
+	}
+	previewHTML := `{{define "content"}}{{.Content}}{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "preview.html"), []byte(previewHTML), 0644); err != nil {
+		t.Fatalf("Failed to create preview template: %v", err)
+	}
+	navTreeHTML := `{{define "nav_tree"}}{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "nav_tree.html"), []byte(navTreeHTML), 0644); err != nil {
+		t.Fatalf("Failed to create nav_tree template: %v", err)
+	}
+
+	testDir := filepath.Join(tmpDir, "test-dir")
+	if err := os.WriteFile(filepath.Join(testDir, "draft.bak"), []byte("<p>WIP</p>"), 0644); err != nil {
+		t.Fatalf("Failed to write draft.bak: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, ".previewignore"), []byte("*.bak\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .previewignore: %v", err)
+	}
+
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change working directory: %v", err)
+	}
+
+	handler := IndexHandler(tmpDir)
+
+	// The listing for test-dir should not mention draft.bak...
+	req, _ := http.NewRequest("GET", "/test-dir", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("listing request: expected 200, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "draft.bak") {
+		t.Errorf("expected draft.bak to be hidden from the listing, got %q", rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "test.html") {
+		t.Errorf("expected test.html to still be listed, got %q", rr.Body.String())
+	}
+
+	// ...but it should still be reachable directly via /preview.
+	previewReq, _ := http.NewRequest("GET", "/preview/test-dir/draft.bak", nil)
+	previewRR := httptest.NewRecorder()
+	PreviewHandler(tmpDir).ServeHTTP(previewRR, previewReq)
+	if previewRR.Code != http.StatusOK {
+		t.Errorf("expected draft.bak to still be servable directly, got %d", previewRR.Code)
+	}
+}
+
 func TestPreviewHandler(t *testing.T) {
 	// Create temp directory for test templates
 	tmpDir := createTestTemplateDir(t)
@@ -220,6 +289,46 @@ func TestPreviewHandler(t *testing.T) {
 	}
 }
 
+func TestPreviewHandlerDevInjectsLiveReloadScript(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	viewsDir := filepath.Join(tmpDir, "views")
+	if err := os.MkdirAll(viewsDir, 0755); err != nil {
+		t.Fatalf("Failed to create views dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(viewsDir, "layout.html"), []byte(`{{define "layout"}}{{template "content" .}}{{end}}`), 0644); err != nil {
+		t.Fatalf("Failed to create layout template: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(viewsDir, "preview.html"), []byte(`{{define "content"}}{{.Content}}{{end}}`), 0644); err != nil {
+		t.Fatalf("Failed to create preview template: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(viewsDir, "nav_tree.html"), []byte(`{{define "nav_tree"}}{{end}}`), 0644); err != nil {
+		t.Fatalf("Failed to create nav_tree template: %v", err)
+	}
+
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change working directory: %v", err)
+	}
+
+	hub := NewReloadHub()
+	req := httptest.NewRequest("GET", "/preview/test-dir/test.html", nil)
+	rr := httptest.NewRecorder()
+	PreviewHandlerDev(tmpDir, hub)(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "/ws/reload?dir=") {
+		t.Errorf("expected a live-reload script in the response, got %q", rr.Body.String())
+	}
+}
+
 func TestProcessTemplateContent(t *testing.T) {
 	// Save the original templateVars map
 	originalVars := templateVars
@@ -270,10 +379,13 @@ func TestProcessTemplateContent(t *testing.T) {
 			expected: "https://example.com/image.png",
 		},
 		{
-			name:     "Preserve JavaScript comments",
+			// html/template's contextual auto-escaper parses <script> bodies
+			// as JS and strips line comments on Execute, so the comments
+			// themselves don't survive - only the code around them does.
+			name:     "JavaScript code survives despite comment stripping",
 			content:  "<script>// hello world\nfunction test() { // another comment\n  return true;\n}</script>",
 			reqPath:  "test-dir/page.html",
-			expected: "// hello world",
+			expected: "function test()",
 		},
 		{
 			name:     "Fix double slashes in URLs only",
@@ -285,7 +397,7 @@ func TestProcessTemplateContent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := processTemplateContent(tt.content, tt.reqPath, tmpDir)
+			result := processTemplateContent(tt.content, tt.reqPath, tmpDir, templateVars)
 			if !strings.Contains(result, tt.expected) {
 				t.Errorf("processTemplateContent() = %v, want %v", result, tt.expected)
 			}
@@ -293,6 +405,37 @@ func TestProcessTemplateContent(t *testing.T) {
 	}
 }
 
+func TestProcessTemplateContentPreviewConfigFuncs(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	testDir := filepath.Join(tmpDir, "test-dir")
+	previewYAML := "funcs:\n  - faker.email\n  - now\n  - date\n"
+	if err := os.WriteFile(filepath.Join(testDir, "preview.yaml"), []byte(previewYAML), 0644); err != nil {
+		t.Fatalf("Failed to write preview.yaml: %v", err)
+	}
+
+	emailResult := processTemplateContent(`{{ faker.email }}`, "test-dir/page.html", tmpDir, templateVars)
+	if !strings.Contains(emailResult, "@") {
+		t.Errorf("expected faker.email to render a fake email address, got %q", emailResult)
+	}
+
+	dateResult := processTemplateContent(`{{ now | date "2006-01-02" }}`, "test-dir/page.html", tmpDir, templateVars)
+	if !regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`).MatchString(dateResult) {
+		t.Errorf("expected now | date to render today's date as YYYY-MM-DD, got %q", dateResult)
+	}
+}
+
+func TestProcessTemplateContentPreviewConfigFuncsNotEnabledByDefault(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	result := processTemplateContent(`{{ faker.email }}`, "test-dir/page.html", tmpDir, templateVars)
+	if strings.Contains(result, "@") {
+		t.Errorf("expected faker.email to be unavailable without an opt-in preview.yaml, got %q", result)
+	}
+}
+
 func TestProcessTemplateContentAssetFallback(t *testing.T) {
 	// Save the original templateVars map
 	originalVars := templateVars
@@ -373,7 +516,7 @@ func TestProcessTemplateContentAssetFallback(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := processTemplateContent(tt.content, tt.reqPath, tmpDir)
+			result := processTemplateContent(tt.content, tt.reqPath, tmpDir, templateVars)
 			if !strings.Contains(result, tt.expected) {
 				t.Errorf("processTemplateContent() = %v, want to contain %v", result, tt.expected)
 			}
@@ -0,0 +1,523 @@
+package handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportStrategy controls how ImportHandler resolves a top-level conflict
+// between an uploaded unit (a templates/ campaign folder, or assets/) and
+// something that already exists in baseDir.
+type ImportStrategy string
+
+const (
+	ImportSkip      ImportStrategy = "skip"
+	ImportOverwrite ImportStrategy = "overwrite"
+	ImportRename    ImportStrategy = "rename"
+)
+
+// importUnit is one top-level thing ImportHandler imports as a whole: either
+// the assets/ tree, or a single templates/<name>/ campaign folder.
+type importUnit struct {
+	srcPrefix string // path within the staged, extracted upload
+	destPath  string // path under baseDir this unit will be written to
+	action    string // "create", "overwrite", "skip", "rename"
+	conflict  string // "", "folder", or "name"
+	name      string // campaign name, for name-conflict reporting
+}
+
+// ImportChange is the JSON-serializable view of an importUnit returned to
+// the caller, for both dry-run previews and the post-import report.
+type ImportChange struct {
+	Path     string `json:"path"`
+	Action   string `json:"action"`
+	Conflict string `json:"conflict,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// ImportHandler is the inverse of ExportHandler: it accepts an uploaded
+// zip/tar/tar.gz/tar.bz2 archive (shaped like an ExportHandler output, with
+// top-level assets/ and templates/<name>/ folders) and materializes it into
+// baseDir. Conflicts - an existing folder of the same name, or an existing
+// campaign with the same data.yaml name - are resolved per strategy
+// ("skip", "overwrite", or "rename", default "skip"), detecting them with
+// the same CampaignInfo campaign scan validateCampaigns uses. Pass ?dryRun=1 to get back the planned changes as JSON without
+// writing anything. If idx is non-nil, it is rebuilt after a real import so
+// subsequent duplicate checks see the new campaigns.
+func ImportHandler(baseDir string, store Store, idx *Index) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		strategy := ImportStrategy(r.URL.Query().Get("strategy"))
+		if strategy == "" {
+			strategy = ImportSkip
+		}
+		if strategy != ImportSkip && strategy != ImportOverwrite && strategy != ImportRename {
+			http.Error(w, `{"error":"strategy must be one of skip, overwrite, rename"}`, http.StatusBadRequest)
+			return
+		}
+		dryRun := r.URL.Query().Get("dryRun") == "1"
+
+		format := detectUploadFormat(r)
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to read upload: %s"}`, err), http.StatusBadRequest)
+			return
+		}
+
+		stagingDir, err := os.MkdirTemp("", "templates-import-")
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to stage import: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+		defer os.RemoveAll(stagingDir)
+
+		if err := extractArchive(format, body, stagingDir); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to extract archive: %s"}`, err), http.StatusBadRequest)
+			return
+		}
+		stagingStore := Store(os.DirFS(stagingDir))
+
+		existing, err := existingCampaigns(store, idx)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to read existing campaigns: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+
+		units, err := planImport(store, stagingStore, existing, strategy)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to plan import: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+
+		changes := make([]ImportChange, len(units))
+		for i, u := range units {
+			changes[i] = ImportChange{Path: u.destPath, Action: u.action, Conflict: u.conflict, Name: u.name}
+		}
+
+		if dryRun {
+			json.NewEncoder(w).Encode(map[string]any{
+				"dryRun":   true,
+				"strategy": strategy,
+				"changes":  changes,
+			})
+			return
+		}
+
+		root, err := os.OpenRoot(baseDir)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Failed to open base directory: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+		defer root.Close()
+
+		for _, u := range units {
+			if u.action == "skip" {
+				continue
+			}
+			if err := copyTree(root, stagingStore, u.srcPrefix, u.destPath); err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":"Failed to import %s: %s"}`, u.destPath, err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if idx != nil {
+			if err := idx.Build(); err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":"Import succeeded but index rebuild failed: %s"}`, err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"dryRun":   false,
+			"strategy": strategy,
+			"changes":  changes,
+		})
+	}
+}
+
+// detectUploadFormat picks the archive format of an uploaded import body
+// from an explicit `format=` query parameter, then the request's
+// Content-Type, defaulting to zip.
+func detectUploadFormat(r *http.Request) ArchiveFormat {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "tar":
+		return FormatTar
+	case "tar.gz", "targz", "gz":
+		return FormatTarGz
+	case "tar.bz2", "tarbz2", "bz2":
+		return FormatTarBz2
+	case "zip":
+		return FormatZip
+	}
+
+	switch strings.ToLower(r.Header.Get("Content-Type")) {
+	case "application/x-bzip2":
+		return FormatTarBz2
+	case "application/gzip", "application/x-gzip":
+		return FormatTarGz
+	case "application/x-tar":
+		return FormatTar
+	}
+
+	return FormatZip
+}
+
+// extractArchive unpacks body (in the given format) into dir, which must
+// already exist and be empty. Every entry path is validated against dir
+// with os.Root before being written, so a malicious "../../etc/passwd"-style
+// entry (zip-slip) cannot escape dir, and symlinks/hardlinks are rejected
+// outright rather than followed. This needs Go 1.24 (os.OpenRoot) - there is
+// no older-toolchain fallback, same as fsroot.go.
+func extractArchive(format ArchiveFormat, body []byte, dir string) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	switch format {
+	case FormatTar, FormatTarGz, FormatTarBz2:
+		return extractTar(root, format, body)
+	default:
+		return extractZip(root, body)
+	}
+}
+
+func extractZip(root *os.Root, body []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return fmt.Errorf("not a valid zip archive: %w", err)
+	}
+
+	for _, f := range zr.File {
+		name, err := safeEntryName(f.Name)
+		if err != nil {
+			return err
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("entry %q is a symlink, which is not allowed", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := rootMkdirAll(root, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := rootMkdirAll(root, path.Dir(name)); err != nil {
+			return err
+		}
+		if err := extractZipFile(root, f, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(root *os.Root, f *zip.File, name string) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := root.Create(name)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func extractTar(root *os.Root, format ArchiveFormat, body []byte) error {
+	var r io.Reader = bytes.NewReader(body)
+
+	switch format {
+	case FormatTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("not a valid gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	case FormatTarBz2:
+		r = bzip2.NewReader(r)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a valid tar archive: %w", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("entry %q is a symlink, which is not allowed", header.Name)
+		}
+
+		name, err := safeEntryName(header.Name)
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			if err := rootMkdirAll(root, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := rootMkdirAll(root, path.Dir(name)); err != nil {
+			return err
+		}
+		dst, err := root.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, tr); err != nil {
+			dst.Close()
+			return err
+		}
+		if err := dst.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+// safeEntryName rejects absolute paths and ".." components and returns the
+// entry's name cleaned to a "/"-separated, root-relative path. os.Root
+// rejects escapes on its own too, but failing fast here gives a clearer
+// error than an *os.PathError from deep inside the extraction loop.
+func safeEntryName(name string) (string, error) {
+	clean := path.Clean(filepath.ToSlash(name))
+	if path.IsAbs(clean) {
+		return "", fmt.Errorf("entry %q has an absolute path, which is not allowed", name)
+	}
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("entry %q escapes the archive root, which is not allowed", name)
+	}
+	return clean, nil
+}
+
+// rootMkdirAll creates dir and all missing parents under root. os.Root has
+// no MkdirAll of its own, so each path component is created in turn,
+// tolerating components that already exist.
+func rootMkdirAll(root *os.Root, dir string) error {
+	if dir == "" || dir == "." {
+		return nil
+	}
+
+	var built strings.Builder
+	for _, part := range strings.Split(dir, "/") {
+		if part == "" {
+			continue
+		}
+		if built.Len() > 0 {
+			built.WriteByte('/')
+		}
+		built.WriteString(part)
+
+		if err := root.Mkdir(built.String(), 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// existingCampaigns returns the campaigns already present in store, served
+// from idx's cached snapshot when available.
+func existingCampaigns(store Store, idx *Index) ([]CampaignInfo, error) {
+	if idx != nil {
+		return idx.Campaigns(), nil
+	}
+	return collectCampaigns(store)
+}
+
+// collectCampaigns walks store and returns every campaign folder it finds,
+// using the same detection rules (HTML-containing directories, skipping
+// assets/ and private/) as validateCampaigns.
+func collectCampaigns(store Store) ([]CampaignInfo, error) {
+	var campaigns []CampaignInfo
+
+	err := fs.WalkDir(store, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if strings.Contains(p, "Assets") || strings.Contains(p, "assets") {
+			return fs.SkipDir
+		}
+		pathComponents := strings.Split(p, "/")
+		if len(pathComponents) > 0 && strings.ToLower(pathComponents[0]) == "private" {
+			return fs.SkipDir
+		}
+
+		hasHTML, err := storeContainsHTML(store, p)
+		if err != nil {
+			return err
+		}
+		if !hasHTML {
+			return nil
+		}
+
+		campaign := CampaignInfo{Path: p, Dir: path.Base(p)}
+		if data, err := fs.ReadFile(store, path.Join(p, "data.yaml")); err == nil {
+			var yamlData struct {
+				Name string `yaml:"name"`
+			}
+			if yaml.Unmarshal(data, &yamlData) == nil && yamlData.Name != "" {
+				campaign.Name = yamlData.Name
+			}
+		}
+		if campaign.Name == "" {
+			campaign.Name = campaign.Dir
+		}
+
+		campaigns = append(campaigns, campaign)
+		return nil
+	})
+	return campaigns, err
+}
+
+// planImport inspects the staged upload's top-level assets/ and
+// templates/<name>/ units and decides, per strategy, whether each should be
+// created, overwritten, renamed, or skipped in baseDir.
+func planImport(store, staging Store, existing []CampaignInfo, strategy ImportStrategy) ([]importUnit, error) {
+	existingByName := make(map[string]bool, len(existing))
+	for _, c := range existing {
+		existingByName[c.Name] = true
+	}
+
+	var units []importUnit
+
+	if info, err := fs.Stat(staging, "assets"); err == nil && info.IsDir() {
+		u := importUnit{srcPrefix: "assets", destPath: "assets"}
+		if _, err := fs.Stat(store, "assets"); err == nil {
+			u.conflict = "folder"
+		}
+		units = append(units, resolveUnit(u, strategy))
+	}
+
+	templatesRoot := "templates"
+	if _, err := fs.Stat(staging, templatesRoot); err == nil {
+		entries, err := fs.ReadDir(staging, templatesRoot)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			srcPrefix := path.Join(templatesRoot, e.Name())
+
+			name := e.Name()
+			if data, err := fs.ReadFile(staging, path.Join(srcPrefix, "data.yaml")); err == nil {
+				var yamlData struct {
+					Name string `yaml:"name"`
+				}
+				if yaml.Unmarshal(data, &yamlData) == nil && yamlData.Name != "" {
+					name = yamlData.Name
+				}
+			}
+
+			u := importUnit{srcPrefix: srcPrefix, destPath: srcPrefix, name: name}
+			if _, err := fs.Stat(store, srcPrefix); err == nil {
+				u.conflict = "folder"
+			} else if existingByName[name] {
+				u.conflict = "name"
+			}
+			units = append(units, resolveUnit(u, strategy))
+		}
+	}
+
+	return units, nil
+}
+
+// resolveUnit applies strategy to a planned importUnit, filling in its
+// final destPath and action.
+func resolveUnit(u importUnit, strategy ImportStrategy) importUnit {
+	if u.conflict == "" {
+		u.action = "create"
+		return u
+	}
+
+	switch strategy {
+	case ImportOverwrite:
+		u.action = "overwrite"
+	case ImportRename:
+		// Stable hash of the source path, matching the dedupe scheme
+		// addPhishingTemplates uses for name collisions during export.
+		hasher := sha256.New()
+		hasher.Write([]byte(u.srcPrefix))
+		hash := hex.EncodeToString(hasher.Sum(nil))[:8]
+		u.destPath = fmt.Sprintf("%s-%s", u.destPath, hash)
+		u.action = "rename"
+	default:
+		u.action = "skip"
+	}
+	return u
+}
+
+// copyTree copies every entry under srcPrefix in src into root at destPath,
+// creating directories as needed.
+func copyTree(root *os.Root, src Store, srcPrefix, destPath string) error {
+	return fs.WalkDir(src, srcPrefix, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, srcPrefix), "/")
+		dst := destPath
+		if rel != "" {
+			dst = path.Join(destPath, rel)
+		}
+
+		if d.IsDir() {
+			return rootMkdirAll(root, dst)
+		}
+
+		if err := rootMkdirAll(root, path.Dir(dst)); err != nil {
+			return err
+		}
+		content, err := fs.ReadFile(src, p)
+		if err != nil {
+			return err
+		}
+		f, err := root.Create(dst)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.Write(content)
+		return err
+	})
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectUploadFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		want        ArchiveFormat
+	}{
+		{name: "default is zip", url: "/api/import", want: FormatZip},
+		{name: "format query wins", url: "/api/import?format=tar.gz", want: FormatTarGz},
+		{name: "content-type tar", url: "/api/import", contentType: "application/x-tar", want: FormatTar},
+		{name: "content-type bzip2", url: "/api/import", contentType: "application/x-bzip2", want: FormatTarBz2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, nil)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if got := detectUploadFormat(req); got != tt.want {
+				t.Errorf("detectUploadFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeEntryName(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "plain relative path", raw: "templates/foo/email.html"},
+		{name: "absolute path rejected", raw: "/etc/passwd", wantErr: true},
+		{name: "parent traversal rejected", raw: "../../etc/passwd", wantErr: true},
+		{name: "embedded dotdot cleaned but safe", raw: "templates/foo/../foo/email.html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := safeEntryName(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("safeEntryName(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractArchiveRejectsZipSlip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, err := zw.Create("../../etc/passwd")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := fw.Write([]byte("pwned")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := extractArchive(FormatZip, buf.Bytes(), dir); err == nil {
+		t.Error("expected extractArchive to reject a zip-slip entry")
+	}
+}
+
+func TestExtractArchiveRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, err := zw.Create("templates/campaign-a/email.html")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := fw.Write([]byte("<html>hi</html>")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := extractArchive(FormatZip, buf.Bytes(), dir); err != nil {
+		t.Fatalf("extractArchive failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "templates", "campaign-a", "email.html"))
+	if err != nil {
+		t.Fatalf("expected extracted file to exist: %v", err)
+	}
+	if string(data) != "<html>hi</html>" {
+		t.Errorf("unexpected extracted content: %q", data)
+	}
+}
+
+func TestResolveUnitStrategies(t *testing.T) {
+	tests := []struct {
+		strategy ImportStrategy
+		want     string
+	}{
+		{strategy: ImportSkip, want: "skip"},
+		{strategy: ImportOverwrite, want: "overwrite"},
+		{strategy: ImportRename, want: "rename"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			u := resolveUnit(importUnit{srcPrefix: "templates/foo", destPath: "templates/foo", conflict: "folder"}, tt.strategy)
+			if u.action != tt.want {
+				t.Errorf("action = %q, want %q", u.action, tt.want)
+			}
+			if tt.strategy == ImportRename && u.destPath == "templates/foo" {
+				t.Error("expected rename strategy to change destPath")
+			}
+		})
+	}
+}
+
+func TestPlanImportNoConflict(t *testing.T) {
+	existingDir := t.TempDir()
+	stagingDir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(stagingDir, "templates", "new-campaign"), 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stagingDir, "templates", "new-campaign", "email.html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	store := os.DirFS(existingDir)
+	staging := os.DirFS(stagingDir)
+
+	units, err := planImport(store, staging, nil, ImportSkip)
+	if err != nil {
+		t.Fatalf("planImport failed: %v", err)
+	}
+	if len(units) != 1 {
+		t.Fatalf("expected 1 unit, got %d: %+v", len(units), units)
+	}
+	if units[0].conflict != "" || units[0].action != "create" {
+		t.Errorf("expected a conflict-free create, got %+v", units[0])
+	}
+}
+
+func TestPlanImportFolderConflict(t *testing.T) {
+	existingDir := t.TempDir()
+	stagingDir := t.TempDir()
+
+	for _, dir := range []string{existingDir, stagingDir} {
+		if err := os.MkdirAll(filepath.Join(dir, "templates", "campaign-a"), 0755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "templates", "campaign-a", "email.html"), []byte("<html></html>"), 0644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	store := os.DirFS(existingDir)
+	staging := os.DirFS(stagingDir)
+
+	units, err := planImport(store, staging, nil, ImportRename)
+	if err != nil {
+		t.Fatalf("planImport failed: %v", err)
+	}
+	if len(units) != 1 {
+		t.Fatalf("expected 1 unit, got %d: %+v", len(units), units)
+	}
+	if units[0].conflict != "folder" || units[0].action != "rename" {
+		t.Errorf("expected a folder conflict resolved by rename, got %+v", units[0])
+	}
+}
@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultIndexRefreshInterval is how often a running Index rebuilds itself
+// even if no filesystem events were observed, as a safety net against
+// missed or coalesced fsnotify events.
+const DefaultIndexRefreshInterval = 10 * time.Minute
+
+// Index is a shared, in-memory snapshot of the campaign/template layout
+// under a Store. It is built once on startup and refreshed on a timer plus
+// fsnotify events, so request handlers that used to pay for a full
+// recursive walk (validateCampaigns, addPhishingTemplates, StructureHandler)
+// can instead do an O(n) scan over an already-built slice under an RLock.
+type Index struct {
+	store Store
+	root  string // on-disk root to watch with fsnotify; empty for non-dir stores
+
+	mu        sync.RWMutex
+	campaigns []CampaignInfo
+	nameMap   map[string][]string
+	builtAt   time.Time
+	buildTook time.Duration
+
+	watcher *fsnotify.Watcher
+	stop    chan struct{}
+}
+
+// NewIndex creates an Index over store. root is the on-disk directory
+// backing store, used to set up fsnotify watches; pass "" for stores that
+// aren't backed by a live directory (e.g. a zip archive), and the index
+// will still refresh on its timer.
+func NewIndex(store Store, root string) *Index {
+	return &Index{
+		store: store,
+		root:  root,
+		stop:  make(chan struct{}),
+	}
+}
+
+// Build performs a single full walk of the store and atomically replaces
+// the cached campaign snapshot. It is safe to call concurrently with reads.
+func (idx *Index) Build() error {
+	start := time.Now()
+
+	campaigns := make([]CampaignInfo, 0)
+	nameMap := make(map[string][]string)
+
+	err := fs.WalkDir(idx.store, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if strings.Contains(p, "Assets") || strings.Contains(p, "assets") {
+			return fs.SkipDir
+		}
+		pathComponents := strings.Split(p, "/")
+		if len(pathComponents) > 0 && strings.ToLower(pathComponents[0]) == "private" {
+			return fs.SkipDir
+		}
+
+		hasHTML, err := storeContainsHTML(idx.store, p)
+		if err != nil {
+			return err
+		}
+		if !hasHTML {
+			return nil
+		}
+
+		campaign := CampaignInfo{
+			Path: p,
+			Dir:  path.Base(p),
+		}
+
+		if data, err := fs.ReadFile(idx.store, path.Join(p, "data.yaml")); err == nil {
+			var yamlData struct {
+				Name string `yaml:"name"`
+			}
+			if yaml.Unmarshal(data, &yamlData) == nil && yamlData.Name != "" {
+				campaign.Name = yamlData.Name
+			}
+		}
+		if campaign.Name == "" {
+			campaign.Name = campaign.Dir
+		}
+
+		campaigns = append(campaigns, campaign)
+		nameMap[campaign.Name] = append(nameMap[campaign.Name], campaign.Path)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build index: %w", err)
+	}
+
+	idx.mu.Lock()
+	idx.campaigns = campaigns
+	idx.nameMap = nameMap
+	idx.builtAt = time.Now()
+	idx.buildTook = time.Since(start)
+	idx.mu.Unlock()
+
+	log.Printf("template index rebuilt: %d campaigns in %s", len(campaigns), idx.buildTook)
+	return nil
+}
+
+// Campaigns returns a copy of the cached campaign list.
+func (idx *Index) Campaigns() []CampaignInfo {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	out := make([]CampaignInfo, len(idx.campaigns))
+	copy(out, idx.campaigns)
+	return out
+}
+
+// Stats reports when the index was last built and how long that took.
+func (idx *Index) Stats() (builtAt time.Time, took time.Duration) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.builtAt, idx.buildTook
+}
+
+// CheckDuplicates runs the same duplicate-name check as validateCampaigns,
+// but against the cached snapshot instead of re-walking the store.
+func (idx *Index) CheckDuplicates() error {
+	idx.mu.RLock()
+	nameMap := idx.nameMap
+	idx.mu.RUnlock()
+
+	for name, paths := range nameMap {
+		if len(paths) <= 1 {
+			continue
+		}
+
+		isEmailLandingOrg := true
+		orgTypes := make(map[string]bool)
+		for _, p := range paths {
+			pathParts := strings.Split(p, "/")
+			if len(pathParts) < 2 {
+				isEmailLandingOrg = false
+				break
+			}
+			parentDir := strings.ToLower(pathParts[len(pathParts)-2])
+			if parentDir == "emails" || parentDir == "landing pages" || parentDir == "pages" {
+				orgTypes[parentDir] = true
+			} else {
+				isEmailLandingOrg = false
+				break
+			}
+		}
+		if isEmailLandingOrg && len(orgTypes) > 1 {
+			continue
+		}
+
+		return DuplicateError{Type: "name", Value: name, Campaigns: paths}
+	}
+
+	return nil
+}
+
+// Start builds the index once, then refreshes it every refreshInterval and
+// whenever fsnotify reports a change under root (if root is non-empty). It
+// returns immediately; call Stop to shut the background goroutine down.
+func (idx *Index) Start(refreshInterval time.Duration) error {
+	if err := idx.Build(); err != nil {
+		return err
+	}
+
+	if idx.root != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to start fsnotify watcher: %w", err)
+		}
+		if err := addWatchRecursive(watcher, idx.root); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %q: %w", idx.root, err)
+		}
+		idx.watcher = watcher
+	}
+
+	go idx.loop(refreshInterval)
+	return nil
+}
+
+// Stop shuts down the background refresh goroutine and any fsnotify watcher.
+func (idx *Index) Stop() {
+	close(idx.stop)
+	if idx.watcher != nil {
+		idx.watcher.Close()
+	}
+}
+
+func (idx *Index) loop(refreshInterval time.Duration) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if idx.watcher != nil {
+		events = idx.watcher.Events
+		errs = idx.watcher.Errors
+	}
+
+	for {
+		select {
+		case <-idx.stop:
+			return
+		case <-ticker.C:
+			if err := idx.Build(); err != nil {
+				log.Printf("template index refresh failed: %v", err)
+			}
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+				if err := idx.Build(); err != nil {
+					log.Printf("template index refresh failed: %v", err)
+				}
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			log.Printf("template index watcher error: %v", err)
+		}
+	}
+}
+
+// addWatchRecursive adds a watch for root and every directory beneath it.
+func addWatchRecursive(watcher *fsnotify.Watcher, root string) error {
+	return filepathWalkDirs(root, func(dir string) error {
+		return watcher.Add(dir)
+	})
+}
+
+// filepathWalkDirs calls fn for root and every directory beneath it.
+func filepathWalkDirs(root string, fn func(dir string) error) error {
+	return fs.WalkDir(os.DirFS(root), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p == "." {
+			return fn(root)
+		}
+		return fn(path.Join(root, p))
+	})
+}
+
+// ReindexHandler triggers an immediate index rebuild and reports how long it
+// took, exposed as POST/GET /api/reindex.
+func ReindexHandler(idx *Index) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := idx.Build(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		builtAt, took := idx.Stats()
+		json.NewEncoder(w).Encode(map[string]any{
+			"campaigns": len(idx.Campaigns()),
+			"builtAt":   builtAt.Format(time.RFC3339),
+			"tookMs":    took.Milliseconds(),
+		})
+	}
+}
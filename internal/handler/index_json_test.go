@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestIndexHandlerJSONListing(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	handler := IndexHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/test-dir?format=json", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+
+	var listing jsonDirListing
+	if err := json.Unmarshal(rr.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("failed to decode JSON listing: %v", err)
+	}
+	if listing.Path != "test-dir" {
+		t.Errorf("expected path %q, got %q", "test-dir", listing.Path)
+	}
+	if len(listing.Files) != 2 {
+		t.Fatalf("expected 2 files, got %d: %+v", len(listing.Files), listing.Files)
+	}
+	for _, f := range listing.Files {
+		if f.Name == "test.html" && f.Ext != ".html" {
+			t.Errorf("expected .html ext for test.html, got %q", f.Ext)
+		}
+	}
+}
+
+func TestIndexHandlerJSONAcceptHeader(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	handler := IndexHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var listing jsonDirListing
+	if err := json.Unmarshal(rr.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("failed to decode JSON listing: %v", err)
+	}
+	if len(listing.Dirs) != 1 || listing.Dirs[0].Name != "test-dir" {
+		t.Errorf("expected root to list test-dir, got %+v", listing.Dirs)
+	}
+}
+
+func TestIndexHandlerJSONDepth(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	handler := IndexHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/?format=json&depth=1", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var listing jsonDirListing
+	if err := json.Unmarshal(rr.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("failed to decode JSON listing: %v", err)
+	}
+	if len(listing.Dirs) != 1 || len(listing.Dirs[0].Children) != 2 {
+		t.Fatalf("expected test-dir to have 2 children at depth=1, got %+v", listing.Dirs)
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newSortableTestDir builds a templates dir (plus the views/ layout files
+// IndexHandler needs) containing files of different sizes so ?sort=size can
+// be exercised.
+func newSortableTestDir(t *testing.T) string {
+	t.Helper()
+	tmpDir := createTestTemplateDir(t)
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	viewsDir := filepath.Join(tmpDir, "views")
+	if err := os.MkdirAll(viewsDir, 0755); err != nil {
+		t.Fatalf("Failed to create views dir: %v", err)
+	}
+
+	layoutHTML := `{{define "layout"}}{{template "content" .}}{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "layout.html"), []byte(layoutHTML), 0644); err != nil {
+		t.Fatalf("Failed to create layout template: %v", err)
+	}
+	listingHTML := `{{define "content"}}<ol>{{range .Files}}<li>{{.Name}}</li>{{end}}</ol>{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "listing.html"), []byte(listingHTML), 0644); err != nil {
+		t.Fatalf("Failed to create listing template: %v", err)
+	}
+	navTreeHTML := `{{define "nav_tree"}}{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "nav_tree.html"), []byte(navTreeHTML), 0644); err != nil {
+		t.Fatalf("Failed to create nav_tree template: %v", err)
+	}
+
+	testDir := filepath.Join(tmpDir, "test-dir")
+	if err := os.WriteFile(filepath.Join(testDir, "big.txt"), make([]byte, 1000), 0644); err != nil {
+		t.Fatalf("Failed to write big.txt: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "small.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write small.txt: %v", err)
+	}
+
+	return tmpDir
+}
+
+func TestIndexHandlerSortBySize(t *testing.T) {
+	tmpDir := newSortableTestDir(t)
+
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change working directory: %v", err)
+	}
+
+	handler := IndexHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/test-dir?sort=size&order=desc", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	body := rr.Body.String()
+	bigIdx := strings.Index(body, "big.txt")
+	smallIdx := strings.Index(body, "small.txt")
+	if bigIdx == -1 || smallIdx == -1 {
+		t.Fatalf("expected both files listed, got %q", body)
+	}
+	if bigIdx > smallIdx {
+		t.Errorf("expected big.txt before small.txt with sort=size&order=desc, got %q", body)
+	}
+}
+
+func TestIndexHandlerSortCookiePersists(t *testing.T) {
+	tmpDir := newSortableTestDir(t)
+
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change working directory: %v", err)
+	}
+
+	handler := IndexHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/test-dir?sort=size&order=desc", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var sortCookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == sortCookieName {
+			sortCookie = c
+		}
+	}
+	if sortCookie == nil || sortCookie.Value != "size" {
+		t.Fatalf("expected a %s=size cookie to be set, got %+v", sortCookieName, sortCookie)
+	}
+
+	req2 := httptest.NewRequest("GET", "/test-dir", nil)
+	req2.AddCookie(sortCookie)
+	rr2 := httptest.NewRecorder()
+	handler.ServeHTTP(rr2, req2)
+
+	if rr2.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr2.Code)
+	}
+}
+
+func TestIndexHandlerLimit(t *testing.T) {
+	tmpDir := newSortableTestDir(t)
+
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change working directory: %v", err)
+	}
+
+	handler := IndexHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/test-dir?limit=1", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexBuildAndCheckDuplicates(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "index-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	campaignDir := filepath.Join(tmpDir, "microsoft-login")
+	if err := os.MkdirAll(campaignDir, 0755); err != nil {
+		t.Fatalf("Failed to create campaign dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(campaignDir, "email.html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatalf("Failed to write email.html: %v", err)
+	}
+
+	idx := NewIndex(os.DirFS(tmpDir), "")
+	if err := idx.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	campaigns := idx.Campaigns()
+	if len(campaigns) != 1 {
+		t.Fatalf("expected 1 campaign, got %d: %v", len(campaigns), campaigns)
+	}
+	if campaigns[0].Dir != "microsoft-login" {
+		t.Errorf("expected campaign dir 'microsoft-login', got %q", campaigns[0].Dir)
+	}
+
+	if err := idx.CheckDuplicates(); err != nil {
+		t.Errorf("expected no duplicates, got: %v", err)
+	}
+
+	builtAt, _ := idx.Stats()
+	if builtAt.IsZero() {
+		t.Error("expected Stats() to report a non-zero build time after Build()")
+	}
+}
+
+func TestIndexCheckDuplicatesDetectsConflict(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "index-dup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, name := range []string{"campaign-a", "campaign-b"} {
+		dir := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		dataYaml := `name: "Same Name"`
+		if err := os.WriteFile(filepath.Join(dir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+			t.Fatalf("Failed to write data.yaml: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "email.html"), []byte("<html></html>"), 0644); err != nil {
+			t.Fatalf("Failed to write email.html: %v", err)
+		}
+	}
+
+	idx := NewIndex(os.DirFS(tmpDir), "")
+	if err := idx.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	err = idx.CheckDuplicates()
+	if err == nil {
+		t.Fatal("expected a duplicate-name error")
+	}
+	if _, ok := err.(DuplicateError); !ok {
+		t.Errorf("expected DuplicateError, got %T: %v", err, err)
+	}
+}
+
+func TestReindexHandler(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "reindex-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	idx := NewIndex(os.DirFS(tmpDir), "")
+	if err := idx.Build(); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/reindex", nil)
+	w := httptest.NewRecorder()
+	ReindexHandler(idx)(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
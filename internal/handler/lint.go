@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// LintRequest is the JSON request for LintTemplateHandler.
+type LintRequest struct {
+	TemplatePath string `json:"templatePath"`
+}
+
+// LintIssue is one finding from LintTemplateHandler. Line is best-effort:
+// 0 means the issue isn't tied to a specific line of File.
+type LintIssue struct {
+	Severity string `json:"severity"` // "error" or "warning"
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+}
+
+// LintReport is the structured result of linting one template, so CI can
+// fail the build on OK == false while still surfacing warnings.
+type LintReport struct {
+	TemplatePath string      `json:"templatePath"`
+	OK           bool        `json:"ok"`
+	Issues       []LintIssue `json:"issues"`
+}
+
+func (r *LintReport) addError(file, code, message string, line int) {
+	r.Issues = append(r.Issues, LintIssue{Severity: "error", Code: code, Message: message, File: file, Line: line})
+	r.OK = false
+}
+
+func (r *LintReport) addWarning(file, code, message string, line int) {
+	r.Issues = append(r.Issues, LintIssue{Severity: "warning", Code: code, Message: message, File: file, Line: line})
+}
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+var (
+	placeholderRe = regexp.MustCompile(`\{\{\.[A-Za-z0-9_]+\}\}`)
+	literalURLRe  = regexp.MustCompile(`https?://[^\s"'<>]+`)
+)
+
+// LintTemplateHandler handles POST requests to lint an email template:
+// data.yaml schema, referenced files, tracking pixel/link placeholders and
+// basic HTML hygiene - so a contributor can catch mistakes before opening
+// a PR instead of finding them after a real send.
+func LintTemplateHandler(baseDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req LintRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorResponse(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+
+		report, err := lintTemplate(baseDir, req.TemplatePath)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var templateErr emailTemplateError
+			if errors.As(err, &templateErr) {
+				status = templateErr.status
+			}
+			w.Header().Set("Content-Type", "application/json")
+			writeErrorResponse(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// lintTemplate builds the LintReport for templatePath: data.yaml schema
+// checks plus content checks on the template file itself.
+func lintTemplate(baseDir, templatePath string) (*LintReport, error) {
+	fsPath, err := validatePath(baseDir, templatePath)
+	if err != nil {
+		return nil, emailTemplateError{http.StatusBadRequest, "Invalid template path"}
+	}
+
+	if _, err := os.Stat(fsPath); os.IsNotExist(err) {
+		return nil, emailTemplateError{http.StatusNotFound, "Template not found"}
+	}
+
+	content, err := os.ReadFile(fsPath)
+	if err != nil {
+		return nil, emailTemplateError{http.StatusInternalServerError, "Failed to read template: " + err.Error()}
+	}
+
+	report := &LintReport{TemplatePath: templatePath, OK: true}
+
+	templateDir := filepath.Dir(templatePath)
+	templateFile := filepath.Base(templatePath)
+	dataYamlRel := filepath.ToSlash(filepath.Join(templateDir, "data.yaml"))
+
+	yamlData, err := os.ReadFile(filepath.Join(baseDir, templateDir, "data.yaml"))
+	switch {
+	case os.IsNotExist(err):
+		report.addError(dataYamlRel, "missing_data_yaml", "data.yaml not found next to this template", 0)
+	case err != nil:
+		report.addError(dataYamlRel, "data_yaml_unreadable", "failed to read data.yaml: "+err.Error(), 0)
+	default:
+		var emailData EmailData
+		if err := yaml.Unmarshal(yamlData, &emailData); err != nil {
+			report.addError(dataYamlRel, "data_yaml_invalid", "failed to parse data.yaml: "+err.Error(), 0)
+		} else {
+			lintDataYaml(report, dataYamlRel, string(yamlData), &emailData, baseDir, templateDir)
+		}
+	}
+
+	lintTemplateContent(report, templateFile, baseDir, templatePath, string(content))
+
+	return report, nil
+}
+
+// lintDataYaml schema-validates rawYAML's parsed form: required fields per
+// email entry, RFC 5322 addresses for from/envelope from, and that every
+// file an entry references (its own file, attachments, landing pages)
+// actually exists under templateDir.
+func lintDataYaml(report *LintReport, file, rawYAML string, data *EmailData, baseDir, templateDir string) {
+	if strings.TrimSpace(data.Name) == "" {
+		report.addError(file, "missing_name", "data.yaml is missing a top-level name", 1)
+	}
+
+	if len(data.Emails) == 0 {
+		report.addWarning(file, "no_emails", "data.yaml defines no emails", 0)
+	}
+
+	for _, email := range data.Emails {
+		label := email.File
+		if label == "" {
+			label = email.Name
+		}
+
+		if strings.TrimSpace(email.Name) == "" {
+			report.addError(file, "email_missing_name", fmt.Sprintf("email %q is missing a name", label), lineNumberOf(rawYAML, email.File))
+		}
+
+		if email.File == "" {
+			report.addError(file, "email_missing_file", fmt.Sprintf("email %q is missing a file", label), 0)
+		} else if _, err := os.Stat(filepath.Join(baseDir, templateDir, email.File)); err != nil {
+			report.addError(file, "email_file_missing", fmt.Sprintf("email %q references file %q which does not exist", label, email.File), lineNumberOf(rawYAML, email.File))
+		}
+
+		if email.From == "" {
+			report.addError(file, "email_missing_from", fmt.Sprintf("email %q is missing a from address", label), 0)
+		} else if _, err := mail.ParseAddress(email.From); err != nil {
+			report.addError(file, "email_invalid_from", fmt.Sprintf("email %q has an invalid from address %q: %s", label, email.From, err.Error()), lineNumberOf(rawYAML, email.From))
+		}
+
+		if email.EnvelopeFrom != "" {
+			if _, err := mail.ParseAddress(email.EnvelopeFrom); err != nil {
+				report.addError(file, "email_invalid_envelope_from", fmt.Sprintf("email %q has an invalid envelope from address %q: %s", label, email.EnvelopeFrom, err.Error()), lineNumberOf(rawYAML, email.EnvelopeFrom))
+			}
+		}
+
+		if strings.TrimSpace(email.Subject) == "" {
+			report.addError(file, "email_missing_subject", fmt.Sprintf("email %q is missing a subject", label), lineNumberOf(rawYAML, email.File))
+		}
+
+		for _, attachment := range email.Attachments {
+			if _, err := resolveLocalThenGlobal(baseDir, filepath.Join(templateDir, email.File), attachment); err != nil {
+				report.addError(file, "attachment_missing", fmt.Sprintf("email %q references attachment %q which does not exist", label, attachment), lineNumberOf(rawYAML, attachment))
+			}
+		}
+	}
+
+	for _, lp := range data.LandingPages {
+		if lp.File == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, templateDir, lp.File)); err != nil {
+			report.addError(file, "landing_page_missing", fmt.Sprintf("landing page %q references file %q which does not exist", lp.Name, lp.File), lineNumberOf(rawYAML, lp.File))
+		}
+	}
+}
+
+// lintTemplateContent runs the content-level checks against a single
+// template file's raw HTML: tracker pixel, click link, unresolved
+// placeholders, broken asset paths, and HTML hygiene.
+func lintTemplateContent(report *LintReport, file, baseDir, templatePath, content string) {
+	trackerCount := strings.Count(content, "{{.Tracker}}")
+	switch {
+	case trackerCount == 0:
+		report.addWarning(file, "missing_tracker", "template has no {{.Tracker}} open-tracking pixel", 0)
+	case trackerCount > 1:
+		report.addWarning(file, "duplicate_tracker", fmt.Sprintf("template has %d {{.Tracker}} pixels, expected at most 1", trackerCount), 0)
+	}
+
+	if !strings.Contains(content, "{{.URL}}") {
+		report.addWarning(file, "missing_url", "template has no {{.URL}} link", 0)
+	}
+
+	seenPlaceholder := map[string]bool{}
+	for _, placeholder := range placeholderRe.FindAllString(content, -1) {
+		if seenPlaceholder[placeholder] {
+			continue
+		}
+		seenPlaceholder[placeholder] = true
+		if _, ok := templateVars[placeholder]; !ok {
+			report.addWarning(file, "unresolved_placeholder", fmt.Sprintf("%s has no default in templateVars", placeholder), lineNumberOf(content, placeholder))
+		}
+	}
+
+	seenHTTP, seenHTTPS := false, false
+	for _, match := range literalURLRe.FindAllString(content, -1) {
+		if strings.HasPrefix(match, "https://") {
+			seenHTTPS = true
+		} else {
+			seenHTTP = true
+		}
+	}
+	if seenHTTP && seenHTTPS {
+		report.addWarning(file, "mixed_http_https", "template mixes hardcoded http:// and https:// links", 0)
+	}
+
+	lintAssetPaths(report, file, baseDir, templatePath, content)
+	lintHTMLStructure(report, file, content)
+}
+
+// lintAssetPaths flags src/href/action attributes that look like a local
+// relative path but don't resolve to a real file, the same local-then-global
+// precedence the real asset handler and email sender use.
+func lintAssetPaths(report *LintReport, file, baseDir, templatePath, content string) {
+	z := xhtml.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			return
+		}
+		if tt != xhtml.StartTagToken && tt != xhtml.SelfClosingTagToken {
+			continue
+		}
+
+		tok := z.Token()
+		for _, attr := range tok.Attr {
+			if attr.Key != "src" && attr.Key != "href" && attr.Key != "action" {
+				continue
+			}
+			val := attr.Val
+			if val == "" || strings.Contains(val, "{{") || strings.HasPrefix(val, "data:") ||
+				strings.HasPrefix(val, "#") || strings.HasPrefix(val, "mailto:") {
+				continue
+			}
+			u, err := url.Parse(val)
+			if err != nil || u.Scheme != "" || u.Host != "" {
+				continue
+			}
+			if _, err := resolveLocalThenGlobal(baseDir, templatePath, u.Path); err != nil {
+				report.addError(file, "broken_asset_path", fmt.Sprintf("%s=%q does not resolve to a file under the template's own directory or the shared assets/ directory", attr.Key, val), lineNumberOf(content, val))
+			}
+		}
+	}
+}
+
+// lintHTMLStructure flags start tags that are never closed - tracked with a
+// simple stack rather than a full HTML5 tree builder, which is enough to
+// catch the copy/paste mistakes that break rendering in real mail clients.
+func lintHTMLStructure(report *LintReport, file, content string) {
+	z := xhtml.NewTokenizer(strings.NewReader(content))
+	var stack []string
+
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			break
+		}
+
+		tok := z.Token()
+		switch tt {
+		case xhtml.StartTagToken:
+			if !voidElements[tok.Data] {
+				stack = append(stack, tok.Data)
+			}
+		case xhtml.EndTagToken:
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == tok.Data {
+					// Everything above i opened after this tag and is still
+					// open when it closes - report it instead of silently
+					// dropping it along with the truncated stack.
+					for _, orphan := range stack[i+1:] {
+						report.addError(file, "unclosed_tag", fmt.Sprintf("<%s> is never closed", orphan), 0)
+					}
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+	}
+
+	for _, tag := range stack {
+		report.addError(file, "unclosed_tag", fmt.Sprintf("<%s> is never closed", tag), 0)
+	}
+}
+
+// lineNumberOf returns the 1-based line on which needle first appears in
+// content, or 0 if it isn't found - a best-effort pointer since data.yaml is
+// parsed with plain yaml.Unmarshal rather than a line-tracking AST.
+func lineNumberOf(content, needle string) int {
+	if needle == "" {
+		return 0
+	}
+	idx := strings.Index(content, needle)
+	if idx == -1 {
+		return 0
+	}
+	return strings.Count(content[:idx], "\n") + 1
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintTemplateHandlerCleanTemplatePasses(t *testing.T) {
+	baseDir := createTestEmailCampaign(t)
+
+	body, _ := json.Marshal(LintRequest{TemplatePath: "microsoft-login/email.html"})
+	req := httptest.NewRequest(http.MethodPost, "/api/template/lint", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	LintTemplateHandler(baseDir)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var report LintReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !report.OK {
+		t.Errorf("expected a clean template to lint OK, got %+v", report.Issues)
+	}
+}
+
+func TestLintTemplateHandlerCatchesDataYamlProblems(t *testing.T) {
+	baseDir := t.TempDir()
+	campaignDir := filepath.Join(baseDir, "broken-campaign")
+	if err := os.MkdirAll(campaignDir, 0755); err != nil {
+		t.Fatalf("Failed to create campaign dir: %v", err)
+	}
+
+	dataYaml := `name: "Broken Campaign"
+emails:
+  - name: "Broken Email"
+    file: "email.html"
+    from: "not-an-email-address"
+    subject: ""`
+	if err := os.WriteFile(filepath.Join(campaignDir, "data.yaml"), []byte(dataYaml), 0644); err != nil {
+		t.Fatalf("Failed to write data.yaml: %v", err)
+	}
+
+	emailHTML := `<html><body><h1>Hello {{.FirstName}}</h1><p>{{.DoesNotExist}}</p><img src="assets/missing.png"><div></body></html>`
+	if err := os.WriteFile(filepath.Join(campaignDir, "email.html"), []byte(emailHTML), 0644); err != nil {
+		t.Fatalf("Failed to write email.html: %v", err)
+	}
+
+	body, _ := json.Marshal(LintRequest{TemplatePath: "broken-campaign/email.html"})
+	req := httptest.NewRequest(http.MethodPost, "/api/template/lint", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	LintTemplateHandler(baseDir)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var report LintReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.OK {
+		t.Fatalf("expected the broken template to fail linting, got %+v", report.Issues)
+	}
+
+	wantCodes := map[string]bool{
+		"email_invalid_from":    false,
+		"email_missing_subject": false,
+		"broken_asset_path":     false,
+		"unclosed_tag":          false,
+		"missing_tracker":       false,
+		"missing_url":           false,
+	}
+	for _, issue := range report.Issues {
+		if _, ok := wantCodes[issue.Code]; ok {
+			wantCodes[issue.Code] = true
+		}
+	}
+	for code, found := range wantCodes {
+		if !found {
+			t.Errorf("expected a %q issue in %+v", code, report.Issues)
+		}
+	}
+}
+
+func TestLintTemplateHandlerUnknownTemplateReturnsError(t *testing.T) {
+	baseDir := createTestEmailCampaign(t)
+
+	body, _ := json.Marshal(LintRequest{TemplatePath: "does-not-exist/email.html"})
+	req := httptest.NewRequest(http.MethodPost, "/api/template/lint", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	LintTemplateHandler(baseDir)(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing template, got %d: %s", w.Code, w.Body.String())
+	}
+}
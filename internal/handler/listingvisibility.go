@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// previewIgnoreFile and previewOnlyFile are the listing-only counterparts to
+// .templateignore (see filter.go): they control what IndexHandlerFS shows in
+// a directory's Dirs/Files slices, not what's reachable at all. A file
+// hidden by .previewignore is still served normally by PreviewHandler,
+// RawViewHandler, and OriginalContentHandler - these files only ever affect
+// the listing template, so a WIP draft can be fetched directly by URL while
+// staying out of the directory browser.
+const (
+	previewIgnoreFile = ".previewignore"
+	previewOnlyFile   = ".previewonly"
+)
+
+// listingGlob is a single .previewignore/.previewonly pattern. It's matched
+// with filepath.Match-like glob semantics extended to support a "**"
+// segment that crosses directory separators, since filepath.Match itself
+// has no way to express that. A pattern with no "/" matches by basename,
+// same as a plain gitignore rule, rather than only matching at its own
+// directory's top level.
+type listingGlob struct {
+	negate   bool
+	re       *regexp.Regexp
+	basename bool
+}
+
+func newListingGlob(raw string) (listingGlob, error) {
+	negate := false
+	if rest, ok := strings.CutPrefix(raw, "!"); ok {
+		negate = true
+		raw = rest
+	}
+	re, err := compileListingGlob(raw)
+	if err != nil {
+		return listingGlob{}, err
+	}
+	return listingGlob{negate: negate, re: re, basename: !strings.Contains(raw, "/")}, nil
+}
+
+// compileListingGlob translates a gitignore-ish glob into a regexp: "**"
+// matches any number of characters (including "/"), "*" matches within a
+// single path segment, and "?" matches one character.
+func compileListingGlob(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			sb.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			sb.WriteString("[^/]")
+			i++
+		case strings.ContainsRune(`.+()|^$\`, rune(pattern[i])):
+			sb.WriteByte('\\')
+			sb.WriteByte(pattern[i])
+			i++
+		default:
+			sb.WriteByte(pattern[i])
+			i++
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+func (g listingGlob) match(entryName string) bool {
+	if g.basename {
+		return g.re.MatchString(path.Base(entryName))
+	}
+	return g.re.MatchString(entryName)
+}
+
+// listingVisibility is the composed .previewignore/.previewonly state in
+// effect for one directory being listed.
+type listingVisibility struct {
+	ignore []listingGlob // accumulated root-to-leaf, so a child rule is checked last
+	only   []listingGlob // from the nearest ancestor (including dirPath itself) that has one
+}
+
+// loadListingVisibility composes the .previewignore/.previewonly rules that
+// apply when listing dirPath (an fs.FS-relative path, "." for the root).
+// .previewignore rules compose across nested directories - a subdirectory's
+// file adds its patterns on top of every ancestor's, so a root-level
+// .previewignore can hide something everywhere and a child directory can
+// "!"-negate it back into view locally. .previewonly is the inverse,
+// narrower case (show only what matches), so only the nearest one found
+// (walking from dirPath back up to root) applies.
+func loadListingVisibility(fsys fs.FS, dirPath string) (*listingVisibility, error) {
+	dirs := []string{"."}
+	if dirPath != "." && dirPath != "" {
+		parts := strings.Split(dirPath, "/")
+		cur := ""
+		for _, part := range parts {
+			if cur == "" {
+				cur = part
+			} else {
+				cur = cur + "/" + part
+			}
+			dirs = append(dirs, cur)
+		}
+	}
+
+	vis := &listingVisibility{}
+	for _, dir := range dirs {
+		ignore, err := loadListingGlobFile(fsys, dir, previewIgnoreFile)
+		if err != nil {
+			return nil, err
+		}
+		vis.ignore = append(vis.ignore, ignore...)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		only, err := loadListingGlobFile(fsys, dirs[i], previewOnlyFile)
+		if err != nil {
+			return nil, err
+		}
+		if only != nil {
+			vis.only = only
+			break
+		}
+	}
+
+	return vis, nil
+}
+
+func loadListingGlobFile(fsys fs.FS, dir, name string) ([]listingGlob, error) {
+	data, err := fs.ReadFile(fsys, path.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var globs []listingGlob
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		g, err := newListingGlob(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in %s: %w", line, path.Join(dir, name), err)
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+// visible reports whether entryName, a single file or directory name within
+// the directory loadListingVisibility was built for, should appear in the
+// listing.
+func (v *listingVisibility) visible(entryName string) bool {
+	if v == nil {
+		return true
+	}
+
+	if len(v.only) > 0 {
+		allowed := false
+		for _, g := range v.only {
+			if g.match(entryName) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	ignored := false
+	for _, g := range v.ignore {
+		if g.match(entryName) {
+			ignored = !g.negate
+		}
+	}
+	return !ignored
+}
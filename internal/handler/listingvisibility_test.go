@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestListingGlobDoubleStarCrossesDirectorySeparators(t *testing.T) {
+	g, err := newListingGlob("drafts/**/*.bak")
+	if err != nil {
+		t.Fatalf("newListingGlob() error = %v", err)
+	}
+	if !g.match("drafts/a/b/c.bak") {
+		t.Errorf("expected drafts/a/b/c.bak to match drafts/**/*.bak")
+	}
+	if g.match("other/a/b/c.bak") {
+		t.Errorf("expected other/a/b/c.bak not to match drafts/**/*.bak")
+	}
+}
+
+func TestListingGlobWithoutSlashMatchesByBasename(t *testing.T) {
+	g, err := newListingGlob("*.bak")
+	if err != nil {
+		t.Fatalf("newListingGlob() error = %v", err)
+	}
+	if !g.match("draft.bak") {
+		t.Errorf("expected draft.bak to match *.bak")
+	}
+	if g.match("draft.html") {
+		t.Errorf("expected draft.html not to match *.bak")
+	}
+}
+
+func TestLoadListingVisibilityComposesAcrossNestedDirectories(t *testing.T) {
+	fsys := fstest.MapFS{
+		".previewignore":            {Data: []byte("*.bak\n")},
+		"campaign-a/.previewignore": {Data: []byte("!keep.bak\n")},
+		"campaign-a/keep.bak":       {Data: []byte("x")},
+		"campaign-a/draft.bak":      {Data: []byte("x")},
+		"campaign-a/page.html":      {Data: []byte("x")},
+	}
+
+	vis, err := loadListingVisibility(fsys, "campaign-a")
+	if err != nil {
+		t.Fatalf("loadListingVisibility() error = %v", err)
+	}
+	if vis.visible("draft.bak") {
+		t.Error("expected draft.bak to stay hidden by the root .previewignore")
+	}
+	if !vis.visible("keep.bak") {
+		t.Error("expected campaign-a/.previewignore's \"!keep.bak\" to re-include it")
+	}
+	if !vis.visible("page.html") {
+		t.Error("expected page.html to be visible")
+	}
+}
+
+func TestLoadListingVisibilityPreviewOnlyRestrictsToMatches(t *testing.T) {
+	fsys := fstest.MapFS{
+		"campaign-a/.previewonly": {Data: []byte("*.html\n")},
+		"campaign-a/page.html":    {Data: []byte("x")},
+		"campaign-a/notes.txt":    {Data: []byte("x")},
+	}
+
+	vis, err := loadListingVisibility(fsys, "campaign-a")
+	if err != nil {
+		t.Fatalf("loadListingVisibility() error = %v", err)
+	}
+	if !vis.visible("page.html") {
+		t.Error("expected page.html to match .previewonly")
+	}
+	if vis.visible("notes.txt") {
+		t.Error("expected notes.txt to be hidden since it doesn't match .previewonly")
+	}
+}
+
+func TestLoadListingVisibilityEmptyWhenNoFilesExist(t *testing.T) {
+	fsys := fstest.MapFS{"campaign-a/page.html": {Data: []byte("x")}}
+
+	vis, err := loadListingVisibility(fsys, "campaign-a")
+	if err != nil {
+		t.Fatalf("loadListingVisibility() error = %v", err)
+	}
+	if !vis.visible("page.html") || !vis.visible("anything-else") {
+		t.Error("expected everything to stay visible with no .previewignore/.previewonly files")
+	}
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+)
+
+// ReloadHub fans out live-reload notifications to preview pages open in a
+// browser in -dev mode, scoped to the template directory each one is
+// currently viewing. Watch feeds it fsnotify events; ReloadHandler accepts
+// the browser-side WebSocket connections Notify then pushes messages to.
+type ReloadHub struct {
+	mu      sync.Mutex
+	clients map[*reloadClient]struct{}
+}
+
+// NewReloadHub returns an empty ReloadHub, ready for Watch and
+// ReloadHandler.
+func NewReloadHub() *ReloadHub {
+	return &ReloadHub{clients: make(map[*reloadClient]struct{})}
+}
+
+// reloadClient is one open /ws/reload connection, scoped to the directory
+// (relative to the templates root) its preview tab is showing.
+type reloadClient struct {
+	conn *websocket.Conn
+	dir  string
+}
+
+// reloadUpgrader upgrades a request to a WebSocket. The dev preview is
+// same-origin and loopback-only, so there's no cross-site case CheckOrigin
+// needs to guard against the way a public API would.
+var reloadUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// ReloadHandler upgrades a request to a WebSocket and registers it with
+// hub, scoped to the `dir=` query parameter LiveReloadScript embeds. It
+// blocks, discarding incoming frames, until the connection closes, at
+// which point the client is unregistered.
+func (hub *ReloadHub) ReloadHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := reloadUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := &reloadClient{conn: conn, dir: path.Clean(r.URL.Query().Get("dir"))}
+
+		hub.mu.Lock()
+		hub.clients[client] = struct{}{}
+		hub.mu.Unlock()
+
+		defer func() {
+			hub.mu.Lock()
+			delete(hub.clients, client)
+			hub.mu.Unlock()
+			conn.Close()
+		}()
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Notify tells every client previewing changedPath's directory, or a parent
+// of it, to refresh. changedPath is relative to the templates root, using
+// "/" separators. A ".css" change sends "css-inject" (cheap, no full
+// navigation needed); anything else sends "reload".
+func (hub *ReloadHub) Notify(changedPath string) {
+	dir := path.Dir(changedPath)
+	msg := "reload"
+	if strings.EqualFold(path.Ext(changedPath), ".css") {
+		msg = "css-inject"
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for client := range hub.clients {
+		if client.dir != "." && client.dir != dir && !strings.HasPrefix(dir, client.dir+"/") {
+			continue
+		}
+		if err := client.conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			log.Printf("livereload: dropping client: %v", err)
+			go client.conn.Close()
+		}
+	}
+}
+
+// reloadableExts are the file extensions a change to which is worth telling
+// a preview tab about: the template formats PreviewHandlerDev renders, plus
+// the asset types one commonly references from a template.
+var reloadableExts = map[string]bool{
+	".html": true, ".md": true,
+	".css": true, ".js": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+}
+
+// Watch starts an fsnotify watcher on root (mirroring Index.Start's own
+// watcher setup in index.go) and calls hub.Notify, with a root-relative
+// "/"-separated path, for every create/write/remove/rename of a file whose
+// extension is in reloadableExts. The returned stop func shuts the watcher
+// down.
+func (hub *ReloadHub) Watch(root string) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start live-reload watcher: %w", err)
+	}
+	if err := addWatchRecursive(watcher, root); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %q for live reload: %w", root, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) == 0 {
+					continue
+				}
+				if !reloadableExts[strings.ToLower(filepath.Ext(ev.Name))] {
+					continue
+				}
+				rel, err := filepath.Rel(root, ev.Name)
+				if err != nil {
+					continue
+				}
+				hub.Notify(filepath.ToSlash(rel))
+			case lerr, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("live-reload watcher error: %v", lerr)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }, nil
+}
+
+// LiveReloadScript returns the <script> PreviewHandlerDev appends to a
+// rendered preview's content: it opens a WebSocket to /ws/reload?dir=dir
+// and, on a "css-inject" message, cache-busts every <link rel=stylesheet>
+// in place, or otherwise reloads the page. dir is the previewed template's
+// directory (relative to the templates root, "/"-separated) - the same
+// value ReloadHub.Notify compares an fsnotify event's directory against.
+func LiveReloadScript(dir string) string {
+	return fmt.Sprintf(`<script>
+(function() {
+  var proto = location.protocol === "https:" ? "wss:" : "ws:";
+  var ws = new WebSocket(proto + "//" + location.host + "/ws/reload?dir=" + encodeURIComponent(%q));
+  ws.onmessage = function(ev) {
+    if (ev.data === "css-inject") {
+      document.querySelectorAll('link[rel="stylesheet"]').forEach(function(link) {
+        link.href = link.href.split("?")[0] + "?t=" + Date.now();
+      });
+      return;
+    }
+    location.reload();
+  };
+})();
+</script>`, dir)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mailpitBaseURL is Mailpit's HTTP API base, e.g. http://mailer:8025 for
+// the Docker Compose setup this package was built against. Overridable via
+// MAILPIT_API_URL for anyone running Mailpit (or a compatible stand-in)
+// somewhere else.
+func mailpitBaseURL() string {
+	return strings.TrimRight(envOrDefault("MAILPIT_API_URL", "http://mailer:8025"), "/")
+}
+
+// InboxHandler proxies Mailpit's GET /api/v1/messages so the previewer's UI
+// can list recently delivered test emails without talking to Mailpit
+// directly (which usually isn't exposed to the browser). Query parameters
+// are passed through unchanged - Mailpit supports "limit", "start", and
+// "search" among others.
+func InboxHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		proxyMailpitGET(w, "/api/v1/messages", r.URL.Query())
+	}
+}
+
+// MessageHandler proxies Mailpit's GET /api/v1/message/{id} for a single
+// message, identified by the "id" query parameter. When "checks=true" is
+// also given, the spam-assassin and HTML-check results Mailpit exposes at
+// /api/v1/message/{id}/sa-check and /html-check are fetched too and merged
+// in under "SpamAssassin"/"HTMLCheck" - both are best-effort, since
+// sa-check in particular is only available when Mailpit was built with
+// spamassassin support, and a failure there shouldn't hide the message
+// itself.
+func MessageHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, `{"error":"missing id"}`, http.StatusBadRequest)
+			return
+		}
+
+		message, err := fetchMailpitJSON("/api/v1/message/" + url.PathEscape(id))
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadGateway)
+			return
+		}
+
+		if r.URL.Query().Get("checks") == "true" {
+			if saCheck, err := fetchMailpitJSON("/api/v1/message/" + url.PathEscape(id) + "/sa-check"); err == nil {
+				message["SpamAssassin"] = saCheck
+			}
+			if htmlCheck, err := fetchMailpitJSON("/api/v1/message/" + url.PathEscape(id) + "/html-check"); err == nil {
+				message["HTMLCheck"] = htmlCheck
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(message)
+	}
+}
+
+// proxyMailpitGET fetches path+query from Mailpit and copies the response
+// straight through to w, preserving Mailpit's own status code and JSON
+// body instead of re-encoding it.
+func proxyMailpitGET(w http.ResponseWriter, path string, query url.Values) {
+	target := mailpitBaseURL() + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	resp, err := http.Get(target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to reach Mailpit: %s"}`, err.Error()), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// fetchMailpitJSON GETs path from Mailpit and decodes it as a generic JSON
+// object, for the cases where this package wants to inspect or merge the
+// response rather than just pass it through.
+func fetchMailpitJSON(path string) (map[string]interface{}, error) {
+	resp, err := http.Get(mailpitBaseURL() + path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Mailpit: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("mailpit returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode Mailpit response: %w", err)
+	}
+	return result, nil
+}
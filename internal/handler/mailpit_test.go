@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestInboxHandlerProxiesMailpitMessages(t *testing.T) {
+	mailpit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/messages" {
+			t.Errorf("expected /api/v1/messages, got %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("limit") != "10" {
+			t.Errorf("expected the limit query param to be forwarded, got %q", r.URL.Query().Get("limit"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"total":1,"messages":[{"ID":"abc123","Subject":"Hi"}]}`))
+	}))
+	defer mailpit.Close()
+	t.Setenv("MAILPIT_API_URL", mailpit.URL)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/email/inbox?limit=10", nil)
+	w := httptest.NewRecorder()
+	InboxHandler()(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"ID":"abc123"`) {
+		t.Errorf("expected Mailpit's response body to pass through, got %q", w.Body.String())
+	}
+}
+
+func TestInboxHandlerPropagatesMailpitUnreachableAsBadGateway(t *testing.T) {
+	t.Setenv("MAILPIT_API_URL", "http://127.0.0.1:1")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/email/inbox", nil)
+	w := httptest.NewRecorder()
+	InboxHandler()(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 when Mailpit is unreachable, got %d", w.Code)
+	}
+}
+
+func TestMessageHandlerFetchesAndMergesChecks(t *testing.T) {
+	mailpit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/message/abc123":
+			w.Write([]byte(`{"ID":"abc123","Subject":"Hi","HTML":"<p>Hi</p>"}`))
+		case "/api/v1/message/abc123/sa-check":
+			w.Write([]byte(`{"Score":0.1}`))
+		case "/api/v1/message/abc123/html-check":
+			w.Write([]byte(`{"Total":100}`))
+		default:
+			t.Errorf("unexpected Mailpit path %s", r.URL.Path)
+		}
+	}))
+	defer mailpit.Close()
+	t.Setenv("MAILPIT_API_URL", mailpit.URL)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/email/message?"+url.Values{"id": {"abc123"}, "checks": {"true"}}.Encode(), nil)
+	w := httptest.NewRecorder()
+	MessageHandler()(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result["Subject"] != "Hi" {
+		t.Errorf("expected the message itself to be present, got %+v", result)
+	}
+	if _, ok := result["SpamAssassin"]; !ok {
+		t.Errorf("expected SpamAssassin check results to be merged in, got %+v", result)
+	}
+	if _, ok := result["HTMLCheck"]; !ok {
+		t.Errorf("expected HTMLCheck results to be merged in, got %+v", result)
+	}
+}
+
+func TestMessageHandlerRequiresID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/email/message", nil)
+	w := httptest.NewRecorder()
+	MessageHandler()(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 when id is missing, got %d", w.Code)
+	}
+}
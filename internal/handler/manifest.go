@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// exportCacheDir holds previously-built export archives, keyed by the
+// manifest hash of their contents. A repeat export of identical inputs
+// reuses the cached file instead of rebuilding it, and serving it through
+// http.ServeContent gives us If-None-Match/Range handling for free.
+var exportCacheDir = filepath.Join(os.TempDir(), "phishingclub-templates-export-cache")
+
+// exportCachePath returns where a built export with the given manifest hash
+// and format is cached on disk.
+func exportCachePath(manifestHash string, format ArchiveFormat) string {
+	return filepath.Join(exportCacheDir, manifestHash+format.Extension())
+}
+
+// deterministicModTime is the fixed timestamp written into every archive
+// entry so that identical inputs always produce a byte-identical archive,
+// instead of leaking the machine's clock into the output. Set
+// SOURCE_DATE_EPOCH (as seconds since the Unix epoch) to override it, for
+// reproducible-builds-style pipelines that need a specific pinned time.
+func deterministicModTime() time.Time {
+	if v := os.Getenv("SOURCE_DATE_EPOCH"); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC()
+		}
+	}
+	return time.Unix(0, 0).UTC()
+}
+
+// ManifestEntry is one file's content-addressed record in a Manifest.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Mode   uint32 `json:"mode"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+
+	// storePath is the original Store path this entry's content was read
+	// from. It is unexported so it never appears in manifest.json; it only
+	// exists to let writeExportArchive re-read the same bytes it hashed.
+	storePath string
+}
+
+// manifestSchemaVersion is the current Manifest.SchemaVersion. Bump it
+// whenever a change to ManifestEntry/Manifest's fields would break a reader
+// written against the previous shape.
+const manifestSchemaVersion = 1
+
+// Manifest is a sorted, content-addressed listing of every file in an
+// export, plus an aggregate SHA256 over all entries. Two exports with the
+// same Manifest.SHA256 are guaranteed to contain byte-identical files.
+type Manifest struct {
+	SchemaVersion int             `json:"schema_version"`
+	Entries       []ManifestEntry `json:"entries"`
+	SHA256        string          `json:"sha256"`
+}
+
+// manifestCollector is an ArchiveWriter that, instead of writing an archive,
+// records a ManifestEntry for every file it's asked to write. Running
+// addAssets/addPhishingTemplates against one builds a manifest using
+// exactly the same traversal and filtering as the real export, so the two
+// can never drift apart.
+type manifestCollector struct {
+	entries []ManifestEntry
+}
+
+func (c *manifestCollector) WriteEntry(name string, info fs.FileInfo, store Store, storePath string) error {
+	if info.IsDir() {
+		return nil
+	}
+
+	content, err := fs.ReadFile(store, storePath)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+
+	c.entries = append(c.entries, ManifestEntry{
+		Path:      name,
+		Mode:      uint32(info.Mode().Perm()),
+		Size:      info.Size(),
+		SHA256:    hex.EncodeToString(sum[:]),
+		storePath: storePath,
+	})
+	return nil
+}
+
+func (c *manifestCollector) Close() error { return nil }
+
+// buildExportManifest walks store the same way ExportHandler does (assets
+// directories, then phishing templates), honoring filter, and returns a
+// manifest sorted by path so the result is independent of directory walk
+// order across "assets" vs "Assets" or across template folders.
+func buildExportManifest(store Store, filter *ExportFilter) (*Manifest, error) {
+	collector := &manifestCollector{}
+
+	for _, assetsDir := range []string{"assets", "Assets"} {
+		if _, err := fs.Stat(store, assetsDir); err == nil {
+			if err := addAssets(collector, store, assetsDir, filter); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := addPhishingTemplates(collector, store, filter); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(collector.entries, func(i, j int) bool {
+		return collector.entries[i].Path < collector.entries[j].Path
+	})
+
+	h := sha256.New()
+	for _, e := range collector.entries {
+		fmt.Fprintf(h, "%s\x00%o\x00%d\x00%s\n", e.Path, e.Mode, e.Size, e.SHA256)
+	}
+
+	return &Manifest{
+		SchemaVersion: manifestSchemaVersion,
+		Entries:       collector.entries,
+		SHA256:        hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// writeExportArchive writes manifest.json followed by every entry in
+// manifest (in their already-sorted order, read back from store via each
+// entry's recorded storePath) to a new archive in format, streamed to w.
+func writeExportArchive(w io.Writer, format ArchiveFormat, store Store, manifest *Manifest) error {
+	aw, err := NewArchiveWriter(format, w)
+	if err != nil {
+		return err
+	}
+	defer aw.Close()
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	mf := manifestFS{name: "manifest.json", data: manifestJSON}
+	info, err := fs.Stat(mf, "manifest.json")
+	if err != nil {
+		return err
+	}
+	if err := aw.WriteEntry("manifest.json", info, mf, "manifest.json"); err != nil {
+		return err
+	}
+
+	for _, e := range manifest.Entries {
+		storeInfo, err := fs.Stat(store, e.storePath)
+		if err != nil {
+			return err
+		}
+		if err := aw.WriteEntry(e.Path, storeInfo, store, e.storePath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// manifestFS presents a single in-memory file as an fs.FS, so synthetic
+// content (manifest.json, or a file re-read for archiving) can be written
+// through the same ArchiveWriter.WriteEntry path used for real Store files.
+type manifestFS struct {
+	name string
+	data []byte
+}
+
+func (m manifestFS) Open(name string) (fs.File, error) {
+	if name != m.name {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &manifestFile{name: m.name, data: m.data}, nil
+}
+
+type manifestFile struct {
+	name   string
+	data   []byte
+	offset int
+}
+
+func (f *manifestFile) Stat() (fs.FileInfo, error) {
+	return manifestFileInfo{name: f.name, size: int64(len(f.data))}, nil
+}
+
+func (f *manifestFile) Read(p []byte) (int, error) {
+	if f.offset >= len(f.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+func (f *manifestFile) Close() error { return nil }
+
+type manifestFileInfo struct {
+	name string
+	size int64
+}
+
+func (i manifestFileInfo) Name() string       { return i.name }
+func (i manifestFileInfo) Size() int64        { return i.size }
+func (i manifestFileInfo) Mode() fs.FileMode  { return 0644 }
+func (i manifestFileInfo) ModTime() time.Time { return deterministicModTime() }
+func (i manifestFileInfo) IsDir() bool        { return false }
+func (i manifestFileInfo) Sys() any           { return nil }
+
+// serveExportCache serves a cached/just-built export file at cachePath,
+// honoring If-None-Match and Range via http.ServeContent since archiveFilename
+// and w's ETag header are already set.
+func serveExportCache(w http.ResponseWriter, r *http.Request, cachePath, archiveFilename string) error {
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	http.ServeContent(w, r, archiveFilename, deterministicModTime(), f)
+	return nil
+}
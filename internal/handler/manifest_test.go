@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newManifestTestStore(t *testing.T) Store {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "manifest-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	campaignDir := filepath.Join(tmpDir, "campaign-a")
+	if err := os.MkdirAll(campaignDir, 0755); err != nil {
+		t.Fatalf("Failed to create campaign dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(campaignDir, "email.html"), []byte("<html>hello</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write email.html: %v", err)
+	}
+
+	assetsDir := filepath.Join(tmpDir, "assets")
+	if err := os.MkdirAll(assetsDir, 0755); err != nil {
+		t.Fatalf("Failed to create assets dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(assetsDir, "logo.png"), []byte("fake-png-bytes"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	return os.DirFS(tmpDir)
+}
+
+func TestBuildExportManifestDeterministic(t *testing.T) {
+	store := newManifestTestStore(t)
+
+	m1, err := buildExportManifest(store, nil)
+	if err != nil {
+		t.Fatalf("buildExportManifest failed: %v", err)
+	}
+	m2, err := buildExportManifest(store, nil)
+	if err != nil {
+		t.Fatalf("buildExportManifest failed: %v", err)
+	}
+
+	if m1.SHA256 != m2.SHA256 {
+		t.Errorf("expected identical manifest hashes across builds, got %q and %q", m1.SHA256, m2.SHA256)
+	}
+	if len(m1.Entries) != 2 {
+		t.Fatalf("expected 2 manifest entries, got %d: %+v", len(m1.Entries), m1.Entries)
+	}
+	for i := 1; i < len(m1.Entries); i++ {
+		if m1.Entries[i-1].Path > m1.Entries[i].Path {
+			t.Errorf("expected entries sorted by path, got %q before %q", m1.Entries[i-1].Path, m1.Entries[i].Path)
+		}
+	}
+}
+
+func TestBuildExportManifestRespectsFilter(t *testing.T) {
+	store := newManifestTestStore(t)
+
+	f := &ExportFilter{active: true}
+	p, err := newMatchPattern("assets/*")
+	if err != nil {
+		t.Fatalf("newMatchPattern failed: %v", err)
+	}
+	f.includes = append(f.includes, p)
+
+	manifest, err := buildExportManifest(store, f)
+	if err != nil {
+		t.Fatalf("buildExportManifest failed: %v", err)
+	}
+	if len(manifest.Entries) != 1 || manifest.Entries[0].Path != "assets/logo.png" {
+		t.Errorf("expected only assets/logo.png, got %+v", manifest.Entries)
+	}
+}
+
+func TestWriteExportArchiveRoundTrip(t *testing.T) {
+	store := newManifestTestStore(t)
+
+	manifest, err := buildExportManifest(store, nil)
+	if err != nil {
+		t.Fatalf("buildExportManifest failed: %v", err)
+	}
+
+	tmpFile, err := os.CreateTemp(t.TempDir(), "export-*.zip")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer tmpFile.Close()
+
+	if err := writeExportArchive(tmpFile, FormatZip, store, manifest); err != nil {
+		t.Fatalf("writeExportArchive failed: %v", err)
+	}
+
+	info, err := tmpFile.Stat()
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() == 0 {
+		t.Error("expected a non-empty archive")
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"gopkg.in/yaml.v3"
+)
+
+// markdownBodyRenderer converts a .md template's body to HTML with the same
+// GFM extensions (tables, strikethrough, autolinks) renderReadme already
+// enables. Unlike README rendering, the result is never passed through
+// readmeSanitizer - a template's Markdown body is authored content, already
+// as trusted as the HTML templates processTemplateContent executes
+// unsanitized everywhere else in this package.
+var markdownBodyRenderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// frontMatterDelim is the line a Markdown template's front-matter block
+// opens and closes with, the same "---" convention Jekyll/Hugo/zs use.
+const frontMatterDelim = "---"
+
+// splitMarkdownFrontMatter splits a leading "---\n...\n---\n" YAML block from
+// the rest of content. ok is false (front nil, body the whole of content)
+// when content doesn't open with a front-matter block at all - a .md file
+// without one is just rendered as a plain Markdown body.
+func splitMarkdownFrontMatter(content []byte) (front, body []byte, ok bool) {
+	lines := strings.Split(string(content), "\n")
+	if len(lines) == 0 || strings.TrimRight(lines[0], "\r") != frontMatterDelim {
+		return nil, content, false
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r") == frontMatterDelim {
+			front = []byte(strings.Join(lines[1:i], "\n"))
+			body = []byte(strings.Join(lines[i+1:], "\n"))
+			return front, body, true
+		}
+	}
+	return nil, content, false
+}
+
+// frontMatterBareVars converts a parsed front-matter map into the bare-keyed
+// form mergeBareVars expects (see vars_config.go): each key capitalized to
+// match the repo's existing var naming (subject -> Subject, from -> From,
+// ...) and each value stringified - a YAML list (e.g. tags:) becomes a
+// comma-joined string, matching how a template would actually want to drop
+// it into a page with {{.Tags}}.
+func frontMatterBareVars(meta map[string]any) map[string]string {
+	bare := make(map[string]string, len(meta))
+	for k, v := range meta {
+		bare[capitalizeFirst(k)] = stringifyFrontMatterValue(v)
+	}
+	return bare
+}
+
+// capitalizeFirst upper-cases only the first rune of s, leaving the rest
+// alone - "subject" -> "Subject", "firstName" -> "FirstName".
+func capitalizeFirst(s string) string {
+	r := []rune(s)
+	if len(r) == 0 {
+		return s
+	}
+	r[0] = []rune(strings.ToUpper(string(r[0])))[0]
+	return string(r)
+}
+
+// stringifyFrontMatterValue renders a front-matter value as plain text:
+// scalars via fmt.Sprint, a YAML sequence (e.g. tags: [a, b]) joined with
+// ", ".
+func stringifyFrontMatterValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []any:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// resolveMarkdownLayout finds the named layout file for a Markdown template
+// at reqPath, trying the template's own directory first (<dir>/layouts/
+// <layout>.html) and then a shared layouts/ directory at fsys's root - the
+// same local-then-global precedent AssetHandler's DefaultAssetFS already
+// established for assets.
+func resolveMarkdownLayout(fsys fs.FS, reqPath, layout string) ([]byte, error) {
+	templateDir := path.Dir(reqPath)
+	candidates := []string{
+		path.Join(templateDir, "layouts", layout+".html"),
+		path.Join("layouts", layout+".html"),
+	}
+	for _, candidate := range candidates {
+		if data, err := fs.ReadFile(fsys, candidate); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("layout %q not found (looked in %s)", layout, strings.Join(candidates, ", "))
+}
+
+// renderMarkdownFS turns a .md file's raw bytes into the Go-template
+// "content" string and vars map that RawViewHandlerFS/PreviewHandlerFS
+// already know how to finish rendering through rendererFor(baseDir).Render:
+// front matter (subject, from, tags, description, ...) becomes extra
+// template vars, the body is converted from Markdown to HTML, and - when a
+// layout: key names one - the body is embedded as the layout's Content var
+// so the layout can supply the tracking pixel, styles and unsubscribe link
+// around it. With no front matter, or front matter naming no layout, the
+// rendered body HTML is returned as the content directly.
+func renderMarkdownFS(fsys fs.FS, reqPath string, content []byte, vars map[string]string) (string, map[string]string, error) {
+	front, body, hasFront := splitMarkdownFrontMatter(content)
+
+	var bodyHTML bytes.Buffer
+	if err := markdownBodyRenderer.Convert(body, &bodyHTML); err != nil {
+		return "", nil, fmt.Errorf("failed to render markdown in %s: %w", reqPath, err)
+	}
+
+	if !hasFront {
+		return bodyHTML.String(), vars, nil
+	}
+
+	var meta map[string]any
+	if len(strings.TrimSpace(string(front))) > 0 {
+		if err := yaml.Unmarshal(front, &meta); err != nil {
+			return "", nil, fmt.Errorf("failed to parse front matter in %s: %w", reqPath, err)
+		}
+	}
+
+	layout, _ := meta["layout"].(string)
+	delete(meta, "layout")
+
+	mergedVars := mergeBareVars(vars, frontMatterBareVars(meta))
+
+	if layout == "" {
+		return bodyHTML.String(), mergedVars, nil
+	}
+
+	layoutContent, err := resolveMarkdownLayout(fsys, reqPath, layout)
+	if err != nil {
+		return "", nil, err
+	}
+
+	mergedVars[wrapVarKey("Content")] = bodyHTML.String()
+	return string(layoutContent), mergedVars, nil
+}
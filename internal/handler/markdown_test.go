@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitFrontMatterParsesDelimitedBlock(t *testing.T) {
+	content := []byte("---\nsubject: Hello\n---\n# Body\n")
+	front, body, ok := splitMarkdownFrontMatter(content)
+	if !ok {
+		t.Fatal("expected front matter to be detected")
+	}
+	if string(front) != "subject: Hello" {
+		t.Errorf("front = %q, want %q", front, "subject: Hello")
+	}
+	if string(body) != "# Body\n" {
+		t.Errorf("body = %q, want %q", body, "# Body\n")
+	}
+}
+
+func TestSplitFrontMatterNoneFound(t *testing.T) {
+	content := []byte("# Just a body\n")
+	front, body, ok := splitMarkdownFrontMatter(content)
+	if ok {
+		t.Fatal("expected no front matter to be detected")
+	}
+	if front != nil {
+		t.Errorf("expected a nil front, got %q", front)
+	}
+	if string(body) != string(content) {
+		t.Errorf("body = %q, want the untouched content", body)
+	}
+}
+
+func TestFrontMatterBareVarsStringifiesAndCapitalizes(t *testing.T) {
+	bare := frontMatterBareVars(map[string]any{
+		"subject": "Invoice overdue",
+		"tags":    []any{"finance", "urgent"},
+	})
+	if bare["Subject"] != "Invoice overdue" {
+		t.Errorf("Subject = %q, want %q", bare["Subject"], "Invoice overdue")
+	}
+	if bare["Tags"] != "finance, urgent" {
+		t.Errorf("Tags = %q, want %q", bare["Tags"], "finance, urgent")
+	}
+}
+
+func TestRenderMarkdownFSWithoutFrontMatter(t *testing.T) {
+	fsys := fstest.MapFS{}
+	content := []byte("Hello **world**")
+
+	out, vars, err := renderMarkdownFS(fsys, "lure.md", content, map[string]string{})
+	if err != nil {
+		t.Fatalf("renderMarkdownFS() error = %v", err)
+	}
+	if !strings.Contains(out, "<strong>world</strong>") {
+		t.Errorf("expected rendered Markdown, got %q", out)
+	}
+	if len(vars) != 0 {
+		t.Errorf("expected vars untouched with no front matter, got %+v", vars)
+	}
+}
+
+func TestRenderMarkdownFSWithLayout(t *testing.T) {
+	fsys := fstest.MapFS{
+		"campaign/layouts/default.html": {Data: []byte("<html><body>{{.Content}}<p>{{.Subject}}</p></body></html>")},
+	}
+	content := []byte("---\nsubject: Password reset\nlayout: default\n---\nClick **here**.\n")
+
+	out, vars, err := renderMarkdownFS(fsys, "campaign/lure.md", content, map[string]string{})
+	if err != nil {
+		t.Fatalf("renderMarkdownFS() error = %v", err)
+	}
+	if !strings.Contains(out, "{{.Content}}") {
+		t.Errorf("expected the layout's raw template source, got %q", out)
+	}
+	if vars[wrapVarKey("Content")] == "" || !strings.Contains(vars[wrapVarKey("Content")], "<strong>here</strong>") {
+		t.Errorf("expected Content var to hold the rendered body, got %+v", vars)
+	}
+	if vars[wrapVarKey("Subject")] != "Password reset" {
+		t.Errorf("expected Subject var from front matter, got %+v", vars)
+	}
+}
+
+func TestRenderMarkdownFSMissingLayoutErrors(t *testing.T) {
+	fsys := fstest.MapFS{}
+	content := []byte("---\nlayout: nonexistent\n---\nBody\n")
+
+	if _, _, err := renderMarkdownFS(fsys, "campaign/lure.md", content, map[string]string{}); err == nil {
+		t.Fatal("expected an error for a layout that doesn't exist")
+	}
+}
+
+func TestRenderMarkdownFSFallsBackToSharedLayout(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/default.html": {Data: []byte("{{.Content}}")},
+	}
+	content := []byte("---\nlayout: default\n---\nBody\n")
+
+	out, _, err := renderMarkdownFS(fsys, "campaign/lure.md", content, map[string]string{})
+	if err != nil {
+		t.Fatalf("renderMarkdownFS() error = %v", err)
+	}
+	if out != "{{.Content}}" {
+		t.Errorf("expected the shared layouts/default.html to be used, got %q", out)
+	}
+}
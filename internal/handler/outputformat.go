@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// OutputFormat is one way a rendered template can be served - as itself
+// (html), converted to plaintext (txt), or packaged as a standalone email
+// message (eml) - modeled on Hugo's output-format abstraction: a template
+// needs only one source file, and each format derives from the same
+// rendered HTML rather than being authored separately.
+type OutputFormat struct {
+	Name      string
+	MediaType string
+	Extension string
+	Transform func(renderedHTML string) ([]byte, error)
+}
+
+// OutputFormats is the built-in format registry PreviewHandlerFS's
+// ?format= query parameter looks up by name.
+var OutputFormats = map[string]OutputFormat{
+	"html": {
+		Name:      "html",
+		MediaType: "text/html; charset=utf-8",
+		Extension: "html",
+		Transform: func(renderedHTML string) ([]byte, error) {
+			_, body := splitFrontMatter(renderedHTML)
+			return []byte(body), nil
+		},
+	},
+	"txt": {
+		Name:      "txt",
+		MediaType: "text/plain; charset=utf-8",
+		Extension: "txt",
+		Transform: func(renderedHTML string) ([]byte, error) {
+			_, body := splitFrontMatter(renderedHTML)
+			text, err := htmlToText(body)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(text), nil
+		},
+	},
+	"eml": {
+		Name:      "eml",
+		MediaType: "message/rfc822",
+		Extension: "eml",
+		Transform: renderEML,
+	},
+}
+
+// OutputFormatNames lists OutputFormats' keys in the order a preview page's
+// format tabs should show them.
+var OutputFormatNames = []string{"html", "txt", "eml"}
+
+// emailFrontMatter is the handful of RFC 5322 headers an email template's
+// front-matter block can set - see splitFrontMatter.
+type emailFrontMatter struct {
+	Subject string
+	From    string
+	ReplyTo string
+}
+
+// splitFrontMatter splits a leading "---\nSubject: ...\nFrom: ...\n---\n"
+// block from the top of content, if one is present, returning its
+// Subject/From/Reply-To headers and the rest of content with the block
+// removed. content is returned unchanged (with a zero emailFrontMatter) if
+// it doesn't start with one - a front-matter block is optional, since only
+// the eml output format needs message headers at all.
+func splitFrontMatter(content string) (emailFrontMatter, string) {
+	const delim = "---"
+	if !strings.HasPrefix(content, delim+"\n") {
+		return emailFrontMatter{}, content
+	}
+
+	rest := content[len(delim)+1:]
+	end := strings.Index(rest, "\n"+delim)
+	if end == -1 {
+		return emailFrontMatter{}, content
+	}
+
+	block := rest[:end]
+	body := strings.TrimPrefix(rest[end+1+len(delim):], "\n")
+
+	var fm emailFrontMatter
+	for _, line := range strings.Split(block, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "subject":
+			fm.Subject = value
+		case "from":
+			fm.From = value
+		case "reply-to":
+			fm.ReplyTo = value
+		}
+	}
+	return fm, body
+}
+
+// blockLevelTags are the HTML elements htmlToText treats as line breaks
+// rather than just stripping - enough for a phishing template's usual
+// content (paragraphs, line breaks, list items, table rows, headings)
+// without trying to be a general-purpose HTML renderer.
+var blockLevelTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// htmlToText converts rendered HTML to plaintext for the txt output
+// format (and the text/plain part of an eml): tags are stripped, the
+// contents of script/style elements are dropped entirely, and
+// blockLevelTags become line breaks so paragraphs and list items don't run
+// together.
+func htmlToText(htmlContent string) (string, error) {
+	z := xhtml.NewTokenizer(strings.NewReader(htmlContent))
+	var buf bytes.Buffer
+	skipDepth := 0
+
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return "", err
+			}
+			break
+		}
+
+		tok := z.Token()
+		switch tt {
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if tok.Data == "script" || tok.Data == "style" {
+				if tt == xhtml.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if blockLevelTags[tok.Data] {
+				buf.WriteString("\n")
+			}
+		case xhtml.EndTagToken:
+			if tok.Data == "script" || tok.Data == "style" {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if blockLevelTags[tok.Data] {
+				buf.WriteString("\n")
+			}
+		case xhtml.TextToken:
+			if skipDepth == 0 {
+				buf.WriteString(tok.Data)
+			}
+		}
+	}
+
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// renderEML packages renderedHTML as an RFC 5322 message: a
+// multipart/alternative body with a plaintext part (via htmlToText) and
+// the original HTML part, with Subject/From/Reply-To headers drawn from
+// renderedHTML's front-matter block, if it has one.
+func renderEML(renderedHTML string) ([]byte, error) {
+	fm, body := splitFrontMatter(renderedHTML)
+
+	text, err := htmlToText(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var mixed bytes.Buffer
+	mpw := multipart.NewWriter(&mixed)
+
+	textPart, err := mpw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create text part: %w", err)
+	}
+	if _, err := textPart.Write([]byte(text)); err != nil {
+		return nil, fmt.Errorf("failed to write text part: %w", err)
+	}
+
+	htmlPart, err := mpw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create html part: %w", err)
+	}
+	if _, err := htmlPart.Write([]byte(body)); err != nil {
+		return nil, fmt.Errorf("failed to write html part: %w", err)
+	}
+
+	if err := mpw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart body: %w", err)
+	}
+
+	var msg bytes.Buffer
+	if fm.Subject != "" {
+		fmt.Fprintf(&msg, "Subject: %s\r\n", fm.Subject)
+	}
+	if fm.From != "" {
+		fmt.Fprintf(&msg, "From: %s\r\n", fm.From)
+	}
+	if fm.ReplyTo != "" {
+		fmt.Fprintf(&msg, "Reply-To: %s\r\n", fm.ReplyTo)
+	}
+	fmt.Fprint(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n", mpw.Boundary())
+	fmt.Fprint(&msg, "\r\n")
+	msg.Write(mixed.Bytes())
+
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	content := "---\nSubject: Your invoice is ready\nFrom: Billing <billing@example.com>\nReply-To: support@example.com\n---\n<p>Hello</p>"
+
+	fm, body := splitFrontMatter(content)
+	if fm.Subject != "Your invoice is ready" {
+		t.Errorf("Subject = %q, want %q", fm.Subject, "Your invoice is ready")
+	}
+	if fm.From != "Billing <billing@example.com>" {
+		t.Errorf("From = %q, want %q", fm.From, "Billing <billing@example.com>")
+	}
+	if fm.ReplyTo != "support@example.com" {
+		t.Errorf("ReplyTo = %q, want %q", fm.ReplyTo, "support@example.com")
+	}
+	if body != "<p>Hello</p>" {
+		t.Errorf("body = %q, want %q", body, "<p>Hello</p>")
+	}
+}
+
+func TestSplitFrontMatterAbsentLeavesContentUnchanged(t *testing.T) {
+	content := "<p>Hello</p>"
+	fm, body := splitFrontMatter(content)
+	if fm != (emailFrontMatter{}) {
+		t.Errorf("expected a zero emailFrontMatter, got %+v", fm)
+	}
+	if body != content {
+		t.Errorf("body = %q, want unchanged %q", body, content)
+	}
+}
+
+func TestHTMLToTextStripsTagsAndScripts(t *testing.T) {
+	html := `<html><head><style>body{color:red}</style></head><body><p>Hello <b>World</b></p><script>alert(1)</script><p>Bye</p></body></html>`
+	text, err := htmlToText(html)
+	if err != nil {
+		t.Fatalf("htmlToText() error = %v", err)
+	}
+	if strings.Contains(text, "alert") || strings.Contains(text, "color:red") {
+		t.Errorf("expected script/style contents to be dropped, got %q", text)
+	}
+	if !strings.Contains(text, "Hello World") {
+		t.Errorf("expected inline text to survive, got %q", text)
+	}
+	if !strings.Contains(text, "Bye") {
+		t.Errorf("expected both paragraphs, got %q", text)
+	}
+}
+
+func TestRenderEMLProducesWellFormedMultipartMessage(t *testing.T) {
+	content := "---\nSubject: Reset your password\nFrom: Support <support@example.com>\nReply-To: noreply@example.com\n---\n<html><body><p>Click <a href=\"https://example.com\">here</a></p></body></html>"
+
+	output, err := renderEML(content)
+	if err != nil {
+		t.Fatalf("renderEML() error = %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(output)))
+	if err != nil {
+		t.Fatalf("mail.ReadMessage() error = %v", err)
+	}
+
+	if got := msg.Header.Get("Subject"); got != "Reset your password" {
+		t.Errorf("Subject header = %q, want %q", got, "Reset your password")
+	}
+	if got := msg.Header.Get("From"); got != "Support <support@example.com>" {
+		t.Errorf("From header = %q, want %q", got, "Support <support@example.com>")
+	}
+	if got := msg.Header.Get("Reply-To"); got != "noreply@example.com" {
+		t.Errorf("Reply-To header = %q, want %q", got, "noreply@example.com")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("mime.ParseMediaType() error = %v", err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", mediaType)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var sawText, sawHTML bool
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		buf := make([]byte, 4096)
+		n, _ := part.Read(buf)
+		body := string(buf[:n])
+
+		switch part.Header.Get("Content-Type") {
+		case "text/plain; charset=UTF-8":
+			sawText = true
+			if !strings.Contains(body, "Click here") {
+				t.Errorf("expected the plaintext part to contain the link text, got %q", body)
+			}
+		case "text/html; charset=UTF-8":
+			sawHTML = true
+			if !strings.Contains(body, "<a href=\"https://example.com\">") {
+				t.Errorf("expected the html part to keep the markup, got %q", body)
+			}
+		}
+	}
+	if !sawText || !sawHTML {
+		t.Errorf("expected both a text/plain and a text/html part, got text=%v html=%v", sawText, sawHTML)
+	}
+}
+
+func TestPreviewHandlerFormatQueryParam(t *testing.T) {
+	// Create temp directory for test templates
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	// Create views directory for templates
+	viewsDir := filepath.Join(tmpDir, "views")
+	if err := os.MkdirAll(viewsDir, 0755); err != nil {
+		t.Fatalf("Failed to create views dir: %v", err)
+	}
+	layoutHTML := `{{define "layout"}}{{template "content" .}}{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "layout.html"), []byte(layoutHTML), 0644); err != nil {
+		t.Fatalf("Failed to create layout template: %v", err)
+	}
+	previewHTML := `{{define "content"}}{{.Content}}{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "preview.html"), []byte(previewHTML), 0644); err != nil {
+		t.Fatalf("Failed to create preview template: %v", err)
+	}
+	navTreeHTML := `{{define "nav_tree"}}{{end}}`
+	if err := os.WriteFile(filepath.Join(viewsDir, "nav_tree.html"), []byte(navTreeHTML), 0644); err != nil {
+		t.Fatalf("Failed to create nav_tree template: %v", err)
+	}
+
+	testDir := filepath.Join(tmpDir, "test-dir")
+	eml := "---\nSubject: Verify your account\nFrom: Security <security@example.com>\n---\n<html><body><p>Hello {{.FirstName}}</p></body></html>"
+	if err := os.WriteFile(filepath.Join(testDir, "email.html"), []byte(eml), 0644); err != nil {
+		t.Fatalf("Failed to write email.html: %v", err)
+	}
+
+	oldWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(oldWd)
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change working directory: %v", err)
+	}
+
+	handler := PreviewHandler(tmpDir)
+
+	txtReq, _ := http.NewRequest("GET", "/preview/test-dir/email.html?format=txt", nil)
+	txtRR := httptest.NewRecorder()
+	handler.ServeHTTP(txtRR, txtReq)
+	if txtRR.Code != http.StatusOK {
+		t.Fatalf("format=txt: expected 200, got %d: %s", txtRR.Code, txtRR.Body.String())
+	}
+	if ct := txtRR.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("format=txt: Content-Type = %q", ct)
+	}
+	if !strings.Contains(txtRR.Body.String(), "Hello John") {
+		t.Errorf("format=txt: expected rendered plaintext, got %q", txtRR.Body.String())
+	}
+
+	emlReq, _ := http.NewRequest("GET", "/preview/test-dir/email.html?format=eml", nil)
+	emlRR := httptest.NewRecorder()
+	handler.ServeHTTP(emlRR, emlReq)
+	if emlRR.Code != http.StatusOK {
+		t.Fatalf("format=eml: expected 200, got %d: %s", emlRR.Code, emlRR.Body.String())
+	}
+	if ct := emlRR.Header().Get("Content-Type"); ct != "message/rfc822" {
+		t.Errorf("format=eml: Content-Type = %q", ct)
+	}
+	if !strings.Contains(emlRR.Body.String(), "Subject: Verify your account") {
+		t.Errorf("format=eml: expected a Subject header, got %q", emlRR.Body.String())
+	}
+
+	badReq, _ := http.NewRequest("GET", "/preview/test-dir/email.html?format=nope", nil)
+	badRR := httptest.NewRecorder()
+	handler.ServeHTTP(badRR, badReq)
+	if badRR.Code != http.StatusBadRequest {
+		t.Errorf("format=nope: expected 400, got %d", badRR.Code)
+	}
+}
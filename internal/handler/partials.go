@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxPartialDepth bounds partial-calling-partial recursion, so a _partials
+// file that (directly or transitively) includes itself fails fast with a
+// readable error instead of overflowing the stack.
+const maxPartialDepth = 10
+
+// partialFuncs binds the "partial" template func used by
+// `{{ partial "microsoft/footer" . }}` to baseDir/reqPath: it resolves a
+// partial the same local-then-global way resolveAssetAttr resolves an
+// <img src> (see TestProcessTemplateContentAssetFallback) - the calling
+// template's own vendor `_partials/` directory first, falling back to the
+// top-level `_partials/` shared across every vendor. depth is threaded
+// through so a partial rendering another partial inherits the same func
+// map and recursion guard.
+func partialFuncs(baseDir, reqPath string, depth int) template.FuncMap {
+	return template.FuncMap{
+		"partial": func(name string, data interface{}) template.HTML {
+			if depth >= maxPartialDepth {
+				return partialErrorOverlay(name, fmt.Errorf("too many nested partials (max %d)", maxPartialDepth))
+			}
+
+			content, err := resolvePartial(baseDir, reqPath, name)
+			if err != nil {
+				return partialErrorOverlay(name, err)
+			}
+
+			tmpl, err := template.New("partial:" + name).
+				Funcs(TemplateFuncs).
+				Funcs(pipelineFuncs(baseDir, reqPath)).
+				Funcs(partialFuncs(baseDir, reqPath, depth+1)).
+				Parse(content)
+			if err != nil {
+				return partialErrorOverlay(name, err)
+			}
+
+			var buf strings.Builder
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return partialErrorOverlay(name, err)
+			}
+			return template.HTML(buf.String())
+		},
+	}
+}
+
+// resolvePartial finds the _partials file name refers to (name has no
+// .html extension, e.g. "footer" or "microsoft/footer"), checking the
+// calling template's own vendor directory's _partials/ first and the
+// top-level _partials/ shared across every vendor second.
+func resolvePartial(baseDir, reqPath, name string) (string, error) {
+	rel := filepath.FromSlash(name) + ".html"
+
+	if vendor, _, ok := strings.Cut(filepath.ToSlash(reqPath), "/"); ok && vendor != "" {
+		vendorPath := filepath.Join(baseDir, vendor, "_partials", rel)
+		data, err := os.ReadFile(vendorPath)
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("partial %q: %w", name, err)
+		}
+	}
+
+	globalPath := filepath.Join(baseDir, "_partials", rel)
+	data, err := os.ReadFile(globalPath)
+	if err != nil {
+		return "", fmt.Errorf("partial %q not found (checked <vendor>/_partials/%s and _partials/%s)", name, rel, rel)
+	}
+	return string(data), nil
+}
+
+// partialErrorOverlay renders a partial-resolution or -render failure as a
+// visible, styled block inline in the preview, rather than failing the
+// whole render (processTemplateContentStat would otherwise fall back to
+// raw string replacement and leave the unrendered `{{ partial ... }}` call
+// sitting in the output) or surfacing a 500.
+func partialErrorOverlay(name string, err error) template.HTML {
+	return template.HTML(fmt.Sprintf(
+		`<div class="partial-error" style="border:1px solid #c00;background:#fee;color:#900;padding:.5em;margin:.5em 0;font-family:monospace;white-space:pre-wrap;">partial %s failed: %s</div>`,
+		template.HTMLEscapeString(fmt.Sprintf("%q", name)),
+		template.HTMLEscapeString(err.Error()),
+	))
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessTemplateContentPartialResolvesFromGlobalPartials(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	globalPartials := filepath.Join(tmpDir, "_partials")
+	if err := os.MkdirAll(globalPartials, 0755); err != nil {
+		t.Fatalf("Failed to create _partials dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(globalPartials, "footer.html"), []byte("<footer>{{.Company}}</footer>"), 0644); err != nil {
+		t.Fatalf("Failed to write footer partial: %v", err)
+	}
+
+	content := `<body>{{ partial "footer" . }}</body>`
+	result := processTemplateContent(content, "test-dir/page.html", tmpDir, map[string]string{"{{.Company}}": "Acme"})
+	if !strings.Contains(result, "<footer>Acme</footer>") {
+		t.Errorf("expected the global partial to render, got %q", result)
+	}
+}
+
+func TestProcessTemplateContentPartialVendorOverrideWinsOverGlobal(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	globalPartials := filepath.Join(tmpDir, "_partials")
+	if err := os.MkdirAll(globalPartials, 0755); err != nil {
+		t.Fatalf("Failed to create _partials dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(globalPartials, "footer.html"), []byte("<footer>generic</footer>"), 0644); err != nil {
+		t.Fatalf("Failed to write global footer partial: %v", err)
+	}
+
+	vendorPartials := filepath.Join(tmpDir, "test-dir", "_partials")
+	if err := os.MkdirAll(vendorPartials, 0755); err != nil {
+		t.Fatalf("Failed to create vendor _partials dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(vendorPartials, "footer.html"), []byte("<footer>vendor-specific</footer>"), 0644); err != nil {
+		t.Fatalf("Failed to write vendor footer partial: %v", err)
+	}
+
+	content := `<body>{{ partial "footer" . }}</body>`
+	result := processTemplateContent(content, "test-dir/page.html", tmpDir, map[string]string{})
+	if !strings.Contains(result, "<footer>vendor-specific</footer>") {
+		t.Errorf("expected the vendor _partials override to win, got %q", result)
+	}
+	if strings.Contains(result, "generic") {
+		t.Errorf("expected the global partial not to be used when a vendor override exists, got %q", result)
+	}
+}
+
+func TestProcessTemplateContentPartialWithDictArgs(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	globalPartials := filepath.Join(tmpDir, "_partials")
+	if err := os.MkdirAll(globalPartials, 0755); err != nil {
+		t.Fatalf("Failed to create _partials dir: %v", err)
+	}
+	buttonHTML := `<a href="{{.url}}">{{.label}}</a>`
+	if err := os.WriteFile(filepath.Join(globalPartials, "button.html"), []byte(buttonHTML), 0644); err != nil {
+		t.Fatalf("Failed to write button partial: %v", err)
+	}
+
+	content := `{{ partial "button" (dict "url" "https://example.com" "label" "Click here") }}`
+	result := processTemplateContent(content, "test-dir/page.html", tmpDir, map[string]string{})
+	if !strings.Contains(result, `<a href="https://example.com">Click here</a>`) {
+		t.Errorf("expected the button partial rendered with its dict args, got %q", result)
+	}
+}
+
+func TestProcessTemplateContentMissingPartialRendersOverlayNotCrash(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	content := `<body>{{ partial "does-not-exist" . }}</body>`
+	result := processTemplateContent(content, "test-dir/page.html", tmpDir, map[string]string{})
+	if !strings.Contains(result, "partial-error") {
+		t.Errorf("expected a readable partial-error overlay, got %q", result)
+	}
+	if !strings.Contains(result, "does-not-exist") {
+		t.Errorf("expected the overlay to name the missing partial, got %q", result)
+	}
+	if !strings.Contains(result, "<body>") {
+		t.Errorf("expected the rest of the page to still render around the overlay, got %q", result)
+	}
+}
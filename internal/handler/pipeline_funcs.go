@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"html/template"
+	"path/filepath"
+
+	"github.com/phishingclub/templates/internal/pipeline"
+)
+
+// pipelineFuncs binds the resource/toCSS/minify/fingerprint template funcs
+// to an asset pipeline scoped to the template being rendered at reqPath
+// inside baseDir. Unlike TemplateFuncs' other entries these need to know
+// which template directory a resource name like "styles.scss" is relative
+// to, so they're merged on top of TemplateFuncs only for the per-request
+// "content" template processTemplateContent parses.
+func pipelineFuncs(baseDir, reqPath string) template.FuncMap {
+	p := pipeline.New(baseDir, filepath.Dir(reqPath))
+
+	return template.FuncMap{
+		"resource":    p.Resource,
+		"toCSS":       p.ToCSS,
+		"minify":      p.Minify,
+		"fingerprint": p.Fingerprint,
+		"resize":      p.Resize,
+		"fit":         p.Fit,
+		"fill":        p.Fill,
+	}
+}
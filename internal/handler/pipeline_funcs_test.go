@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessTemplateContentResourcePipeline(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	templateDir := filepath.Join(tmpDir, "test-dir")
+	if err := os.WriteFile(filepath.Join(templateDir, "styles.css"), []byte("body {\n  color: red;\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write styles.css: %v", err)
+	}
+
+	content := `{{ $css := resource "styles.css" | toCSS | minify | fingerprint }}<link rel="stylesheet" href="{{ $css.URL }}" integrity="{{ $css.Integrity }}">`
+	result := processTemplateContent(content, "test-dir/page.html", tmpDir, templateVars)
+
+	if !strings.Contains(result, "/templates/assets/_gen/styles.") {
+		t.Errorf("expected a fingerprinted asset URL, got %q", result)
+	}
+	if !strings.Contains(result, "integrity=\"sha384-") {
+		t.Errorf("expected a sha384 SRI integrity attribute, got %q", result)
+	}
+}
+
+func TestProcessTemplateContentResizePipeline(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	templateDir := filepath.Join(tmpDir, "test-dir")
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "logo.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	content := `{{ $img := resource "logo.png" | fit "40x40" | fingerprint }}<img src="{{ $img.URL }}">`
+	result := processTemplateContent(content, "test-dir/page.html", tmpDir, templateVars)
+
+	if !strings.Contains(result, "/templates/assets/_gen/logo.") {
+		t.Errorf("expected a fingerprinted image URL, got %q", result)
+	}
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PreviewConfig is a template directory's preview.yaml/preview.json: extra
+// data-context values merged into a render alongside the usual
+// "{{.Name}}" vars, and a list of opt-in builtin template functions (see
+// builtinPreviewFuncs) to make available to it. It's layered the same way
+// vars.yaml/<template>.vars.yaml already layer template variables - a
+// repo-wide preview.yaml next to the templates directory, overridden by one
+// sitting in the template's own directory.
+type PreviewConfig struct {
+	Vars  map[string]any `yaml:"vars" json:"vars"`
+	Funcs []string       `yaml:"funcs" json:"funcs"`
+}
+
+// mergePreviewConfig layers override on top of base: override's vars win on
+// a key collision, and its funcs list is unioned with base's rather than
+// replacing it, since opting into one more builtin in a per-directory
+// preview.yaml shouldn't silently drop ones the repo-wide config enabled.
+func mergePreviewConfig(base, override *PreviewConfig) *PreviewConfig {
+	merged := &PreviewConfig{Vars: make(map[string]any)}
+	for _, cfg := range []*PreviewConfig{base, override} {
+		if cfg == nil {
+			continue
+		}
+		for k, v := range cfg.Vars {
+			merged.Vars[k] = v
+		}
+		merged.Funcs = append(merged.Funcs, cfg.Funcs...)
+	}
+	merged.Funcs = dedupeStrings(merged.Funcs)
+	return merged
+}
+
+// dedupeStrings returns in with duplicate entries removed, preserving the
+// order of each value's first occurrence.
+func dedupeStrings(in []string) []string {
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// parsePreviewConfig parses a preview.yaml/preview.json's raw bytes,
+// selecting YAML or JSON by name's extension - the same convention
+// loadBareVarsFile uses for vars.yaml/vars.json.
+func parsePreviewConfig(name string, data []byte) (*PreviewConfig, error) {
+	var cfg PreviewConfig
+	var err error
+	if strings.HasSuffix(strings.ToLower(name), ".json") {
+		err = json.Unmarshal(data, &cfg)
+	} else {
+		err = yaml.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(name), err)
+	}
+	return &cfg, nil
+}
+
+// loadPreviewConfigFile reads and parses a preview.yaml/preview.yml/
+// preview.json in dir, returning an empty config (not an error) if none of
+// the three exists.
+func loadPreviewConfigFile(dir string) (*PreviewConfig, error) {
+	for _, name := range []string{"preview.yaml", "preview.yml", "preview.json"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parsePreviewConfig(name, data)
+	}
+	return &PreviewConfig{}, nil
+}
+
+// effectivePreviewConfig merges the repo-wide preview.yaml sibling to
+// baseDir (the same directory loadBaseTemplateVars looks for vars.yaml in)
+// with a per-template-directory override sitting alongside reqPath itself
+// under baseDir (e.g. "Microsoft/Emails/Test/preview.yaml" overriding just
+// that one template), the latter winning on conflicting vars.
+func effectivePreviewConfig(baseDir, reqPath string) (*PreviewConfig, error) {
+	base, err := loadPreviewConfigFile(filepath.Dir(baseDir))
+	if err != nil {
+		return nil, err
+	}
+	override, err := loadPreviewConfigFile(filepath.Join(baseDir, filepath.Dir(reqPath)))
+	if err != nil {
+		return nil, err
+	}
+	return mergePreviewConfig(base, override), nil
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEffectivePreviewConfigMergesDirOverrideOverRepoWide(t *testing.T) {
+	tmpDir := t.TempDir()
+	baseDir := filepath.Join(tmpDir, "phishing-templates")
+	templateDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+
+	repoWide := "vars:\n  Company: Acme\nfuncs:\n  - uuid\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "preview.yaml"), []byte(repoWide), 0644); err != nil {
+		t.Fatalf("Failed to write repo-wide preview.yaml: %v", err)
+	}
+
+	dirOverride := "vars:\n  Company: Campaign A Co\nfuncs:\n  - now\n"
+	if err := os.WriteFile(filepath.Join(templateDir, "preview.yaml"), []byte(dirOverride), 0644); err != nil {
+		t.Fatalf("Failed to write per-directory preview.yaml: %v", err)
+	}
+
+	cfg, err := effectivePreviewConfig(baseDir, "campaign-a/page.html")
+	if err != nil {
+		t.Fatalf("effectivePreviewConfig() error = %v", err)
+	}
+	if cfg.Vars["Company"] != "Campaign A Co" {
+		t.Errorf("expected the per-directory override to win, got %+v", cfg.Vars)
+	}
+	if len(cfg.Funcs) != 2 {
+		t.Errorf("expected both the repo-wide and per-directory funcs to be unioned, got %+v", cfg.Funcs)
+	}
+}
+
+func TestEffectivePreviewConfigEmptyWhenNoFilesExist(t *testing.T) {
+	baseDir := t.TempDir()
+
+	cfg, err := effectivePreviewConfig(baseDir, "campaign-a/page.html")
+	if err != nil {
+		t.Fatalf("effectivePreviewConfig() error = %v", err)
+	}
+	if len(cfg.Vars) != 0 || len(cfg.Funcs) != 0 {
+		t.Errorf("expected an empty config, got %+v", cfg)
+	}
+}
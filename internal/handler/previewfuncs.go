@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	mrand "math/rand"
+	"strings"
+	"time"
+)
+
+// builtinPreviewFuncs is the catalog of optional template helpers a
+// preview.yaml's funcs: list can opt into by name. They're kept out of
+// TemplateFuncs (always available to every render) because a couple of
+// them - "date" in particular - deliberately reuse a name TemplateFuncs
+// already has with different semantics (Go reference-layout formatting of
+// a piped time.Time, vs. TemplateFuncs' PHP-style date()); a template
+// should only get that behavior when its own preview.yaml asks for it.
+//
+// faker.name and faker.email both resolve to the same "faker" entry: a
+// template can only chain a field onto a bare registered identifier, not
+// call one with a dotted name ({{faker.name}} parses as "call faker with
+// no arguments, then look up the string key \"name\" in whatever it
+// returns" - see fakerData), so there's nothing to register separately for
+// the two config names.
+var builtinPreviewFuncs = map[string]template.FuncMap{
+	"faker.name":  {"faker": fakerData},
+	"faker.email": {"faker": fakerData},
+	"now":         {"now": time.Now},
+	"uuid":        {"uuid": newUUID},
+	"b64":         {"b64": b64Encode},
+	"urlquery":    {"urlquery": template.URLQueryEscaper},
+	"date":        {"date": formatPipedTime},
+}
+
+// previewFuncMap resolves a preview.yaml funcs: list into the FuncMap to
+// merge on top of TemplateFuncs for one render. Names it doesn't recognize
+// are skipped - an unknown helper in a preview.yaml shouldn't break every
+// other template sharing it.
+func previewFuncMap(names []string) template.FuncMap {
+	merged := template.FuncMap{}
+	for _, name := range names {
+		if fns, ok := builtinPreviewFuncs[name]; ok {
+			for k, v := range fns {
+				merged[k] = v
+			}
+		}
+	}
+	return merged
+}
+
+// formatPipedTime formats t using a Go reference-layout string, e.g.
+// {{ now | date "2006-01-02" }}.
+func formatPipedTime(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+// b64Encode is the standard-base64 encoding of s, under the shorter name
+// the funcs: list references ("b64", matching the common Sprig-style
+// convention) rather than TemplateFuncs' existing "base64" key.
+func b64Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// newUUID returns a random (version 4, RFC 4122) UUID string.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate uuid: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+var fakerFirstNames = []string{
+	"James", "Mary", "Robert", "Patricia", "John",
+	"Jennifer", "Michael", "Linda", "William", "Elizabeth",
+}
+var fakerLastNames = []string{
+	"Smith", "Johnson", "Williams", "Brown", "Jones",
+	"Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+}
+var fakerDomains = []string{"example.com", "example.org", "example.net"}
+
+// fakerData returns a freshly-generated fake name and email as the map
+// {{faker.name}}/{{faker.email}} index into - see builtinPreviewFuncs.
+func fakerData() map[string]string {
+	first := fakerFirstNames[randIndex(len(fakerFirstNames))]
+	last := fakerLastNames[randIndex(len(fakerLastNames))]
+	email := strings.ToLower(first) + "." + strings.ToLower(last) + "@" + fakerDomains[randIndex(len(fakerDomains))]
+	return map[string]string{
+		"name":  first + " " + last,
+		"email": email,
+	}
+}
+
+func randIndex(n int) int {
+	// #nosec
+	return mrand.Intn(n)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPreviewFuncMapSkipsUnknownNames(t *testing.T) {
+	funcs := previewFuncMap([]string{"uuid", "not-a-real-helper"})
+	if _, ok := funcs["uuid"]; !ok {
+		t.Fatalf("expected uuid to be registered, got %+v", funcs)
+	}
+	if len(funcs) != 1 {
+		t.Errorf("expected only the recognized name to be registered, got %+v", funcs)
+	}
+}
+
+func TestPreviewFuncMapFakerAliasesShareOneFunc(t *testing.T) {
+	funcs := previewFuncMap([]string{"faker.name", "faker.email"})
+	if _, ok := funcs["faker"]; !ok {
+		t.Fatalf("expected faker.name and faker.email to both resolve to a \"faker\" func, got %+v", funcs)
+	}
+	if len(funcs) != 1 {
+		t.Errorf("expected exactly one registered func, got %+v", funcs)
+	}
+}
+
+func TestFakerDataLooksLikeAnEmail(t *testing.T) {
+	data := fakerData()
+	if !strings.Contains(data["email"], "@") {
+		t.Errorf("expected a fake email address, got %q", data["email"])
+	}
+	if data["name"] == "" {
+		t.Error("expected a non-empty fake name")
+	}
+}
+
+func TestNewUUIDLooksLikeAV4UUID(t *testing.T) {
+	id, err := newUUID()
+	if err != nil {
+		t.Fatalf("newUUID() error = %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("newUUID() = %q, doesn't look like a v4 UUID", id)
+	}
+}
+
+func TestB64EncodeRoundTrips(t *testing.T) {
+	if got := b64Encode("hello"); got != "aGVsbG8=" {
+		t.Errorf("b64Encode(\"hello\") = %q, want %q", got, "aGVsbG8=")
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// readmeMarkdown renders README.md files with GFM extensions (tables,
+// strikethrough, autolinks) enabled, matching the GitHub/Gitea convention
+// template authors already expect.
+var readmeMarkdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// readmeSanitizer strips anything goldmark's output shouldn't be trusted to
+// leave in before it's embedded in the listing UI as template.HTML.
+var readmeSanitizer = bluemonday.UGCPolicy()
+
+// findReadme looks for a README.md among files (case-insensitive) and
+// returns its DirEntry, or nil if there isn't one.
+func findReadme(files []fs.DirEntry) fs.DirEntry {
+	for _, entry := range files {
+		if !entry.IsDir() && strings.EqualFold(entry.Name(), "README.md") {
+			return entry
+		}
+	}
+	return nil
+}
+
+// renderReadme renders dirPath's README.md (if any) in fsys to sanitized
+// HTML for display below the file grid in the directory listing. It
+// returns an empty template.HTML, nil when there is no README.md.
+func renderReadme(fsys fs.FS, dirPath string, files []fs.DirEntry) (template.HTML, error) {
+	entry := findReadme(files)
+	if entry == nil {
+		return "", nil
+	}
+
+	content, err := fs.ReadFile(fsys, path.Join(dirPath, entry.Name()))
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := readmeMarkdown.Convert(content, &buf); err != nil {
+		return "", err
+	}
+
+	return template.HTML(readmeSanitizer.SanitizeBytes(buf.Bytes())), nil
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRenderReadmeRendersMarkdown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"campaign/README.md": {Data: []byte("# Pretext\n\nTargets IT staff. <script>alert(1)</script>\n")},
+		"campaign/email.html": {Data: []byte("<html></html>")},
+	}
+	files, err := fsys.ReadDir("campaign")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+
+	html, err := renderReadme(fsys, "campaign", files)
+	if err != nil {
+		t.Fatalf("renderReadme() error = %v", err)
+	}
+	if !strings.Contains(string(html), "<h1") {
+		t.Errorf("expected rendered heading, got %q", html)
+	}
+	if strings.Contains(string(html), "<script>") {
+		t.Errorf("expected script tag to be sanitized out, got %q", html)
+	}
+}
+
+func TestRenderReadmeCaseInsensitive(t *testing.T) {
+	fsys := fstest.MapFS{
+		"campaign/readme.md": {Data: []byte("lowercase readme")},
+	}
+	files, err := fsys.ReadDir("campaign")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+
+	html, err := renderReadme(fsys, "campaign", files)
+	if err != nil {
+		t.Fatalf("renderReadme() error = %v", err)
+	}
+	if !strings.Contains(string(html), "lowercase readme") {
+		t.Errorf("expected lowercase readme.md to be picked up, got %q", html)
+	}
+}
+
+func TestRenderReadmeNone(t *testing.T) {
+	fsys := fstest.MapFS{
+		"campaign/email.html": {Data: []byte("<html></html>")},
+	}
+	files, err := fsys.ReadDir("campaign")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+
+	html, err := renderReadme(fsys, "campaign", files)
+	if err != nil {
+		t.Fatalf("renderReadme() error = %v", err)
+	}
+	if html != "" {
+		t.Errorf("expected empty HTML when no README.md exists, got %q", html)
+	}
+}
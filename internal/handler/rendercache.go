@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// statRecord is one stat result consulted while resolving an asset path -
+// recorded whether the file existed or not, since the asset-fallback logic
+// in resolveAssetAttr stats nonexistent candidate paths constantly and a
+// cached render is only valid while every one of those misses is still a
+// miss.
+type statRecord struct {
+	Path    string
+	Exists  bool
+	ModTime time.Time
+}
+
+// statRecorder wraps os.Stat as a statFunc, appending every call's outcome
+// so a render can later be revalidated by re-checking just the paths it
+// actually consulted instead of re-rendering from scratch.
+type statRecorder struct {
+	records []statRecord
+}
+
+func (s *statRecorder) stat(name string) (os.FileInfo, error) {
+	info, err := os.Stat(name)
+	switch {
+	case err == nil:
+		s.records = append(s.records, statRecord{Path: name, Exists: true, ModTime: info.ModTime()})
+	case os.IsNotExist(err):
+		s.records = append(s.records, statRecord{Path: name, Exists: false})
+	}
+	return info, err
+}
+
+// statsStillValid re-stats every path a render consulted and reports whether the
+// outcome (exists + mtime, or still missing) is unchanged.
+func statsStillValid(records []statRecord) bool {
+	for _, rec := range records {
+		info, err := os.Stat(rec.Path)
+		switch {
+		case err == nil && rec.Exists:
+			if !info.ModTime().Equal(rec.ModTime) {
+				return false
+			}
+		case os.IsNotExist(err) && !rec.Exists:
+			// still missing, as recorded
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type renderCacheEntry struct {
+	Rendered string
+	Stats    []statRecord
+}
+
+// RenderStats reports a Renderer's render-cache hit/miss counters.
+type RenderStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// renderCacheKey hashes the rendered template's full identity - its source
+// content, the request path (asset resolution is relative to it), every
+// effective template variable, and the effective preview.yaml (its extra
+// vars and opted-in funcs list) - so two requests only share a cache entry
+// when all of that is identical. Without the preview.yaml bytes in the key,
+// editing one wouldn't invalidate a render that already ran with the old
+// funcs list or vars enabled.
+func renderCacheKey(content, reqPath string, vars map[string]string, previewCfg *PreviewConfig) string {
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	h.Write([]byte(content))
+	h.Write([]byte{0})
+	h.Write([]byte(reqPath))
+	for _, k := range names {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(vars[k]))
+	}
+	if previewCfg != nil {
+		if encoded, err := json.Marshal(previewCfg); err == nil {
+			h.Write([]byte{0})
+			h.Write(encoded)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Render renders content for reqPath, reusing a cached result when one
+// exists and every file it consulted while resolving asset paths is
+// unchanged. A cache hit skips template execution and HTML tokenization
+// entirely.
+//
+// Render returns a non-nil error only when the underlying render was
+// aborted by SafeExecute's Limits (a *LimitError) - that render is not
+// cached, since it produced no usable content. An ordinary template error
+// is handled internally (see processTemplateContentStat) and never
+// surfaces here.
+func (r *Renderer) Render(content, reqPath string, vars map[string]string) (string, error) {
+	previewCfg, _ := effectivePreviewConfig(r.baseDir, reqPath)
+	key := renderCacheKey(content, reqPath, vars, previewCfg)
+
+	if entry, ok := r.renderCache.Get(key); ok {
+		if statsStillValid(entry.Stats) {
+			r.hits.Add(1)
+			return entry.Rendered, nil
+		}
+		r.renderCache.Remove(key)
+	} else if entry, ok := r.loadDiskEntry(key); ok {
+		r.hits.Add(1)
+		r.renderCache.Add(key, entry)
+		return entry.Rendered, nil
+	}
+	r.misses.Add(1)
+
+	rec := &statRecorder{}
+	rendered, err := processTemplateContentStat(content, reqPath, r.baseDir, vars, rec.stat, previewCfg)
+	if err != nil {
+		return "", err
+	}
+
+	entry := renderCacheEntry{Rendered: rendered, Stats: rec.records}
+	r.renderCache.Add(key, entry)
+	r.writeDiskEntry(key, entry)
+
+	return rendered, nil
+}
+
+// Invalidate drops every cached render that consulted path during asset
+// resolution - the file watcher calls this when path changes on disk, since
+// editing an asset invalidates any render that found (or didn't find) it at
+// that path, not just one keyed directly on it.
+func (r *Renderer) Invalidate(path string) {
+	for _, key := range r.renderCache.Keys() {
+		entry, ok := r.renderCache.Peek(key)
+		if !ok {
+			continue
+		}
+		for _, rec := range entry.Stats {
+			if rec.Path == path {
+				r.renderCache.Remove(key)
+				r.removeDiskEntry(key)
+				break
+			}
+		}
+	}
+}
+
+// Stats returns the render cache's hit/miss counters.
+func (r *Renderer) Stats() RenderStats {
+	return RenderStats{Hits: r.hits.Load(), Misses: r.misses.Load()}
+}
+
+// renderCacheDir is the on-disk tier under baseDir that keeps the render
+// cache warm across restarts - the in-memory LRU alone would start every
+// process back at zero hits.
+func (r *Renderer) renderCacheDir() string {
+	return filepath.Join(r.baseDir, ".cache", "render")
+}
+
+func (r *Renderer) writeDiskEntry(key string, entry renderCacheEntry) {
+	dir := r.renderCacheDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(filepath.Join(dir, key+".json"), data, 0644)
+}
+
+func (r *Renderer) removeDiskEntry(key string) {
+	_ = os.Remove(filepath.Join(r.renderCacheDir(), key+".json"))
+}
+
+// loadDiskEntry reads a previously persisted render for key, validating its
+// recorded stats before returning it so a stale on-disk entry from a prior
+// run doesn't get served blindly.
+func (r *Renderer) loadDiskEntry(key string) (renderCacheEntry, bool) {
+	data, err := os.ReadFile(filepath.Join(r.renderCacheDir(), key+".json"))
+	if err != nil {
+		return renderCacheEntry{}, false
+	}
+	var entry renderCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return renderCacheEntry{}, false
+	}
+	if !statsStillValid(entry.Stats) {
+		return renderCacheEntry{}, false
+	}
+	return entry, true
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRendererRenderCachesHitOnUnchangedAssets(t *testing.T) {
+	baseDir := t.TempDir()
+	templateDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "logo.png"), []byte("logo"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	r := NewRenderer(baseDir, nil)
+	content := `<img src="logo.png">`
+
+	first, err := r.Render(content, "campaign-a/page.html", nil)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	second, err := r.Render(content, "campaign-a/page.html", nil)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	if first != second {
+		t.Errorf("expected identical renders, got %q then %q", first, second)
+	}
+
+	stats := r.Stats()
+	if stats.Misses != 1 || stats.Hits != 1 {
+		t.Errorf("expected 1 miss and 1 hit, got %+v", stats)
+	}
+}
+
+func TestRendererRenderInvalidatesOnAssetChange(t *testing.T) {
+	baseDir := t.TempDir()
+	templateDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	logoPath := filepath.Join(templateDir, "logo.png")
+	if err := os.WriteFile(logoPath, []byte("logo"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	r := NewRenderer(baseDir, nil)
+	content := `<img src="logo.png">`
+
+	if _, err := r.Render(content, "campaign-a/page.html", nil); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(logoPath, later, later); err != nil {
+		t.Fatalf("Failed to bump mtime: %v", err)
+	}
+
+	if _, err := r.Render(content, "campaign-a/page.html", nil); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	stats := r.Stats()
+	if stats.Misses != 2 {
+		t.Errorf("expected the mtime change to force a second miss, got %+v", stats)
+	}
+}
+
+func TestRendererInvalidateDropsEntriesReferencingPath(t *testing.T) {
+	baseDir := t.TempDir()
+	templateDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	logoPath := filepath.Join(templateDir, "logo.png")
+	if err := os.WriteFile(logoPath, []byte("logo"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	r := NewRenderer(baseDir, nil)
+	content := `<img src="logo.png">`
+
+	if _, err := r.Render(content, "campaign-a/page.html", nil); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	r.Invalidate(logoPath)
+	if _, err := r.Render(content, "campaign-a/page.html", nil); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	stats := r.Stats()
+	if stats.Misses != 2 {
+		t.Errorf("expected Invalidate to force a fresh render, got %+v", stats)
+	}
+}
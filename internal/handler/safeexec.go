@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// Limits bounds a single template render: how long it may run, how much
+// output it may produce, and how large a value a guarded template func like
+// randAlpha may be asked for. DefaultLimits is what processTemplateContentStat
+// uses; an embedder running less-trusted template authors can tighten it by
+// passing its own Limits to SafeExecute.
+type Limits struct {
+	Timeout            time.Duration
+	MaxOutputBytes     int64
+	MaxRandAlphaLength int
+}
+
+// DefaultLimits guards a render against a runaway or malicious template - an
+// infinite range/recursion, or a func call asked to produce more output than
+// is reasonable - before this module is embedded somewhere template authors
+// aren't fully trusted.
+var DefaultLimits = Limits{
+	Timeout:            2 * time.Second,
+	MaxOutputBytes:     5 * 1024 * 1024,
+	MaxRandAlphaLength: 32,
+}
+
+// LimitError is returned by SafeExecute when a render is aborted by Limits
+// rather than failing on its own terms - a template's own Parse/Execute
+// error keeps its original type. Callers use errors.As to tell the two
+// apart: a LimitError means the template may be actively abusive and is
+// worth surfacing as a hard failure rather than the usual graceful
+// string-replacement fallback.
+type LimitError struct {
+	// Reason is a short, human-readable description of which limit was hit.
+	Reason string
+}
+
+func (e *LimitError) Error() string {
+	return "template execution limit exceeded: " + e.Reason
+}
+
+// boundedWriter wraps an io.Writer and fails once more than limit bytes have
+// passed through it, so a template producing unbounded output (e.g. an
+// infinite range) can't exhaust memory before Timeout has a chance to fire.
+type boundedWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (bw *boundedWriter) Write(p []byte) (int, error) {
+	if bw.written+int64(len(p)) > bw.limit {
+		return 0, &LimitError{Reason: fmt.Sprintf("output exceeded %d bytes", bw.limit)}
+	}
+	n, err := bw.w.Write(p)
+	bw.written += int64(n)
+	return n, err
+}
+
+// SafeExecute runs tmpl.Execute(w, data), guarded by limits: it's given
+// limits.Timeout to finish, its output is capped at limits.MaxOutputBytes,
+// and a panic that escapes Execute - text/template already recovers a
+// panicking template func itself and turns it into an ordinary error, so
+// what reaches here is something more severe, like the runtime.Error a
+// stack overflow from runaway named-template recursion raises - is
+// recovered and returned as a *LimitError instead of crashing the server.
+// An ordinary Execute error - a template referencing a missing field, a
+// func returning its own error - is returned unchanged.
+//
+// Execute runs on a goroutine that keeps going after a timeout fires, since
+// text/template offers no way to interrupt it mid-execution; w must not be
+// read again after SafeExecute returns a *LimitError, as the goroutine may
+// still be writing to it.
+func SafeExecute(tmpl *template.Template, data any, w io.Writer, limits Limits) error {
+	bw := &boundedWriter{w: w, limit: limits.MaxOutputBytes}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- &LimitError{Reason: fmt.Sprintf("panic: %v", rec)}
+			}
+		}()
+		done <- tmpl.Execute(bw, data)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), limits.Timeout)
+	defer cancel()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return &LimitError{Reason: fmt.Sprintf("exceeded %s timeout", limits.Timeout)}
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSafeExecuteRunsOrdinaryTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("t").Parse("hello {{.Name}}"))
+	var buf bytes.Buffer
+
+	if err := SafeExecute(tmpl, map[string]string{"Name": "world"}, &buf, DefaultLimits); err != nil {
+		t.Fatalf("SafeExecute failed: %v", err)
+	}
+	if buf.String() != "hello world" {
+		t.Errorf("got %q, want %q", buf.String(), "hello world")
+	}
+}
+
+func TestSafeExecuteReturnsOrdinaryExecuteErrorUnwrapped(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(template.FuncMap{
+		"boom": func() (string, error) { return "", errors.New("boom") },
+	}).Parse("{{boom}}"))
+	var buf bytes.Buffer
+
+	err := SafeExecute(tmpl, nil, &buf, DefaultLimits)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		t.Errorf("expected an ordinary execute error, got a LimitError: %v", err)
+	}
+}
+
+func TestSafeExecuteTimesOut(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(template.FuncMap{
+		"sleep": func() string { time.Sleep(50 * time.Millisecond); return "" },
+	}).Parse("{{sleep}}"))
+	var buf bytes.Buffer
+
+	err := SafeExecute(tmpl, nil, &buf, Limits{Timeout: time.Millisecond, MaxOutputBytes: DefaultLimits.MaxOutputBytes})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a *LimitError, got %v", err)
+	}
+	if !strings.Contains(limitErr.Reason, "timeout") {
+		t.Errorf("expected a timeout reason, got %q", limitErr.Reason)
+	}
+}
+
+func TestSafeExecuteAbortsOnOutputOverflow(t *testing.T) {
+	tmpl := template.Must(template.New("t").Parse(strings.Repeat("x", 1024)))
+	var buf bytes.Buffer
+
+	err := SafeExecute(tmpl, nil, &buf, Limits{Timeout: DefaultLimits.Timeout, MaxOutputBytes: 10})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a *LimitError, got %v", err)
+	}
+	if !strings.Contains(limitErr.Reason, "output exceeded") {
+		t.Errorf("expected an output-limit reason, got %q", limitErr.Reason)
+	}
+}
+
+// panicWriter always panics on Write, standing in for the kind of panic
+// that escapes text/template's own recovery - a panicking template func is
+// caught by text/template itself (via its safeCall) and surfaces as an
+// ordinary Execute error, never reaching SafeExecute's recover at all.
+type panicWriter struct{}
+
+func (panicWriter) Write(p []byte) (int, error) {
+	panic("kaboom")
+}
+
+func TestSafeExecuteDoesNotTreatAPanickingTemplateFuncAsALimitError(t *testing.T) {
+	tmpl := template.Must(template.New("t").Funcs(template.FuncMap{
+		"boom": func() (string, error) { panic("kaboom") },
+	}).Parse("{{boom}}"))
+	var buf bytes.Buffer
+
+	err := SafeExecute(tmpl, nil, &buf, DefaultLimits)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		t.Errorf("a panicking template func should surface as an ordinary Execute error, not a LimitError: %v", err)
+	}
+}
+
+func TestSafeExecuteRecoversPanic(t *testing.T) {
+	tmpl := template.Must(template.New("t").Parse("hello"))
+
+	err := SafeExecute(tmpl, nil, panicWriter{}, DefaultLimits)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a *LimitError, got %v", err)
+	}
+	if !strings.Contains(limitErr.Reason, "panic") {
+		t.Errorf("expected a panic reason, got %q", limitErr.Reason)
+	}
+}
@@ -586,6 +586,70 @@ func TestValidatePathEncodingBypass(t *testing.T) {
 			expectError: true,
 			description: "Form feed in path should be blocked",
 		},
+
+		// Windows reserved device names
+		{
+			name:        "ReservedNameCon",
+			reqPath:     "CON",
+			expectError: true,
+			description: "Windows reserved device name CON should be blocked",
+		},
+		{
+			name:        "ReservedNameLowercase",
+			reqPath:     "con",
+			expectError: true,
+			description: "Reserved device name check should be case-insensitive",
+		},
+		{
+			name:        "ReservedNameWithExtension",
+			reqPath:     "CON.html",
+			expectError: true,
+			description: "Reserved device name with an extension should still be blocked",
+		},
+		{
+			name:        "ReservedNameCom1",
+			reqPath:     "COM1.txt",
+			expectError: true,
+			description: "Reserved device name COM1 should be blocked",
+		},
+		{
+			name:        "ReservedNameLpt9",
+			reqPath:     "lpt9",
+			expectError: true,
+			description: "Reserved device name LPT9 should be blocked",
+		},
+		{
+			name:        "ReservedNameNestedSegment",
+			reqPath:     "templates/aux/file.html",
+			expectError: true,
+			description: "A reserved device name in a non-final path segment should be blocked",
+		},
+		{
+			name:        "NotAReservedNamePrefix",
+			reqPath:     "constable.html",
+			expectError: false,
+			description: "A filename that merely starts with a reserved basename should not be blocked",
+		},
+
+		// Trailing dot/space in a path segment
+		{
+			name:        "TrailingDotInFilename",
+			reqPath:     "logo.png.",
+			expectError: true,
+			description: "A filename ending in a dot should be blocked",
+		},
+		{
+			name:        "TrailingSpaceInFilename",
+			reqPath:     "logo.png ",
+			expectError: true,
+			description: "A filename ending in a space should be blocked",
+		},
+		{
+			name:        "TrailingDotInDirSegment",
+			reqPath:     "templates./logo.png",
+			expectError: true,
+			description: "A directory segment ending in a dot should be blocked",
+		},
 	}
 
 	for _, tt := range encodingTests {
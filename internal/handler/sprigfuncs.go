@@ -0,0 +1,408 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RegisterFuncsOptions selects which optional namespaces RegisterFuncs adds
+// on top of its always-on string/list/math helpers. The zero value adds
+// none of them - a template only gets sha256sum, now, env and the like once something asks for that namespace by name, the same opt-in
+// shape builtinPreviewFuncs already uses for preview.yaml's funcs: list.
+type RegisterFuncsOptions struct {
+	// Crypto adds b64enc/b64dec, urlquery, sha1sum, sha256sum, md5sum and
+	// hmacSha256.
+	Crypto bool
+
+	// Regex adds nothing further: regexMatch and regexReplace are always
+	// registered along with the string helpers.
+	Regex bool
+
+	// Time adds now, dateModify, dateInZone and unixEpoch.
+	Time bool
+
+	// Env adds an "env" accessor for process environment variables. This
+	// is opt-in on its own, independent of the other namespaces: a
+	// phishing template that can read the server's environment is a much
+	// bigger blast radius than string/list/math/crypto/regex/time
+	// helpers, so omitting it from the other namespace flags isn't
+	// sufficient to leave it out - Env itself has to be false.
+	Env bool
+}
+
+// RegisterFuncs returns tmpl with the Sprig-compatible function library
+// added: stringFuncs, listFuncs and mathFuncs always, plus whichever
+// optional namespaces opts selects. It's additive with TemplateFuncs -
+// call tmpl.Funcs(TemplateFuncs) first if the template also needs the
+// original ad-hoc helpers (split, dict, date, qr, ...); RegisterFuncs
+// doesn't assume that's wanted, since a template built purely from this
+// library might deliberately want nothing else in scope.
+func RegisterFuncs(tmpl *template.Template, opts RegisterFuncsOptions) *template.Template {
+	tmpl = tmpl.Funcs(stringFuncs).Funcs(listFuncs).Funcs(mathFuncs)
+	if opts.Crypto {
+		tmpl = tmpl.Funcs(cryptoFuncs)
+	}
+	if opts.Regex {
+		tmpl = tmpl.Funcs(regexFuncs)
+	}
+	if opts.Time {
+		tmpl = tmpl.Funcs(timeFuncs)
+	}
+	if opts.Env {
+		tmpl = tmpl.Funcs(template.FuncMap{"env": os.Getenv})
+	}
+	return tmpl
+}
+
+var stringFuncs = template.FuncMap{
+	"upper":        strings.ToUpper,
+	"lower":        strings.ToLower,
+	"title":        titleCase,
+	"trim":         strings.TrimSpace,
+	"trimPrefix":   func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix":   func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":      func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":     func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":    func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"regexReplace": regexReplace,
+	"regexMatch":   regexMatch,
+}
+
+// titleCase capitalizes the first letter of each space-separated word in
+// s. strings.Title is deprecated (it mishandles anything outside ASCII
+// and punctuation-adjacent letters) and this package has no dependency on
+// golang.org/x/text/cases, so a small ASCII-oriented version is enough for
+// what a phishing template actually needs it for - capitalizing a name or
+// company field.
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		r := []rune(w)
+		if len(r) > 0 {
+			r[0] = []rune(strings.ToUpper(string(r[0])))[0]
+		}
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
+
+// regexReplace replaces every match of pattern in s with repl, using Go's
+// RE2 syntax and $1-style capture group references in repl.
+func regexReplace(pattern, repl, s string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+	return re.ReplaceAllString(s, repl), nil
+}
+
+// regexMatch reports whether s contains a match for pattern.
+func regexMatch(pattern, s string) (bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+	return re.MatchString(s), nil
+}
+
+// listFuncs operates on a []interface{} the way a template's range/pipeline
+// produces one - via reflect rather than a concrete slice type, so a
+// template can pipe the result of one of these into another regardless of
+// what element type it holds.
+var listFuncs = template.FuncMap{
+	"list":      func(items ...any) []any { return items },
+	"first":     listFirst,
+	"last":      listLast,
+	"slice":     listSlice,
+	"uniq":      listUniq,
+	"reverse":   listReverse,
+	"sortAlpha": listSortAlpha,
+}
+
+func listValue(list any) (reflect.Value, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return reflect.Value{}, fmt.Errorf("expected a list, got %T", list)
+	}
+	return v, nil
+}
+
+func listFirst(list any) (any, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	return v.Index(0).Interface(), nil
+}
+
+func listLast(list any) (any, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	return v.Index(v.Len() - 1).Interface(), nil
+}
+
+// listSlice returns list[start:end], clamped to list's bounds rather than
+// erroring on an out-of-range index - a template author counting elements
+// by hand is more likely to be off-by-one than to want a hard failure.
+func listSlice(list any, start, end int) (any, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > v.Len() {
+		end = v.Len()
+	}
+	if start > end {
+		start = end
+	}
+	return v.Slice(start, end).Interface(), nil
+}
+
+func listUniq(list any) (any, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	out := reflect.MakeSlice(v.Type(), 0, v.Len())
+	seen := make(map[any]bool, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		key := item.Interface()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = reflect.Append(out, item)
+	}
+	return out.Interface(), nil
+}
+
+func listReverse(list any) (any, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	for i := 0; i < v.Len(); i++ {
+		out.Index(v.Len() - 1 - i).Set(v.Index(i))
+	}
+	return out.Interface(), nil
+}
+
+// listSortAlpha sorts list by each element's string representation
+// (fmt.Sprint), the same fallback Sprig's own sortAlpha uses for a list
+// that isn't already []string.
+func listSortAlpha(list any) (any, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(out, v)
+	items := make([]reflect.Value, out.Len())
+	for i := range items {
+		items[i] = out.Index(i)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return fmt.Sprint(items[i].Interface()) < fmt.Sprint(items[j].Interface())
+	})
+	sorted := reflect.MakeSlice(v.Type(), out.Len(), out.Len())
+	for i, item := range items {
+		sorted.Index(i).Set(item)
+	}
+	return sorted.Interface(), nil
+}
+
+// mathFuncs implements add/sub/mul/div/mod/min/max over int64, matching
+// Sprig's own integer-only (not addf/subf/...) arithmetic helpers. Inputs
+// come from a template pipeline as arbitrary numeric types (int, float64
+// from a parsed JSON vars.yaml value, etc.), so each is coerced with
+// toInt64 before the operation.
+var mathFuncs = template.FuncMap{
+	"add": func(a, b any) (int64, error) { return mathBinary(a, b, func(x, y int64) int64 { return x + y }) },
+	"sub": func(a, b any) (int64, error) { return mathBinary(a, b, func(x, y int64) int64 { return x - y }) },
+	"mul": func(a, b any) (int64, error) { return mathBinary(a, b, func(x, y int64) int64 { return x * y }) },
+	"div": func(a, b any) (int64, error) {
+		y, err := toInt64(b)
+		if err != nil {
+			return 0, err
+		}
+		if y == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		x, err := toInt64(a)
+		if err != nil {
+			return 0, err
+		}
+		return x / y, nil
+	},
+	"mod": func(a, b any) (int64, error) {
+		y, err := toInt64(b)
+		if err != nil {
+			return 0, err
+		}
+		if y == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		x, err := toInt64(a)
+		if err != nil {
+			return 0, err
+		}
+		return x % y, nil
+	},
+	"min": func(a, b any) (int64, error) { return mathBinary(a, b, minInt64) },
+	"max": func(a, b any) (int64, error) { return mathBinary(a, b, maxInt64) },
+}
+
+func mathBinary(a, b any, op func(x, y int64) int64) (int64, error) {
+	x, err := toInt64(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toInt64(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y), nil
+}
+
+func minInt64(x, y int64) int64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+func maxInt64(x, y int64) int64 {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// toInt64 coerces the numeric types a template value is realistically
+// holding - the Go int literal types, float32/64 (as parsed from a
+// vars.yaml number), and a numeric string - into an int64.
+func toInt64(v any) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint:
+		return int64(n), nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		return int64(n), nil
+	case float32:
+		return int64(n), nil
+	case float64:
+		return int64(n), nil
+	case string:
+		var out int64
+		if _, err := fmt.Sscanf(n, "%d", &out); err != nil {
+			return 0, fmt.Errorf("%q is not a number", n)
+		}
+		return out, nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", v)
+	}
+}
+
+// cryptoFuncs: encoding and hashing helpers, opt-in via
+// RegisterFuncsOptions.Crypto.
+var cryptoFuncs = template.FuncMap{
+	"b64enc":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"b64dec":     b64dec,
+	"urlquery":   func(s string) string { return url.QueryEscape(s) },
+	"sha1sum":    func(s string) string { sum := sha1.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+	"sha256sum":  func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+	"md5sum":     func(s string) string { sum := md5.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+	"hmacSha256": hmacSha256,
+}
+
+func b64dec(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid base64: %w", err)
+	}
+	return string(data), nil
+}
+
+// hmacSha256 returns the hex-encoded HMAC-SHA256 of message under key, as
+// {{hmacSha256 "key" "message"}}.
+func hmacSha256(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// regexFuncs is an alias namespace: regexMatch/regexReplace live in
+// stringFuncs (always on) since list/string helpers are commonly used
+// together, but RegisterFuncsOptions.Regex is kept as its own flag per the
+// ticket's requested namespace list - selecting it is a no-op beyond what
+// stringFuncs already registered, rather than a separate set of names.
+var regexFuncs = template.FuncMap{}
+
+// timeFuncs: date/time helpers, opt-in via RegisterFuncsOptions.Time.
+var timeFuncs = template.FuncMap{
+	"now":        time.Now,
+	"dateModify": dateModify,
+	"dateInZone": dateInZone,
+	"unixEpoch":  func(t time.Time) int64 { return t.Unix() },
+}
+
+// dateModify adds a Go duration string (e.g. "-1h", "24h") to t, as
+// {{now | dateModify "-1h"}}.
+func dateModify(duration string, t time.Time) (time.Time, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return t, fmt.Errorf("invalid duration %q: %w", duration, err)
+	}
+	return t.Add(d), nil
+}
+
+// dateInZone formats t in the named IANA zone using a Go reference-layout
+// string, as {{now | dateInZone "2006-01-02 15:04:05" "UTC"}}.
+func dateInZone(layout, zone string, t time.Time) (string, error) {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return "", fmt.Errorf("invalid time zone %q: %w", zone, err)
+	}
+	return t.In(loc).Format(layout), nil
+}
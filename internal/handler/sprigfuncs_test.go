@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+)
+
+func TestRegisterFuncsAlwaysAddsBaseHelpers(t *testing.T) {
+	// html/template.Template doesn't expose a way to list registered
+	// funcs, so exercise the base (always-on) helpers through an actual
+	// execution instead.
+	tmpl := RegisterFuncs(template.New("t"), RegisterFuncsOptions{})
+	out := execTemplate(t, tmpl, `{{upper "a"}}{{trim " b "}}{{regexMatch "^a" "abc"}}{{list 1 2}}{{first (list 1 2)}}{{add 1 2}}`)
+	if out == "" {
+		t.Fatalf("expected base helpers to be callable, got empty output")
+	}
+}
+
+func TestRegisterFuncsGatesCryptoTimeEnv(t *testing.T) {
+	cases := []struct {
+		name string
+		tmpl string
+		opts RegisterFuncsOptions
+	}{
+		{"crypto", `{{sha256sum "x"}}`, RegisterFuncsOptions{Crypto: true}},
+		{"time", `{{now}}`, RegisterFuncsOptions{Time: true}},
+		{"env", `{{env "PATH"}}`, RegisterFuncsOptions{Env: true}},
+	}
+	for _, c := range cases {
+		t.Run(c.name+"_enabled", func(t *testing.T) {
+			tmpl := RegisterFuncs(template.New("t"), c.opts)
+			if _, err := tmpl.Parse(c.tmpl); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if err := tmpl.Execute(&bytes.Buffer{}, nil); err != nil {
+				t.Errorf("Execute() error = %v, expected the namespace to be registered", err)
+			}
+		})
+		t.Run(c.name+"_disabled", func(t *testing.T) {
+			tmpl := RegisterFuncs(template.New("t"), RegisterFuncsOptions{})
+			if _, err := tmpl.Parse(c.tmpl); err == nil {
+				t.Errorf("Parse() succeeded, expected %q to be undefined without opting in", c.tmpl)
+			}
+		})
+	}
+}
+
+func TestAddSubMulDivMod(t *testing.T) {
+	tmpl := RegisterFuncs(template.New("t"), RegisterFuncsOptions{})
+	if got := execTemplate(t, tmpl, `{{add 2 3}}`); got != "5" {
+		t.Errorf("add 2 3 = %q, want 5", got)
+	}
+	if got := execTemplate(t, tmpl, `{{sub 5 3}}`); got != "2" {
+		t.Errorf("sub 5 3 = %q, want 2", got)
+	}
+	if got := execTemplate(t, tmpl, `{{mul 2 3}}`); got != "6" {
+		t.Errorf("mul 2 3 = %q, want 6", got)
+	}
+	if got := execTemplate(t, tmpl, `{{div 7 2}}`); got != "3" {
+		t.Errorf("div 7 2 = %q, want 3", got)
+	}
+	if got := execTemplate(t, tmpl, `{{mod 7 2}}`); got != "1" {
+		t.Errorf("mod 7 2 = %q, want 1", got)
+	}
+	if got := execTemplate(t, tmpl, `{{min 7 2}}`); got != "2" {
+		t.Errorf("min 7 2 = %q, want 2", got)
+	}
+	if got := execTemplate(t, tmpl, `{{max 7 2}}`); got != "7" {
+		t.Errorf("max 7 2 = %q, want 7", got)
+	}
+}
+
+func TestListHelpers(t *testing.T) {
+	tmpl := RegisterFuncs(template.New("t"), RegisterFuncsOptions{})
+	if got := execTemplate(t, tmpl, `{{first (list 1 2 3)}}`); got != "1" {
+		t.Errorf("first = %q, want 1", got)
+	}
+	if got := execTemplate(t, tmpl, `{{last (list 1 2 3)}}`); got != "3" {
+		t.Errorf("last = %q, want 3", got)
+	}
+	if got := execTemplate(t, tmpl, `{{slice (list 1 2 3 4) 1 3}}`); got != "[2 3]" {
+		t.Errorf("slice = %q, want [2 3]", got)
+	}
+	if got := execTemplate(t, tmpl, `{{uniq (list 1 1 2)}}`); got != "[1 2]" {
+		t.Errorf("uniq = %q, want [1 2]", got)
+	}
+	if got := execTemplate(t, tmpl, `{{reverse (list 1 2 3)}}`); got != "[3 2 1]" {
+		t.Errorf("reverse = %q, want [3 2 1]", got)
+	}
+	if got := execTemplate(t, tmpl, `{{sortAlpha (list "b" "a" "c")}}`); got != "[a b c]" {
+		t.Errorf("sortAlpha = %q, want [a b c]", got)
+	}
+}
+
+func TestTitleCaseCapitalizesEachWord(t *testing.T) {
+	if got := titleCase("jane doe"); got != "Jane Doe" {
+		t.Errorf("titleCase(\"jane doe\") = %q, want %q", got, "Jane Doe")
+	}
+}
+
+func TestHmacSha256KnownVector(t *testing.T) {
+	if got := hmacSha256("key", "message"); got != "6e9ef29b75fffc5b7abae527d58fdadb2fe42e7219011976917343065f58ed4a" {
+		t.Errorf("hmacSha256 = %q, want the known HMAC-SHA256 vector", got)
+	}
+}
+
+func execTemplate(t *testing.T, tmpl *template.Template, text string) string {
+	t.Helper()
+	// Clone rather than Parse tmpl directly - tmpl is shared across calls in
+	// the same test, and a *template.Template can't be Parsed again once
+	// it's been Executed.
+	clone, err := tmpl.Clone()
+	if err != nil {
+		t.Fatalf("Clone error = %v", err)
+	}
+	parsed, err := clone.Parse(text)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", text, err)
+	}
+	var buf bytes.Buffer
+	if err := parsed.Execute(&buf, nil); err != nil {
+		t.Fatalf("Execute(%q) error = %v", text, err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"sync"
+	"time"
+)
+
+// sriKey caches a Subresource Integrity digest against the file's mtime, not
+// just its path, so an edit during development (which bumps mtime)
+// invalidates the cached hash instead of serving a stale one.
+type sriKey struct {
+	path    string
+	modTime time.Time
+}
+
+var sriCache sync.Map // sriKey -> string (sha384-<base64> integrity value)
+
+// fileIntegrity returns the "sha384-<base64>" Subresource Integrity value
+// for the file at absPath, computing it at most once per (path, mtime) pair.
+func fileIntegrity(absPath string) (string, error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", err
+	}
+	key := sriKey{path: absPath, modTime: info.ModTime()}
+	if cached, ok := sriCache.Load(key); ok {
+		return cached.(string), nil
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha512.Sum384(data)
+	integrity := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	sriCache.Store(key, integrity)
+	return integrity, nil
+}
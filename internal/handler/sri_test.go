@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileIntegrityMatchesKnownDigest(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "styles.css")
+	if err := os.WriteFile(file, []byte("body{color:red}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	integrity, err := fileIntegrity(file)
+	if err != nil {
+		t.Fatalf("fileIntegrity() error = %v", err)
+	}
+	// echo -n 'body{color:red}' | openssl dgst -sha384 -binary | base64
+	want := "sha384-8U9HYzsHbf55cFZyiWIE29+QPYQ9WO+U5uT/ViFw0TOwM2Fbbb74ZegzRV/nvwrD"
+	if integrity != want {
+		t.Errorf("fileIntegrity() = %q, want %q", integrity, want)
+	}
+}
+
+func TestFileIntegrityInvalidatesOnEdit(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "styles.css")
+	if err := os.WriteFile(file, []byte("body{color:red}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	first, err := fileIntegrity(file)
+	if err != nil {
+		t.Fatalf("fileIntegrity() error = %v", err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.WriteFile(file, []byte("body{color:blue}"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite file: %v", err)
+	}
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("Failed to bump mtime: %v", err)
+	}
+
+	second, err := fileIntegrity(file)
+	if err != nil {
+		t.Fatalf("fileIntegrity() second call error = %v", err)
+	}
+	if second == first {
+		t.Error("expected integrity hash to change after editing the file and bumping its mtime")
+	}
+}
+
+func TestProcessAssetPathsEmitsSRIForResolvedAsset(t *testing.T) {
+	baseDir := t.TempDir()
+	templateDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "logo.png"), []byte("logo"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	ConfigureEmitSRI(baseDir, true)
+	defer ConfigureEmitSRI(baseDir, false)
+
+	content := `<img src="logo.png">`
+	got := processAssetPaths(content, "campaign-a/page.html", baseDir)
+
+	if !strings.Contains(got, `integrity="sha384-`) {
+		t.Errorf("expected an integrity attribute, got %q", got)
+	}
+	if !strings.Contains(got, `crossorigin="anonymous"`) {
+		t.Errorf("expected a crossorigin attribute, got %q", got)
+	}
+}
+
+func TestProcessAssetPathsNoSRIWhenDisabled(t *testing.T) {
+	baseDir := t.TempDir()
+	templateDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "logo.png"), []byte("logo"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	content := `<img src="logo.png">`
+	got := processAssetPaths(content, "campaign-a/page.html", baseDir)
+
+	if strings.Contains(got, "integrity=") {
+		t.Errorf("expected no integrity attribute with EmitSRI off, got %q", got)
+	}
+}
+
+func TestProcessAssetPathsNoSRIWhenAssetNotFound(t *testing.T) {
+	baseDir := t.TempDir()
+	ConfigureEmitSRI(baseDir, true)
+	defer ConfigureEmitSRI(baseDir, false)
+
+	content := `<img src="missing.png">`
+	got := processAssetPaths(content, "campaign-a/page.html", baseDir)
+
+	if strings.Contains(got, "integrity=") {
+		t.Errorf("expected no integrity attribute for an unresolved asset, got %q", got)
+	}
+}
+
+func TestProcessAssetPathsSkipsNonStylesheetLink(t *testing.T) {
+	baseDir := t.TempDir()
+	templateDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "favicon.ico"), []byte("icon"), 0644); err != nil {
+		t.Fatalf("Failed to write favicon.ico: %v", err)
+	}
+
+	ConfigureEmitSRI(baseDir, true)
+	defer ConfigureEmitSRI(baseDir, false)
+
+	content := `<link rel="icon" href="favicon.ico">`
+	got := processAssetPaths(content, "campaign-a/page.html", baseDir)
+
+	if strings.Contains(got, "integrity=") {
+		t.Errorf("expected no integrity attribute on a non-stylesheet link, got %q", got)
+	}
+}
+
+func TestProcessAssetPathsDoesNotDuplicateExistingIntegrity(t *testing.T) {
+	baseDir := t.TempDir()
+	templateDir := filepath.Join(baseDir, "campaign-a")
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "logo.png"), []byte("logo"), 0644); err != nil {
+		t.Fatalf("Failed to write logo.png: %v", err)
+	}
+
+	ConfigureEmitSRI(baseDir, true)
+	defer ConfigureEmitSRI(baseDir, false)
+
+	content := `<img src="logo.png" integrity="sha384-already-present">`
+	got := processAssetPaths(content, "campaign-a/page.html", baseDir)
+
+	if strings.Count(got, "integrity=") != 1 {
+		t.Errorf("expected the existing integrity attribute to be left alone, got %q", got)
+	}
+}
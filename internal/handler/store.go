@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Store is the read-only filesystem surface handlers use to reach template
+// content. Callers work against it with the standard io/fs helpers
+// (fs.ReadFile, fs.ReadDir, fs.WalkDir, fs.Stat) instead of os/filepath,
+// so a live directory and a `.zip` archive can be served interchangeably.
+type Store interface {
+	fs.FS
+}
+
+// OpenStore returns a Store backed by path. If path ends in ".zip" it is
+// opened as a zip archive; otherwise it is treated as a directory.
+func OpenStore(path string) (Store, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip store %q: %w", path, err)
+		}
+		return zr, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat store path %q: %w", path, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("store path %q is not a directory or .zip archive", path)
+	}
+
+	return os.DirFS(path), nil
+}
+
+// storeContainsHTML reports whether dirPath (relative to the store root)
+// directly contains any *.html files.
+func storeContainsHTML(store Store, dirPath string) (bool, error) {
+	entries, err := fs.ReadDir(store, dirPath)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".html") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
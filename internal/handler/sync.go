@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// syncManifestFile is the top-level manifest, analogous to a vendir config,
+// that SyncTemplates reads before validateCampaigns/addPhishingTemplates
+// run. A missing file means there's nothing to sync.
+const syncManifestFile = "templates.yaml"
+
+// SyncSourceType selects how a SyncSource's content is fetched.
+type SyncSourceType string
+
+const (
+	SyncSourceZip SyncSourceType = "zip" // an HTTPS-hosted zip archive
+	SyncSourceGit SyncSourceType = "git"
+	SyncSourceOCI SyncSourceType = "oci"
+)
+
+// SyncSource is one remote tree templates.yaml asks SyncTemplates to merge
+// into the templates root before validation/export. Dest is a subdirectory
+// under the root; a Dest under "private/" (or any other .templateignore
+// pattern) is synced like any other source but then skipped by
+// validateCampaigns/addPhishingTemplates exactly as a manually-placed
+// private folder would be.
+//
+// Credentials are never part of the manifest: they're resolved at runtime
+// from TEMPLATES_SYNC_USERNAME_<ID>/TEMPLATES_SYNC_PASSWORD_<ID>/
+// TEMPLATES_SYNC_TOKEN_<ID> by ID, so templates.yaml can be committed
+// alongside the templates it describes.
+type SyncSource struct {
+	ID     string         `yaml:"id"`
+	Type   SyncSourceType `yaml:"type"`
+	URL    string         `yaml:"url"`
+	Ref    string         `yaml:"ref,omitempty"`
+	Digest string         `yaml:"digest,omitempty"`
+	Dest   string         `yaml:"dest"`
+
+	// IncludePaths, when set, limits which archive entries are written:
+	// only entries matching at least one gitignore-style glob are kept,
+	// using the same matcher ExportFilter uses for include/exclude.
+	IncludePaths []string `yaml:"includePaths,omitempty"`
+}
+
+// SyncManifest is the parsed shape of templates.yaml.
+type SyncManifest struct {
+	Sources []SyncSource `yaml:"sources"`
+}
+
+// LoadSyncManifest reads and parses root's templates.yaml. A missing file
+// is not an error: it just means there's nothing to sync.
+func LoadSyncManifest(root string) (*SyncManifest, error) {
+	data, err := os.ReadFile(filepath.Join(root, syncManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &SyncManifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest SyncManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", syncManifestFile, err)
+	}
+	return &manifest, nil
+}
+
+// envIDRe matches the characters syncEnvID replaces with "_" so a source ID
+// like "client-a.example" becomes a valid environment variable suffix.
+var envIDRe = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// syncEnvID upper-cases id and replaces every run of non-alphanumeric
+// characters with "_", e.g. "client-a" -> "CLIENT_A".
+func syncEnvID(id string) string {
+	return envIDRe.ReplaceAllString(strings.ToUpper(id), "_")
+}
+
+// SyncCredentials holds whichever of a source's credentials were found in
+// the environment. At most one of (Username+Password) or Token is normally
+// set; syncZip prefers Token (as a bearer token) when present.
+type SyncCredentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// credentialsFor resolves id's credentials from
+// TEMPLATES_SYNC_USERNAME_<ID>, TEMPLATES_SYNC_PASSWORD_<ID> and
+// TEMPLATES_SYNC_TOKEN_<ID>, so no secret needs to live in templates.yaml.
+func credentialsFor(id string) SyncCredentials {
+	suffix := syncEnvID(id)
+	return SyncCredentials{
+		Username: os.Getenv("TEMPLATES_SYNC_USERNAME_" + suffix),
+		Password: os.Getenv("TEMPLATES_SYNC_PASSWORD_" + suffix),
+		Token:    os.Getenv("TEMPLATES_SYNC_TOKEN_" + suffix),
+	}
+}
+
+// SyncTemplates fetches every source in manifest into its Dest under root.
+// Call it before validateCampaigns/addPhishingTemplates so they see the
+// merged tree. client is the HTTP client used for "zip" sources; pass nil
+// to use http.DefaultClient.
+func SyncTemplates(root string, manifest *SyncManifest, client *http.Client) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for _, src := range manifest.Sources {
+		if err := syncOne(root, src, client); err != nil {
+			return fmt.Errorf("sync %q: %w", src.ID, err)
+		}
+	}
+	return nil
+}
+
+func syncOne(root string, src SyncSource, client *http.Client) error {
+	if src.Dest == "" {
+		return fmt.Errorf("missing dest")
+	}
+
+	switch src.Type {
+	case SyncSourceZip:
+		return syncZip(root, src, client)
+	case SyncSourceGit, SyncSourceOCI:
+		return fmt.Errorf("source type %q is not yet implemented", src.Type)
+	default:
+		return fmt.Errorf("unknown source type %q", src.Type)
+	}
+}
+
+// syncZip downloads src.URL as a zip archive, verifies it against
+// src.Digest when set, and extracts it under root/src.Dest - filtered by
+// src.IncludePaths, if any - using the same zip-slip-safe os.Root
+// extraction extractArchive uses for uploaded imports. src.URL must be
+// https: credentialsFor's resolved bearer token or basic-auth credentials
+// would otherwise go out over the wire in the clear.
+func syncZip(root string, src SyncSource, client *http.Client) error {
+	parsed, err := url.Parse(src.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %w", src.URL, err)
+	}
+	if parsed.Scheme != "https" {
+		return fmt.Errorf("refusing to fetch %q: source url must be https, not %q", src.URL, parsed.Scheme)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, src.URL, nil)
+	if err != nil {
+		return err
+	}
+	creds := credentialsFor(src.ID)
+	switch {
+	case creds.Token != "":
+		req.Header.Set("Authorization", "Bearer "+creds.Token)
+	case creds.Username != "" || creds.Password != "":
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", src.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: status %s", src.URL, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src.URL, err)
+	}
+
+	if src.Digest != "" {
+		sum := sha256.Sum256(body)
+		got := hex.EncodeToString(sum[:])
+		want := strings.TrimPrefix(src.Digest, "sha256:")
+		if !strings.EqualFold(got, want) {
+			return fmt.Errorf("digest mismatch for %s: want %s, got %s", src.URL, want, got)
+		}
+	}
+
+	destDir := filepath.Join(root, filepath.FromSlash(src.Dest))
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	var includes []matchPattern
+	for _, raw := range src.IncludePaths {
+		p, err := newMatchPattern(raw)
+		if err != nil {
+			return fmt.Errorf("invalid includePaths pattern %q: %w", raw, err)
+		}
+		includes = append(includes, p)
+	}
+
+	return extractFilteredZip(destDir, body, includes)
+}
+
+// extractFilteredZip is extractArchive's zip path, with an extra step: an
+// entry is only written if it matches at least one include pattern, or
+// includes is empty. It reuses the same safeEntryName/rootMkdirAll helpers
+// extractArchive uses, so synced content gets the same zip-slip and
+// symlink protection as an uploaded import. Like extractArchive, this needs
+// Go 1.24 (os.OpenRoot) - there is no older-toolchain fallback.
+func extractFilteredZip(destDir string, body []byte, includes []matchPattern) error {
+	if len(includes) == 0 {
+		return extractArchive(FormatZip, body, destDir)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return fmt.Errorf("not a valid zip archive: %w", err)
+	}
+
+	root, err := os.OpenRoot(destDir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	for _, f := range zr.File {
+		name, err := safeEntryName(f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		allowed := false
+		for _, p := range includes {
+			if p.match(name, false) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			continue
+		}
+
+		if err := rootMkdirAll(root, path.Dir(name)); err != nil {
+			return err
+		}
+		if err := extractZipFile(root, f, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
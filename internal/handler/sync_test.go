@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildTestZip returns the bytes of a zip archive containing files at the
+// given paths, each with the same content.
+func buildTestZip(t *testing.T, paths ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, p := range paths {
+		w, err := zw.Create(p)
+		if err != nil {
+			t.Fatalf("Failed to add %q to test zip: %v", p, err)
+		}
+		if _, err := w.Write([]byte("<html>content</html>")); err != nil {
+			t.Fatalf("Failed to write %q: %v", p, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close test zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCredentialsForReadsEnvByID(t *testing.T) {
+	t.Setenv("TEMPLATES_SYNC_USERNAME_CLIENT_A", "alice")
+	t.Setenv("TEMPLATES_SYNC_PASSWORD_CLIENT_A", "hunter2")
+	t.Setenv("TEMPLATES_SYNC_TOKEN_CLIENT_A", "")
+
+	creds := credentialsFor("client-a")
+	if creds.Username != "alice" || creds.Password != "hunter2" {
+		t.Errorf("expected username/password resolved from env, got %+v", creds)
+	}
+	if creds.Token != "" {
+		t.Errorf("expected no token set, got %q", creds.Token)
+	}
+}
+
+func TestSyncZipUsesBearerTokenFromEnv(t *testing.T) {
+	zipData := buildTestZip(t, "vendor-campaign/email.html")
+
+	var gotAuth string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Write(zipData)
+	}))
+	defer srv.Close()
+
+	t.Setenv("TEMPLATES_SYNC_TOKEN_UPSTREAM", "s3cr3t-token")
+
+	root := t.TempDir()
+	src := SyncSource{ID: "upstream", Type: SyncSourceZip, URL: srv.URL, Dest: "synced"}
+	if err := syncZip(root, src, srv.Client()); err != nil {
+		t.Fatalf("syncZip failed: %v", err)
+	}
+
+	if gotAuth != "Bearer s3cr3t-token" {
+		t.Errorf("expected request to carry the env-resolved bearer token, got %q", gotAuth)
+	}
+	if _, err := os.Stat(filepath.Join(root, "synced", "vendor-campaign", "email.html")); err != nil {
+		t.Errorf("expected synced file to be written: %v", err)
+	}
+}
+
+func TestSyncZipRejectsNonHTTPSSource(t *testing.T) {
+	zipData := buildTestZip(t, "vendor-campaign/email.html")
+
+	var called bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.Write(zipData)
+	}))
+	defer srv.Close()
+
+	t.Setenv("TEMPLATES_SYNC_TOKEN_UPSTREAM", "s3cr3t-token")
+
+	root := t.TempDir()
+	src := SyncSource{ID: "upstream", Type: SyncSourceZip, URL: srv.URL, Dest: "synced"}
+	err := syncZip(root, src, srv.Client())
+	if err == nil {
+		t.Fatal("expected a plain-http source url to be rejected")
+	}
+	if called {
+		t.Error("expected syncZip to reject the url before ever sending the request (and its bearer token)")
+	}
+}
+
+func TestSyncZipDigestMismatchFailsHard(t *testing.T) {
+	zipData := buildTestZip(t, "vendor-campaign/email.html")
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipData)
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	src := SyncSource{
+		ID:     "upstream",
+		Type:   SyncSourceZip,
+		URL:    srv.URL,
+		Dest:   "synced",
+		Digest: "sha256:0000000000000000000000000000000000000000000000000000000000000",
+	}
+
+	err := syncZip(root, src, srv.Client())
+	if err == nil {
+		t.Fatal("expected a digest mismatch to fail")
+	}
+}
+
+func TestSyncZipDigestMatchSucceeds(t *testing.T) {
+	zipData := buildTestZip(t, "vendor-campaign/email.html")
+	sum := sha256.Sum256(zipData)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipData)
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	src := SyncSource{ID: "upstream", Type: SyncSourceZip, URL: srv.URL, Dest: "synced", Digest: digest}
+	if err := syncZip(root, src, srv.Client()); err != nil {
+		t.Fatalf("expected a matching digest to succeed, got: %v", err)
+	}
+}
+
+func TestSyncZipIncludePathsFiltersEntries(t *testing.T) {
+	zipData := buildTestZip(t, "kept/email.html", "dropped/email.html")
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipData)
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	src := SyncSource{
+		ID:           "upstream",
+		Type:         SyncSourceZip,
+		URL:          srv.URL,
+		Dest:         "synced",
+		IncludePaths: []string{"kept/*"},
+	}
+	if err := syncZip(root, src, srv.Client()); err != nil {
+		t.Fatalf("syncZip failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "synced", "kept", "email.html")); err != nil {
+		t.Errorf("expected kept/email.html to be written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "synced", "dropped", "email.html")); !os.IsNotExist(err) {
+		t.Errorf("expected dropped/email.html to be filtered out, stat err: %v", err)
+	}
+}
+
+func TestSyncedPrivateDestIsSkippedByValidation(t *testing.T) {
+	zipData := buildTestZip(t, "vendor-campaign/data.yaml", "vendor-campaign/email.html")
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(zipData)
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	src := SyncSource{ID: "upstream", Type: SyncSourceZip, URL: srv.URL, Dest: "private/vendor"}
+	if err := syncZip(root, src, srv.Client()); err != nil {
+		t.Fatalf("syncZip failed: %v", err)
+	}
+
+	// A campaign with the same data.yaml name already exists outside the
+	// synced private/ prefix; if validation didn't skip private/, this
+	// would fail with a DuplicateError.
+	publicDir := filepath.Join(root, "generic-service")
+	if err := os.MkdirAll(publicDir, 0755); err != nil {
+		t.Fatalf("Failed to create public dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "data.yaml"), []byte("name: \"vendor-campaign\""), 0644); err != nil {
+		t.Fatalf("Failed to write public data.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicDir, "email.html"), []byte("<html>public</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write public email.html: %v", err)
+	}
+
+	if err := ValidateCampaignsFS(os.DirFS(root)); err != nil {
+		t.Errorf("expected the synced private/ campaign to be skipped by validation, got: %v", err)
+	}
+}
@@ -65,8 +65,10 @@ var TemplateFuncs = template.FuncMap{
 		// #nosec
 		return rand.Intn(n2-n1+1) + n1, nil
 	},
-	"randAlpha": RandAlpha,
-	"qr":        GenerateQRCode,
+	"randAlpha": func(length int) (string, error) {
+		return RandAlpha(length, DefaultLimits.MaxRandAlphaLength)
+	},
+	"qr": GenerateQRCode,
 	"date": func(format string, offsetSeconds ...int) string {
 		offset := 0
 		if len(offsetSeconds) > 0 {
@@ -113,10 +115,12 @@ func GenerateQRCode(args ...any) (template.HTML, error) {
 	return template.HTML(buf.String()), nil
 }
 
-// RandAlpha returns a random string of the given length
-func RandAlpha(length int) (string, error) {
-	if length > 32 {
-		return "", fmt.Errorf("length must be less than 32")
+// RandAlpha returns a random string of the given length, capped at maxLength
+// (see Limits.MaxRandAlphaLength) so a template can't use it to force a
+// render to produce an unreasonable amount of output.
+func RandAlpha(length, maxLength int) (string, error) {
+	if length > maxLength {
+		return "", fmt.Errorf("length must be at most %d", maxLength)
 	}
 	b := make([]byte, length)
 	for i := range b {
@@ -126,6 +130,18 @@ func RandAlpha(length int) (string, error) {
 	return string(b), nil
 }
 
+// limitFuncs returns the subset of TemplateFuncs whose behavior depends on
+// Limits - currently just randAlpha's output cap. processTemplateContentStat
+// merges it in after TemplateFuncs so a render can enforce its own limits
+// without changing the "randAlpha" entry every other render still uses.
+func limitFuncs(limits Limits) template.FuncMap {
+	return template.FuncMap{
+		"randAlpha": func(length int) (string, error) {
+			return RandAlpha(length, limits.MaxRandAlphaLength)
+		},
+	}
+}
+
 // QRHTMLWriter generates QR codes as HTML tables
 type QRHTMLWriter struct {
 	w       io.Writer
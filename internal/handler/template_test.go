@@ -0,0 +1,32 @@
+package handler
+
+import "testing"
+
+func TestRandAlphaRespectsMaxLength(t *testing.T) {
+	s, err := RandAlpha(8, 32)
+	if err != nil {
+		t.Fatalf("RandAlpha failed: %v", err)
+	}
+	if len(s) != 8 {
+		t.Errorf("expected an 8-char string, got %q", s)
+	}
+
+	if _, err := RandAlpha(33, 32); err == nil {
+		t.Error("expected an error for a length over maxLength")
+	}
+}
+
+func TestLimitFuncsOverridesRandAlphaLimit(t *testing.T) {
+	funcs := limitFuncs(Limits{MaxRandAlphaLength: 4})
+	randAlpha, ok := funcs["randAlpha"].(func(int) (string, error))
+	if !ok {
+		t.Fatalf("randAlpha func has an unexpected type: %T", funcs["randAlpha"])
+	}
+
+	if _, err := randAlpha(5); err == nil {
+		t.Error("expected an error once length exceeds the tightened limit")
+	}
+	if s, err := randAlpha(4); err != nil || len(s) != 4 {
+		t.Errorf("randAlpha(4) = %q, %v; want a 4-char string and no error", s, err)
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// TemplateFS composes an ordered stack of fs.FS layers and resolves a
+// lookup against the first layer that has it, trying the exact same
+// cleaned path in every layer rather than guessing which layer a path
+// belongs to. Layers are typically, from most to least specific: a
+// campaign's own folder, a shared brand-assets tree, and an embedded
+// default kit.
+type TemplateFS struct {
+	layers []fs.FS
+}
+
+// NewTemplateFS returns a TemplateFS that tries layers in order, first hit
+// wins. An empty TemplateFS reports fs.ErrNotExist for everything.
+func NewTemplateFS(layers ...fs.FS) *TemplateFS {
+	return &TemplateFS{layers: layers}
+}
+
+// DefaultAssetFS is the two-layer stack AssetHandler used before
+// TemplateFS existed: fsys's own tree first, then the same request path
+// re-probed as an asset suffix (see assetSuffixFS) against fsys's assets/
+// folder - a campaign-local file taking precedence over a shared brand
+// asset of the same name.
+func DefaultAssetFS(fsys fs.FS) *TemplateFS {
+	return NewTemplateFS(fsys, newAssetSuffixFS(fsys))
+}
+
+// Open implements fs.FS.
+func (t *TemplateFS) Open(name string) (fs.File, error) {
+	err := fs.ErrNotExist
+	for _, layer := range t.layers {
+		f, layerErr := layer.Open(name)
+		if layerErr == nil {
+			return f, nil
+		}
+		err = layerErr
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+}
+
+// Stat implements fs.StatFS, so fs.Stat(t, name) walks the same layers
+// Open does without having to open (and then discard) a file handle just
+// to check whether one exists.
+func (t *TemplateFS) Stat(name string) (fs.FileInfo, error) {
+	err := fs.ErrNotExist
+	for _, layer := range t.layers {
+		info, layerErr := fs.Stat(layer, name)
+		if layerErr == nil {
+			return info, nil
+		}
+		err = layerErr
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+}
+
+// assetSuffixFS adapts AssetHandler's historical "last 1-2 path segments"
+// fallback into an fs.FS: a lookup for foo/bar/baz/logo.png is retried as
+// assets/baz/logo.png, then assets/logo.png, against fsys. Wrapping this as
+// its own fs.FS - rather than AssetHandler special-casing it - is what lets
+// TemplateFS try the identical full path across every layer.
+type assetSuffixFS struct {
+	fsys fs.FS
+}
+
+func newAssetSuffixFS(fsys fs.FS) assetSuffixFS {
+	return assetSuffixFS{fsys: fsys}
+}
+
+// candidates returns assetSuffixFS's fallback paths for name, most specific
+// first: assets/<dir>/<file>, then assets/<file>.
+func (a assetSuffixFS) candidates(name string) []string {
+	parts := strings.Split(name, "/")
+	var candidates []string
+	if len(parts) >= 2 {
+		candidates = append(candidates, path.Join("assets", strings.Join(parts[len(parts)-2:], "/")))
+	}
+	if len(parts) >= 1 {
+		candidates = append(candidates, path.Join("assets", parts[len(parts)-1]))
+	}
+	return candidates
+}
+
+func (a assetSuffixFS) Open(name string) (fs.File, error) {
+	for _, c := range a.candidates(name) {
+		if f, err := a.fsys.Open(c); err == nil {
+			return f, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (a assetSuffixFS) Stat(name string) (fs.FileInfo, error) {
+	for _, c := range a.candidates(name) {
+		if info, err := fs.Stat(a.fsys, c); err == nil {
+			return info, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplateFSFirstLayerWins(t *testing.T) {
+	override := fstest.MapFS{
+		"logo.png": {Data: []byte("override")},
+	}
+	base := fstest.MapFS{
+		"logo.png": {Data: []byte("base")},
+	}
+
+	tfs := NewTemplateFS(override, base)
+	data, err := fs.ReadFile(tfs, "logo.png")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "override" {
+		t.Errorf("expected the first layer to win, got %q", data)
+	}
+}
+
+func TestTemplateFSFallsThroughToLaterLayers(t *testing.T) {
+	campaign := fstest.MapFS{
+		"other.png": {Data: []byte("unrelated")},
+	}
+	brand := fstest.MapFS{
+		"logo.png": {Data: []byte("brand")},
+	}
+	embedded := fstest.MapFS{
+		"logo.png": {Data: []byte("embedded-default")},
+	}
+
+	tfs := NewTemplateFS(campaign, brand, embedded)
+	data, err := fs.ReadFile(tfs, "logo.png")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "brand" {
+		t.Errorf("expected the first layer that has the file to win, got %q", data)
+	}
+}
+
+func TestTemplateFSMissEverywhereReportsNotExist(t *testing.T) {
+	tfs := NewTemplateFS(fstest.MapFS{}, fstest.MapFS{})
+	_, err := tfs.Open("missing.png")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a not-exist error, got %v", err)
+	}
+}
+
+func TestDefaultAssetFSMatchesHistoricalFallback(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Microsoft/Emails/Template/microsoft/unused.txt": {Data: []byte("x")},
+		"assets/microsoft/microsoft-logo.png":            {Data: []byte("fallback")},
+	}
+
+	tfs := DefaultAssetFS(fsys)
+	data, err := fs.ReadFile(tfs, "Microsoft/Emails/Template/microsoft/microsoft-logo.png")
+	if err != nil {
+		t.Fatalf("expected the asset-suffix layer to resolve the shared asset: %v", err)
+	}
+	if string(data) != "fallback" {
+		t.Errorf("expected fallback content, got %q", data)
+	}
+}
+
+func TestDefaultAssetFSPrimaryLayerTakesPrecedence(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Microsoft/Emails/Template/logo.png": {Data: []byte("primary")},
+		"assets/logo.png":                    {Data: []byte("shared")},
+	}
+
+	tfs := DefaultAssetFS(fsys)
+	data, err := fs.ReadFile(tfs, "Microsoft/Emails/Template/logo.png")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != "primary" {
+		t.Errorf("expected the campaign-local file to win over the shared asset, got %q", data)
+	}
+}
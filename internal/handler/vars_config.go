@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// wrapVarKey converts a bare variable name (e.g. "FirstName", as used in
+// vars.yaml, <template>.vars.yaml and the /api/vars JSON payloads) into the
+// "{{.FirstName}}" placeholder key templateVars and processTemplateContent
+// use internally.
+func wrapVarKey(name string) string {
+	return "{{." + name + "}}"
+}
+
+// unwrapVarKey extracts the bare variable name from a "{{.Name}}" placeholder
+// key. It returns false if placeholder isn't in that form.
+func unwrapVarKey(placeholder string) (string, bool) {
+	if strings.HasPrefix(placeholder, "{{.") && strings.HasSuffix(placeholder, "}}") {
+		return strings.TrimSuffix(strings.TrimPrefix(placeholder, "{{."), "}}"), true
+	}
+	return "", false
+}
+
+// mergeBareVars layers bare-keyed overrides (e.g. {"FirstName": "John"}) on
+// top of base, which is already in "{{.Name}}" placeholder form. The result
+// is a new map; base is left untouched.
+func mergeBareVars(base map[string]string, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for name, v := range overrides {
+		merged[wrapVarKey(name)] = v
+	}
+	return merged
+}
+
+// loadBareVarsFile parses a bare-keyed vars file (YAML or JSON, selected by
+// extension) into a map[string]string.
+func loadBareVarsFile(filePath string) (map[string]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var bare map[string]string
+	if strings.HasSuffix(strings.ToLower(filePath), ".json") {
+		err = json.Unmarshal(data, &bare)
+	} else {
+		err = yaml.Unmarshal(data, &bare)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(filePath), err)
+	}
+	return bare, nil
+}
+
+// loadBaseTemplateVars loads the effective global template variables for
+// baseDir: the hardcoded templateVars defaults, overridden by a vars.yaml,
+// vars.yml or vars.json file sibling to baseDir, if one exists. Engagements
+// that need different defaults (locale, sender names, tracking URLs) can
+// drop one of those files next to the templates directory instead of
+// editing this package.
+func loadBaseTemplateVars(baseDir string) (map[string]string, error) {
+	dir := filepath.Dir(baseDir)
+
+	for _, name := range []string{"vars.yaml", "vars.yml", "vars.json"} {
+		bare, err := loadBareVarsFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return mergeBareVars(templateVars, bare), nil
+	}
+
+	return templateVars, nil
+}
+
+// loadTemplateOverrideFS loads the bare-keyed per-template override for
+// fsPath from a sibling "<template>.vars.yaml" file, if one exists. It
+// returns a nil map (no error) when there is no override file.
+func loadTemplateOverrideFS(fsys fs.FS, fsPath string) (map[string]string, error) {
+	overridePath := strings.TrimSuffix(fsPath, path.Ext(fsPath)) + ".vars.yaml"
+
+	data, err := fs.ReadFile(fsys, overridePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var bare map[string]string
+	if err := yaml.Unmarshal(data, &bare); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path.Base(overridePath), err)
+	}
+	return bare, nil
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+)
+
+// templateVarsCookieName stores a user's bare-keyed template variable
+// overrides directly in the cookie value (base64-encoded JSON), so preview
+// sessions don't need any server-side session store.
+const templateVarsCookieName = "templateVarsOverride"
+
+// encodeSessionVars serializes bare-keyed overrides into a cookie-safe
+// string.
+func encodeSessionVars(overrides map[string]string) (string, error) {
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// decodeSessionVars reverses encodeSessionVars.
+func decodeSessionVars(value string) (map[string]string, error) {
+	data, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var overrides map[string]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// sessionTemplateVars reads and decodes the caller's templateVarsCookieName
+// cookie, if any. It returns a nil map when no (valid) cookie is present so
+// callers can merge it unconditionally.
+func sessionTemplateVars(r *http.Request) map[string]string {
+	cookie, err := r.Cookie(templateVarsCookieName)
+	if err != nil {
+		return nil
+	}
+	overrides, err := decodeSessionVars(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	return overrides
+}
+
+// effectiveTemplateVars returns the fully merged "{{.Name}}"-keyed template
+// variables for baseDir and r: the defaults (or a sibling vars.yaml/json
+// override of them), with the caller's session cookie overrides, if any,
+// layered on top.
+func effectiveTemplateVars(baseDir string, r *http.Request) (map[string]string, error) {
+	base, err := loadBaseTemplateVars(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if session := sessionTemplateVars(r); session != nil {
+		base = mergeBareVars(base, session)
+	}
+	return base, nil
+}
+
+// effectiveTemplateVarsForFS is effectiveTemplateVars plus a per-template
+// override: if a sibling "<template>.vars.yaml" (fsPath with its extension
+// replaced by ".vars.yaml") exists in fsys it is merged
+// over the globals, before the caller's session cookie overrides (which
+// take precedence over everything, since they're the user's explicit
+// choice for this preview).
+func effectiveTemplateVarsForFS(fsys fs.FS, fsPath, baseDir string, r *http.Request) (map[string]string, error) {
+	vars, err := loadBaseTemplateVars(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	override, err := loadTemplateOverrideFS(fsys, fsPath)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		vars = mergeBareVars(vars, override)
+	}
+
+	if session := sessionTemplateVars(r); session != nil {
+		vars = mergeBareVars(vars, session)
+	}
+
+	return vars, nil
+}
+
+// VarsHandler exposes the effective template variable set for the preview
+// UI's "edit variables" panel. GET returns the effective set (defaults,
+// vars.yaml overrides and the caller's session overrides, if any) as a bare
+// -keyed JSON object. POST accepts a bare-keyed JSON object of overrides,
+// stores it in the caller's session cookie and returns the resulting
+// effective set, so a user can preview with their own values without
+// editing any files on disk.
+func VarsHandler(baseDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			vars, err := effectiveTemplateVars(baseDir, r)
+			if err != nil {
+				http.Error(w, "Failed to load variables: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSONVars(w, vars)
+
+		case http.MethodPost:
+			var overrides map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil {
+				http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+				return
+			}
+
+			encoded, err := encodeSessionVars(overrides)
+			if err != nil {
+				http.Error(w, "Failed to encode overrides: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:  templateVarsCookieName,
+				Value: encoded,
+				Path:  "/",
+			})
+
+			base, err := loadBaseTemplateVars(baseDir)
+			if err != nil {
+				http.Error(w, "Failed to load variables: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSONVars(w, mergeBareVars(base, overrides))
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// writeJSONVars writes a "{{.Name}}"-keyed vars map back out as bare-keyed
+// JSON, the shape /api/vars consumers POST and expect back.
+func writeJSONVars(w http.ResponseWriter, vars map[string]string) {
+	bare := make(map[string]string, len(vars))
+	for placeholder, value := range vars {
+		if name, ok := unwrapVarKey(placeholder); ok {
+			bare[name] = value
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(bare)
+}
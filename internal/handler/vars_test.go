@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadBaseTemplateVarsDefaults(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "vars-defaults-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	baseDir := filepath.Join(tmpDir, "phishing-templates")
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		t.Fatalf("Failed to create base dir: %v", err)
+	}
+
+	vars, err := loadBaseTemplateVars(baseDir)
+	if err != nil {
+		t.Fatalf("loadBaseTemplateVars() error = %v", err)
+	}
+	if vars["{{.FirstName}}"] != "John" {
+		t.Errorf("expected default FirstName %q, got %q", "John", vars["{{.FirstName}}"])
+	}
+}
+
+func TestLoadBaseTemplateVarsSiblingOverride(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "vars-override-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	baseDir := filepath.Join(tmpDir, "phishing-templates")
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		t.Fatalf("Failed to create base dir: %v", err)
+	}
+
+	varsYAML := "FirstName: Alice\nCity: Berlin\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "vars.yaml"), []byte(varsYAML), 0644); err != nil {
+		t.Fatalf("Failed to write vars.yaml: %v", err)
+	}
+
+	vars, err := loadBaseTemplateVars(baseDir)
+	if err != nil {
+		t.Fatalf("loadBaseTemplateVars() error = %v", err)
+	}
+	if vars["{{.FirstName}}"] != "Alice" {
+		t.Errorf("expected overridden FirstName %q, got %q", "Alice", vars["{{.FirstName}}"])
+	}
+	if vars["{{.LastName}}"] != "Doe" {
+		t.Errorf("expected untouched default LastName %q, got %q", "Doe", vars["{{.LastName}}"])
+	}
+}
+
+func TestLoadTemplateOverrideFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"campaign/email.html":      {Data: []byte("<html></html>")},
+		"campaign/email.vars.yaml": {Data: []byte("FirstName: Bob\n")},
+	}
+
+	override, err := loadTemplateOverrideFS(fsys, "campaign/email.html")
+	if err != nil {
+		t.Fatalf("loadTemplateOverrideFS() error = %v", err)
+	}
+	if override["FirstName"] != "Bob" {
+		t.Errorf("expected override FirstName %q, got %q", "Bob", override["FirstName"])
+	}
+}
+
+func TestLoadTemplateOverrideFSMissing(t *testing.T) {
+	fsys := fstest.MapFS{
+		"campaign/email.html": {Data: []byte("<html></html>")},
+	}
+
+	override, err := loadTemplateOverrideFS(fsys, "campaign/email.html")
+	if err != nil {
+		t.Fatalf("loadTemplateOverrideFS() error = %v", err)
+	}
+	if override != nil {
+		t.Errorf("expected nil override when no sibling file exists, got %+v", override)
+	}
+}
+
+func TestVarsHandlerGetReturnsDefaults(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	handler := VarsHandler(tmpDir)
+
+	req := httptest.NewRequest("GET", "/api/vars", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var vars map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if vars["FirstName"] != "John" {
+		t.Errorf("expected FirstName %q, got %q", "John", vars["FirstName"])
+	}
+}
+
+func TestVarsHandlerPostPersistsSessionOverride(t *testing.T) {
+	tmpDir := createTestTemplateDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	handler := VarsHandler(tmpDir)
+
+	body, _ := json.Marshal(map[string]string{"FirstName": "Eve"})
+	postReq := httptest.NewRequest("POST", "/api/vars", bytes.NewReader(body))
+	postRR := httptest.NewRecorder()
+	handler.ServeHTTP(postRR, postReq)
+
+	if postRR.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", postRR.Code, postRR.Body.String())
+	}
+
+	var cookie *http.Cookie
+	for _, c := range postRR.Result().Cookies() {
+		if c.Name == templateVarsCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatalf("expected a %s cookie to be set", templateVarsCookieName)
+	}
+
+	var posted map[string]string
+	if err := json.Unmarshal(postRR.Body.Bytes(), &posted); err != nil {
+		t.Fatalf("failed to decode POST response: %v", err)
+	}
+	if posted["FirstName"] != "Eve" {
+		t.Errorf("expected POST response FirstName %q, got %q", "Eve", posted["FirstName"])
+	}
+
+	getReq := httptest.NewRequest("GET", "/api/vars", nil)
+	getReq.AddCookie(cookie)
+	getRR := httptest.NewRecorder()
+	handler.ServeHTTP(getRR, getReq)
+
+	var vars map[string]string
+	if err := json.Unmarshal(getRR.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("failed to decode GET response: %v", err)
+	}
+	if vars["FirstName"] != "Eve" {
+		t.Errorf("expected session-persisted FirstName %q, got %q", "Eve", vars["FirstName"])
+	}
+	if vars["LastName"] != "Doe" {
+		t.Errorf("expected untouched default LastName %q, got %q", "Doe", vars["LastName"])
+	}
+}
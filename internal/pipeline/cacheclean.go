@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCacheMaxAge is how long a fingerprinted cache entry sits in
+// assets/_gen before -clean-cache (or the periodic pruner StartCachePruner
+// runs) removes it, absent a more specific -cache-max-age.
+const DefaultCacheMaxAge = 30 * 24 * time.Hour
+
+// CleanCache removes every entry under baseDir's assets/_gen cache (see
+// (*Pipeline).cacheDir) whose mtime is older than maxAge, returning how
+// many files were removed. It's safe to call whether or not the cache
+// directory exists yet.
+func CleanCache(baseDir string, maxAge time.Duration) (int, error) {
+	cacheDir := filepath.Join(baseDir, "assets", "_gen")
+	entries, err := os.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(filepath.Join(cacheDir, entry.Name())); err == nil {
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+// StartCachePruner runs CleanCache(baseDir, maxAge) every interval until
+// the returned stop func is called, mirroring Index.Start's periodic
+// background-refresh goroutine (index.go) for the generated-asset cache
+// rather than the template index.
+func StartCachePruner(baseDir string, maxAge, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				CleanCache(baseDir, maxAge)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
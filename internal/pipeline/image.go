@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// geometryMode selects how resizeAsset fits a's image into the requested
+// WxH box.
+type geometryMode int
+
+const (
+	// geometryStretch scales width and height independently to exactly
+	// WxH, ignoring the source's aspect ratio - what Resize does.
+	geometryStretch geometryMode = iota
+	// geometryFit scales to fit entirely within WxH, preserving aspect
+	// ratio - the result may be narrower or shorter than requested, what
+	// Fit does.
+	geometryFit
+	// geometryFill scales to cover WxH, preserving aspect ratio, then
+	// crops the overflow equally from both sides (a centered crop) so the
+	// result is always exactly WxH -
+	// what Fill does.
+	geometryFill
+)
+
+// Resize scales a's image to exactly the "WxH" given in param, stretching
+// it if that changes the aspect ratio.
+func (p *Pipeline) Resize(param string, a *Asset) (*Asset, error) {
+	return resizeAsset(a, param, geometryStretch)
+}
+
+// Fit scales a's image to fit within the "WxH" box given in param without
+// cropping, preserving aspect ratio - the output may be smaller than WxH
+// in one dimension.
+func (p *Pipeline) Fit(param string, a *Asset) (*Asset, error) {
+	return resizeAsset(a, param, geometryFit)
+}
+
+// Fill scales a's image to cover the "WxH" box given in param, preserving
+// aspect ratio, and crops the overflow equally from both sides so the output
+// is always exactly WxH.
+func (p *Pipeline) Fill(param string, a *Asset) (*Asset, error) {
+	return resizeAsset(a, param, geometryFill)
+}
+
+// resizeAsset decodes a's content as an image, scales it per mode to the
+// dimensions in param, and re-encodes it in its original format. Scaling
+// uses nearest-neighbor sampling rather than a bilinear/Lanczos filter -
+// adequate for the logos and header images templates embed, not meant for
+// photographic quality, and needs no dependency beyond the standard
+// library's image, image/png, image/jpeg and image/gif packages.
+func resizeAsset(a *Asset, param string, mode geometryMode) (*Asset, error) {
+	width, height, err := parseDimensions(param)
+	if err != nil {
+		return nil, err
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(a.Content))
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", a.Name, err)
+	}
+
+	dstW, dstH := width, height
+	cropSrc := src.Bounds()
+	switch mode {
+	case geometryFit:
+		dstW, dstH = fitDimensions(cropSrc, width, height)
+	case geometryFill:
+		cropSrc = fillCropRect(cropSrc, width, height)
+	}
+
+	dst := nearestNeighborScale(src, cropSrc, dstW, dstH)
+
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, dst, format); err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", a.Name, err)
+	}
+
+	return &Asset{Name: a.Name, MIME: a.MIME, Content: buf.Bytes()}, nil
+}
+
+// parseDimensions parses a "WxH" geometry parameter (e.g. "200x200") into
+// its width and height, both of which must be positive integers.
+func parseDimensions(param string) (width, height int, err error) {
+	parts := strings.SplitN(param, "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid dimensions %q, want WxH", param)
+	}
+	width, err = strconv.Atoi(parts[0])
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid width in %q", param)
+	}
+	height, err = strconv.Atoi(parts[1])
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid height in %q", param)
+	}
+	return width, height, nil
+}
+
+// fitDimensions returns the largest width/height no bigger than maxW/maxH
+// that preserves srcBounds' aspect ratio.
+func fitDimensions(srcBounds image.Rectangle, maxW, maxH int) (int, int) {
+	srcW, srcH := srcBounds.Dx(), srcBounds.Dy()
+	if srcW == 0 || srcH == 0 {
+		return maxW, maxH
+	}
+	widthScale := float64(maxW) / float64(srcW)
+	heightScale := float64(maxH) / float64(srcH)
+	scale := widthScale
+	if heightScale < scale {
+		scale = heightScale
+	}
+	w := max(1, int(float64(srcW)*scale))
+	h := max(1, int(float64(srcH)*scale))
+	return w, h
+}
+
+// fillCropRect returns the centered sub-rectangle of srcBounds whose
+// aspect ratio matches dstW:dstH, so scaling that crop to dstW x dstH
+// covers the box with no letterboxing.
+func fillCropRect(srcBounds image.Rectangle, dstW, dstH int) image.Rectangle {
+	srcW, srcH := srcBounds.Dx(), srcBounds.Dy()
+	if srcW == 0 || srcH == 0 || dstW == 0 || dstH == 0 {
+		return srcBounds
+	}
+	srcAspect := float64(srcW) / float64(srcH)
+	dstAspect := float64(dstW) / float64(dstH)
+
+	cropW, cropH := srcW, srcH
+	if srcAspect > dstAspect {
+		cropW = int(float64(srcH) * dstAspect)
+	} else {
+		cropH = int(float64(srcW) / dstAspect)
+	}
+	x0 := srcBounds.Min.X + (srcW-cropW)/2
+	y0 := srcBounds.Min.Y + (srcH-cropH)/2
+	return image.Rect(x0, y0, x0+cropW, y0+cropH)
+}
+
+// nearestNeighborScale copies cropSrc from src into a new dstW x dstH
+// image, sampling the nearest source pixel for each destination pixel.
+func nearestNeighborScale(src image.Image, cropSrc image.Rectangle, dstW, dstH int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	srcW, srcH := cropSrc.Dx(), cropSrc.Dy()
+	if srcW == 0 || srcH == 0 || dstW == 0 || dstH == 0 {
+		return dst
+	}
+	for y := 0; y < dstH; y++ {
+		srcY := cropSrc.Min.Y + y*srcH/dstH
+		for x := 0; x < dstW; x++ {
+			srcX := cropSrc.Min.X + x*srcW/dstW
+			dst.Set(x, y, src.At(srcX, srcY))
+		}
+	}
+	return dst
+}
+
+// encodeImage re-encodes img in the named format ("png", "jpeg", "gif" -
+// what image.Decode's registered decoders report). Any other format falls
+// back to PNG, which round-trips a geometry transform losslessly.
+func encodeImage(w *bytes.Buffer, img image.Image, format string) error {
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return png.Encode(w, img)
+	}
+}
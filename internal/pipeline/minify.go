@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"bytes"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/js"
+)
+
+// minifiableMIMEs lists the MIME types minifier has a minifier registered
+// for; any other MIME type passes through minifyContent unchanged.
+var minifiableMIMEs = map[string]bool{
+	"text/css":               true,
+	"application/javascript": true,
+}
+
+var minifier = newMinifier()
+
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("application/javascript", js.Minify)
+	return m
+}
+
+// minifyContent minifies content for mimeType, passing it through
+// unchanged if there's no minifier registered for that MIME type.
+func minifyContent(mimeType string, content []byte) ([]byte, error) {
+	if !minifiableMIMEs[mimeType] {
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+	if err := minifier.Minify(mimeType, &buf, bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
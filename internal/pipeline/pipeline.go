@@ -0,0 +1,192 @@
+// Package pipeline implements a small Hugo-Piper-style asset pipeline:
+// templates chain resource/toCSS/minify/fingerprint to turn a source asset
+// (e.g. SCSS) into a cached, content-hashed, SRI-verifiable URL, e.g.:
+//
+//	{{ $css := resource "styles.scss" | toCSS | minify | fingerprint }}
+//	<link rel="stylesheet" href="{{ $css.URL }}" integrity="{{ $css.Integrity }}">
+package pipeline
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Asset is an in-flight artifact moving through the pipeline: its name
+// (a relative path; its extension reflects the asset's current format),
+// MIME type and raw content.
+type Asset struct {
+	Name    string
+	MIME    string
+	Content []byte
+}
+
+// Resource is the final, cacheable output of a pipeline chain - what
+// {{ $css.URL }} / {{ $css.Integrity }} / {{ $css.MIME }} resolve to in
+// templates.
+type Resource struct {
+	URL       string
+	Integrity string
+	MIME      string
+}
+
+// Pipeline resolves and caches assets for one template directory within
+// baseDir, mirroring the template-dir-then-global-assets fallback lookup
+// processAssetPaths already performs for plain src/href rewriting.
+type Pipeline struct {
+	baseDir     string
+	templateDir string
+}
+
+// New returns a Pipeline that resolves resources for templateDir (the
+// directory, relative to baseDir, that the template being rendered lives
+// in) against baseDir.
+func New(baseDir, templateDir string) *Pipeline {
+	return &Pipeline{baseDir: baseDir, templateDir: templateDir}
+}
+
+// cacheDir is where fingerprinted, transformed artifacts are written so
+// they can be served back out - and cached forever - through the same
+// baseDir/assets/ tree AssetHandler already serves.
+func (p *Pipeline) cacheDir() string {
+	return filepath.Join(p.baseDir, "assets", "_gen")
+}
+
+// Resource loads name from the template's own directory, falling back to
+// the global assets/ directory.
+func (p *Pipeline) Resource(name string) (*Asset, error) {
+	candidates := []string{
+		filepath.Join(p.baseDir, p.templateDir, name),
+		filepath.Join(p.baseDir, "assets", name),
+	}
+
+	for _, candidate := range candidates {
+		content, err := os.ReadFile(candidate)
+		if err == nil {
+			return &Asset{
+				Name:    name,
+				MIME:    mimeForExt(filepath.Ext(name)),
+				Content: content,
+			}, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("resource %q not found in %q or the global assets directory", name, p.templateDir)
+}
+
+// ToCSS compiles a.Content as SCSS/Sass to CSS. Assets that aren't
+// .scss/.sass pass through unchanged, so the same chain also works for a
+// plain .css resource.
+func (p *Pipeline) ToCSS(a *Asset) (*Asset, error) {
+	ext := strings.ToLower(filepath.Ext(a.Name))
+	if ext != ".scss" && ext != ".sass" {
+		return a, nil
+	}
+
+	css, err := compileSCSS(a.Content)
+	if err != nil {
+		return nil, fmt.Errorf("compiling %s: %w", a.Name, err)
+	}
+
+	return &Asset{
+		Name:    strings.TrimSuffix(a.Name, ext) + ".css",
+		MIME:    "text/css",
+		Content: css,
+	}, nil
+}
+
+// Minify runs a through tdewolff/minify for its MIME type. Asset types
+// without a registered minifier pass through unchanged.
+func (p *Pipeline) Minify(a *Asset) (*Asset, error) {
+	minified, err := minifyContent(a.MIME, a.Content)
+	if err != nil {
+		return nil, fmt.Errorf("minifying %s: %w", a.Name, err)
+	}
+	return &Asset{Name: a.Name, MIME: a.MIME, Content: minified}, nil
+}
+
+// Fingerprint writes a into the assets/_gen/ cache under a content-hashed
+// filename (e.g. styles.abc123def456.css) so it can be served with a
+// cache-forever header, and returns the Resource a template uses to link
+// to it: a URL plus a SHA-384 SRI Integrity digest. The filename is
+// content-addressed, so it doubles as cache invalidation - a changed input
+// always produces a new filename, and a repeat of the same input is a
+// cache hit that skips the write.
+func (p *Pipeline) Fingerprint(a *Asset) (*Resource, error) {
+	sum := sha512.Sum384(a.Content)
+	integrity := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	hash := base64.RawURLEncoding.EncodeToString(sum[:])[:12]
+	ext := filepath.Ext(a.Name)
+	fingerprinted := strings.TrimSuffix(a.Name, ext) + "." + hash + ext
+
+	if err := os.MkdirAll(p.cacheDir(), 0755); err != nil {
+		return nil, err
+	}
+
+	outPath := filepath.Join(p.cacheDir(), fingerprinted)
+	if _, err := os.Stat(outPath); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err := os.WriteFile(outPath, a.Content, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Resource{
+		URL:       "/templates/assets/_gen/" + fingerprinted,
+		Integrity: integrity,
+		MIME:      a.MIME,
+	}, nil
+}
+
+// ApplyOp runs the named operation ("resize", "fit", "fill", "minify")
+// against a with param, the form a query suffix like ?resize=200x200
+// translates into for AssetHandler. param is ignored for "minify", which
+// takes none.
+func (p *Pipeline) ApplyOp(a *Asset, op, param string) (*Asset, error) {
+	switch op {
+	case "resize":
+		return p.Resize(param, a)
+	case "fit":
+		return p.Fit(param, a)
+	case "fill":
+		return p.Fill(param, a)
+	case "minify":
+		return p.Minify(a)
+	default:
+		return nil, fmt.Errorf("unknown resource operation %q", op)
+	}
+}
+
+// NewAsset builds an Asset around content already read from disk, sniffing
+// its MIME type from name's extension the same way Resource does for an
+// asset it loads itself.
+func NewAsset(name string, content []byte) *Asset {
+	return &Asset{
+		Name:    name,
+		MIME:    mimeForExt(filepath.Ext(name)),
+		Content: content,
+	}
+}
+
+// mimeForExt maps a handful of common asset extensions to their MIME type.
+func mimeForExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".scss", ".sass", ".css":
+		return "text/css"
+	case ".js":
+		return "application/javascript"
+	case ".json":
+		return "application/json"
+	default:
+		return "application/octet-stream"
+	}
+}
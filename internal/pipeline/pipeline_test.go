@@ -0,0 +1,314 @@
+package pipeline
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPNG returns a w x h PNG-encoded solid-color square, used as a source
+// image for the resize/fit/fill tests below.
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 50, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decodePNGBounds(t *testing.T, data []byte) image.Rectangle {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	return img.Bounds()
+}
+
+func newTestBaseDir(t *testing.T) string {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "pipeline-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+	return tmpDir
+}
+
+func TestResourceTemplateDirTakesPrecedence(t *testing.T) {
+	baseDir := newTestBaseDir(t)
+	templateDir := "campaign-a"
+	if err := os.MkdirAll(filepath.Join(baseDir, templateDir), 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(baseDir, "assets"), 0755); err != nil {
+		t.Fatalf("Failed to create assets dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, templateDir, "styles.css"), []byte("local"), 0644); err != nil {
+		t.Fatalf("Failed to write local asset: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "assets", "styles.css"), []byte("global"), 0644); err != nil {
+		t.Fatalf("Failed to write global asset: %v", err)
+	}
+
+	p := New(baseDir, templateDir)
+	asset, err := p.Resource("styles.css")
+	if err != nil {
+		t.Fatalf("Resource() error = %v", err)
+	}
+	if string(asset.Content) != "local" {
+		t.Errorf("expected template-dir asset to take precedence, got %q", asset.Content)
+	}
+}
+
+func TestResourceFallsBackToGlobalAssets(t *testing.T) {
+	baseDir := newTestBaseDir(t)
+	templateDir := "campaign-a"
+	if err := os.MkdirAll(filepath.Join(baseDir, templateDir), 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(baseDir, "assets"), 0755); err != nil {
+		t.Fatalf("Failed to create assets dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "assets", "styles.css"), []byte("global"), 0644); err != nil {
+		t.Fatalf("Failed to write global asset: %v", err)
+	}
+
+	p := New(baseDir, templateDir)
+	asset, err := p.Resource("styles.css")
+	if err != nil {
+		t.Fatalf("Resource() error = %v", err)
+	}
+	if string(asset.Content) != "global" {
+		t.Errorf("expected fallback to global assets, got %q", asset.Content)
+	}
+}
+
+func TestResourceNotFound(t *testing.T) {
+	baseDir := newTestBaseDir(t)
+	p := New(baseDir, "campaign-a")
+
+	if _, err := p.Resource("missing.css"); err == nil {
+		t.Error("expected an error for a missing resource")
+	}
+}
+
+func TestToCSSPassesThroughPlainCSS(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	asset := &Asset{Name: "styles.css", MIME: "text/css", Content: []byte("body{}")}
+
+	out, err := p.ToCSS(asset)
+	if err != nil {
+		t.Fatalf("ToCSS() error = %v", err)
+	}
+	if string(out.Content) != "body{}" {
+		t.Errorf("expected plain .css to pass through unchanged, got %q", out.Content)
+	}
+}
+
+func TestToCSSRequiresLibsassBuildTagForSCSS(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	asset := &Asset{Name: "styles.scss", MIME: "text/x-scss", Content: []byte("body { a { color: red; } }")}
+
+	if _, err := p.ToCSS(asset); err == nil || !strings.Contains(err.Error(), "libsass") {
+		t.Errorf("expected a libsass build-tag error in the default build, got %v", err)
+	}
+}
+
+func TestMinifyPassesThroughUnknownMIME(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	asset := &Asset{Name: "image.png", MIME: "application/octet-stream", Content: []byte{0x01, 0x02}}
+
+	out, err := p.Minify(asset)
+	if err != nil {
+		t.Fatalf("Minify() error = %v", err)
+	}
+	if string(out.Content) != string(asset.Content) {
+		t.Errorf("expected unknown MIME to pass through unchanged")
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	asset := &Asset{Name: "styles.css", MIME: "text/css", Content: []byte("body {\n  color:  red;\n}\n")}
+
+	out, err := p.Minify(asset)
+	if err != nil {
+		t.Fatalf("Minify() error = %v", err)
+	}
+	if len(out.Content) >= len(asset.Content) {
+		t.Errorf("expected minified CSS to be smaller, got %q from %q", out.Content, asset.Content)
+	}
+}
+
+func TestFingerprintWritesCacheAndIsIdempotent(t *testing.T) {
+	baseDir := newTestBaseDir(t)
+	p := New(baseDir, "campaign-a")
+	asset := &Asset{Name: "styles.css", MIME: "text/css", Content: []byte("body{color:red}")}
+
+	res, err := p.Fingerprint(asset)
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	if !strings.HasPrefix(res.Integrity, "sha384-") {
+		t.Errorf("expected a sha384- SRI integrity digest, got %q", res.Integrity)
+	}
+	if !strings.Contains(res.URL, "styles.") || !strings.HasSuffix(res.URL, ".css") {
+		t.Errorf("expected a fingerprinted .css URL, got %q", res.URL)
+	}
+
+	cachedPath := filepath.Join(baseDir, "assets", "_gen", filepath.Base(res.URL))
+	if _, err := os.Stat(cachedPath); err != nil {
+		t.Fatalf("expected fingerprinted file to exist at %q: %v", cachedPath, err)
+	}
+
+	// A second call with identical content must produce the same URL
+	// (content-addressed) without erroring on the pre-existing file.
+	res2, err := p.Fingerprint(asset)
+	if err != nil {
+		t.Fatalf("Fingerprint() second call error = %v", err)
+	}
+	if res2.URL != res.URL {
+		t.Errorf("expected idempotent fingerprinting, got %q then %q", res.URL, res2.URL)
+	}
+}
+
+func TestResizeStretchesToExactDimensions(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	asset := &Asset{Name: "logo.png", MIME: "image/png", Content: testPNG(t, 100, 50)}
+
+	out, err := p.Resize("40x40", asset)
+	if err != nil {
+		t.Fatalf("Resize() error = %v", err)
+	}
+	bounds := decodePNGBounds(t, out.Content)
+	if bounds.Dx() != 40 || bounds.Dy() != 40 {
+		t.Errorf("expected a 40x40 image, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestFitPreservesAspectRatio(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	asset := &Asset{Name: "logo.png", MIME: "image/png", Content: testPNG(t, 100, 50)}
+
+	out, err := p.Fit("40x40", asset)
+	if err != nil {
+		t.Fatalf("Fit() error = %v", err)
+	}
+	bounds := decodePNGBounds(t, out.Content)
+	if bounds.Dx() != 40 || bounds.Dy() != 20 {
+		t.Errorf("expected a 40x20 image preserving the 2:1 aspect ratio, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestFillCoversExactDimensions(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	asset := &Asset{Name: "logo.png", MIME: "image/png", Content: testPNG(t, 100, 50)}
+
+	out, err := p.Fill("40x40", asset)
+	if err != nil {
+		t.Fatalf("Fill() error = %v", err)
+	}
+	bounds := decodePNGBounds(t, out.Content)
+	if bounds.Dx() != 40 || bounds.Dy() != 40 {
+		t.Errorf("expected a 40x40 image, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestResizeRejectsInvalidDimensions(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	asset := &Asset{Name: "logo.png", MIME: "image/png", Content: testPNG(t, 10, 10)}
+
+	if _, err := p.Resize("not-a-size", asset); err == nil {
+		t.Error("expected an error for invalid dimensions")
+	}
+}
+
+func TestApplyOpDispatchesToEachOperation(t *testing.T) {
+	p := New(newTestBaseDir(t), "campaign-a")
+	image := &Asset{Name: "logo.png", MIME: "image/png", Content: testPNG(t, 20, 20)}
+
+	if _, err := p.ApplyOp(image, "resize", "10x10"); err != nil {
+		t.Errorf("ApplyOp(resize) error = %v", err)
+	}
+	if _, err := p.ApplyOp(image, "fit", "10x10"); err != nil {
+		t.Errorf("ApplyOp(fit) error = %v", err)
+	}
+	if _, err := p.ApplyOp(image, "fill", "10x10"); err != nil {
+		t.Errorf("ApplyOp(fill) error = %v", err)
+	}
+
+	css := &Asset{Name: "styles.css", MIME: "text/css", Content: []byte("body {\n  color: red;\n}\n")}
+	if _, err := p.ApplyOp(css, "minify", ""); err != nil {
+		t.Errorf("ApplyOp(minify) error = %v", err)
+	}
+
+	if _, err := p.ApplyOp(image, "bogus", ""); err == nil {
+		t.Error("expected an error for an unknown op")
+	}
+}
+
+func TestNewAssetSniffsMIMEFromExtension(t *testing.T) {
+	asset := NewAsset("styles.css", []byte("body{}"))
+	if asset.MIME != "text/css" {
+		t.Errorf("MIME = %q, want text/css", asset.MIME)
+	}
+}
+
+func TestCleanCacheRemovesOnlyStaleEntries(t *testing.T) {
+	baseDir := newTestBaseDir(t)
+	genDir := filepath.Join(baseDir, "assets", "_gen")
+	if err := os.MkdirAll(genDir, 0755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+
+	stale := filepath.Join(genDir, "stale.css")
+	fresh := filepath.Join(genDir, "fresh.css")
+	if err := os.WriteFile(stale, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to write stale entry: %v", err)
+	}
+	if err := os.WriteFile(fresh, []byte("b"), 0644); err != nil {
+		t.Fatalf("Failed to write fresh entry: %v", err)
+	}
+	oldTime := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(stale, oldTime, oldTime); err != nil {
+		t.Fatalf("Failed to backdate stale entry: %v", err)
+	}
+
+	removed, err := CleanCache(baseDir, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CleanCache() error = %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("removed = %d, want 1", removed)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("expected the stale entry to be removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("expected the fresh entry to survive")
+	}
+}
+
+func TestCleanCacheNoCacheDirIsNotAnError(t *testing.T) {
+	removed, err := CleanCache(newTestBaseDir(t), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CleanCache() error = %v", err)
+	}
+	if removed != 0 {
+		t.Errorf("removed = %d, want 0", removed)
+	}
+}
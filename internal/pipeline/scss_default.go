@@ -0,0 +1,13 @@
+//go:build !libsass
+
+package pipeline
+
+import "fmt"
+
+// compileSCSS is the CGO-free default: real SCSS compilation needs a
+// libsass/dartsass binding, which pulls in CGO, so it's opt-in via the
+// libsass build tag (see scss_libsass.go) to keep the default build
+// CGO-free.
+func compileSCSS(src []byte) ([]byte, error) {
+	return nil, fmt.Errorf("SCSS compilation is not available in this build; rebuild with -tags libsass")
+}
@@ -0,0 +1,26 @@
+//go:build libsass
+
+package pipeline
+
+import (
+	"bytes"
+
+	libsass "github.com/wellington/go-libsass"
+)
+
+// compileSCSS compiles SCSS/Sass to CSS via libsass. Only built when the
+// libsass build tag is set, since the CGO binding isn't available (or
+// wanted) in the default, CGO-free build.
+func compileSCSS(src []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	comp, err := libsass.New(&out, bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	if err := comp.Run(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"archive/zip"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -13,12 +11,20 @@ import (
 	"time"
 
 	"github.com/phishingclub/templates/internal/handler"
+	"github.com/phishingclub/templates/internal/pipeline"
 )
 
 func main() {
 	port := flag.Int("port", 8080, "Port to run the server on")
 	templatesDir := flag.String("templates", "./phishing-templates", "Directory containing templates")
-	export := flag.Bool("export", false, "Export templates and assets to zip file and exit")
+	export := flag.Bool("export", false, "Export templates and assets to an archive and exit")
+	exportFormat := flag.String("format", "zip", "Export archive format: zip, tar, tar.gz, or tar.bz2")
+	exportInclude := flag.String("include", "", "Comma-separated glob patterns to include in an export (e.g. \"banking/*,microsoft/*\")")
+	exportExclude := flag.String("exclude", "", "Comma-separated glob patterns to exclude from an export (e.g. \"*/draft-*\")")
+	exportSignKey := flag.String("sign-key", "", "Path to a base64-encoded Ed25519 seed to sign the export manifest with")
+	cleanCache := flag.Bool("clean-cache", false, "Remove generated asset cache entries older than -cache-max-age and exit")
+	cacheMaxAge := flag.Duration("cache-max-age", pipeline.DefaultCacheMaxAge, "Max age for generated asset cache entries (resize/fit/fill/minify output) before they're pruned")
+	dev := flag.Bool("dev", false, "Enable the hot-reload dev server: preview pages refresh automatically when a template or asset file changes")
 	flag.Parse()
 
 	// Ensure templates directory exists
@@ -35,9 +41,23 @@ func main() {
 		log.Fatalf("Failed to get absolute path: %v", err)
 	}
 
+	// Merge any templates.yaml-declared remote sources into absPath before
+	// anything else reads it, so export, validation and the server all see
+	// the same synced tree. A directory with no templates.yaml has nothing
+	// to sync.
+	manifest, err := handler.LoadSyncManifest(absPath)
+	if err != nil {
+		log.Fatalf("Failed to read templates.yaml: %v", err)
+	}
+	if len(manifest.Sources) > 0 {
+		if err := handler.SyncTemplates(absPath, manifest, nil); err != nil {
+			log.Fatalf("Failed to sync templates: %v", err)
+		}
+	}
+
 	// Handle export mode
 	if *export {
-		err := performExport(absPath)
+		err := performExport(absPath, *exportFormat, *exportInclude, *exportExclude, *exportSignKey)
 		if err != nil {
 			log.Fatalf("Export failed: %v", err)
 		}
@@ -45,6 +65,16 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Handle clean-cache mode
+	if *cleanCache {
+		removed, err := pipeline.CleanCache(absPath, *cacheMaxAge)
+		if err != nil {
+			log.Fatalf("Cache cleanup failed: %v", err)
+		}
+		log.Printf("Removed %d stale cache entries", removed)
+		os.Exit(0)
+	}
+
 	// Setup router
 	mux := http.NewServeMux()
 
@@ -57,8 +87,22 @@ func main() {
 		http.StripPrefix("/templates/", handler.AssetHandler(absPath)),
 	)
 
-	// Handle template preview
-	mux.HandleFunc("/preview/", handler.PreviewHandler(absPath))
+	// Handle template preview. In -dev mode, previews live-reload: a
+	// ReloadHub watches absPath and pushes a refresh over /ws/reload the
+	// moment a file in the previewed directory changes.
+	if *dev {
+		reloadHub := handler.NewReloadHub()
+		if _, err := reloadHub.Watch(absPath); err != nil {
+			log.Fatalf("Failed to start live-reload watcher: %v", err)
+		}
+		mux.HandleFunc("/preview/", handler.PreviewHandlerDev(absPath, reloadHub))
+		mux.HandleFunc("/ws/reload", reloadHub.ReloadHandler())
+	} else {
+		mux.HandleFunc("/preview/", handler.PreviewHandler(absPath))
+	}
+
+	// Download a single scenario folder as an archive
+	mux.HandleFunc("/archive/", handler.ArchiveHandler(absPath))
 
 	// Handle directory listings
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -80,10 +124,46 @@ func main() {
 		handler.IndexHandler(absPath)(w, r)
 	})
 
+	// Open the templates store once; it serves StructureHandler, DownloadHandler
+	// and ExportHandler through the fs.FS-based Store abstraction so they work
+	// the same whether absPath is a directory or a .zip archive.
+	store, err := handler.OpenStore(absPath)
+	if err != nil {
+		log.Fatalf("Failed to open templates store: %v", err)
+	}
+
+	// Build the campaign index once up front and keep it fresh in the
+	// background so validateCampaigns-heavy requests scan a cache instead of
+	// re-walking the whole tree. Watching absPath only makes sense for the
+	// directory-backed store case; a zip store has no live directory to watch.
+	idx := handler.NewIndex(store, absPath)
+	if err := idx.Start(handler.DefaultIndexRefreshInterval); err != nil {
+		log.Fatalf("Failed to start template index: %v", err)
+	}
+
+	// Keep the generated asset cache (resize/fit/fill/minify output) from
+	// growing unbounded by pruning entries older than -cache-max-age once
+	// a day, the same background-goroutine pattern idx.Start uses for the
+	// template index.
+	pipeline.StartCachePruner(absPath, *cacheMaxAge, 24*time.Hour)
+
 	// API endpoints
-	mux.HandleFunc("/api/structure", handler.StructureHandler(absPath))
-	mux.HandleFunc("/api/download", handler.DownloadHandler(absPath))
-	mux.HandleFunc("/api/export", handler.ExportHandler(absPath))
+	mux.HandleFunc("/api/structure", handler.StructureHandler(store))
+	mux.HandleFunc("/api/download", handler.DownloadHandler(store, idx))
+	mux.HandleFunc("/api/export", handler.ExportHandler(store, idx))
+	mux.HandleFunc("/api/reindex", handler.ReindexHandler(idx))
+	mux.HandleFunc("/api/import", handler.ImportHandler(absPath, store, idx))
+	mux.HandleFunc("/api/vars", handler.VarsHandler(absPath))
+
+	// Email template testing: send a test email through EMAIL_BACKEND, then
+	// let the UI look it up in Mailpit to preview what actually landed.
+	mux.HandleFunc("/api/email/send", handler.SendTestEmailHandler(absPath, fmt.Sprintf(":%d", *port)))
+	mux.HandleFunc("/api/email/check", handler.CheckEmailTemplateHandler(absPath))
+	mux.HandleFunc("/api/email/inbox", handler.InboxHandler())
+	mux.HandleFunc("/api/email/message", handler.MessageHandler())
+
+	// Lint a template's data.yaml and markup before it's sent for real.
+	mux.HandleFunc("/api/template/lint", handler.LintTemplateHandler(absPath))
 
 	// Raw template view handler
 	mux.HandleFunc("/raw/", handler.RawViewHandler(absPath))
@@ -102,212 +182,73 @@ func main() {
 	log.Fatal(server.ListenAndServe())
 }
 
-// performExport handles command line export functionality
-func performExport(templatesDir string) error {
-	timestamp := time.Now().Format("20060102-150405")
-	zipFilename := fmt.Sprintf("export-%s.zip", timestamp)
-
-	// Create output file
-	outputFile, err := os.Create(zipFilename)
+// performExport handles command line export functionality: it builds a
+// handler.Exporter over templatesDir the same way ExportHandler builds one
+// per HTTP request, so -export produces exactly the same archive shape
+// (manifest.json plus assets/ and templates/ entries) the API does, just
+// written to a local file instead of a response body. formatFlag is an
+// ArchiveFormat value (zip/tar/tar.gz/tar.bz2); includeFlag/excludeFlag are
+// comma-separated glob (or "re:"-prefixed regex) patterns; signKeyPath, if
+// non-empty, signs the manifest and writes a detached signature alongside
+// the archive.
+func performExport(templatesDir, formatFlag, includeFlag, excludeFlag, signKeyPath string) error {
+	store, err := handler.OpenStore(templatesDir)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+		return fmt.Errorf("failed to open templates store: %w", err)
 	}
-	defer outputFile.Close()
-
-	// Create zip writer
-	zipWriter := zip.NewWriter(outputFile)
-	defer zipWriter.Close()
 
-	// Process branding assets (check both "assets" and "Branding" directories)
-	assetsPath := filepath.Join(templatesDir, "assets")
-	if _, err := os.Stat(assetsPath); err == nil {
-		err = addBrandingAssets(zipWriter, assetsPath)
-		if err != nil {
-			return fmt.Errorf("error processing assets: %v", err)
-		}
+	exporter, err := handler.NewExporter(store, handler.ArchiveFormat(formatFlag), splitCSV(includeFlag), splitCSV(excludeFlag))
+	if err != nil {
+		return err
 	}
-
-	// Also check for legacy "Branding" directory
-	brandingPath := filepath.Join(templatesDir, "Branding")
-	if _, err := os.Stat(brandingPath); err == nil {
-		err = addBrandingAssets(zipWriter, brandingPath)
-		if err != nil {
-			return fmt.Errorf("error processing branding assets: %v", err)
+	if signKeyPath != "" {
+		if err := exporter.LoadSigningKey(signKeyPath); err != nil {
+			return err
 		}
 	}
 
-	// Process phishing templates
-	err = addPhishingTemplates(zipWriter, templatesDir)
+	timestamp := time.Now().Format("20060102-150405")
+	archiveFilename := fmt.Sprintf("export-%s%s", timestamp, exporter.Format.Extension())
+	outputFile, err := os.Create(archiveFilename)
 	if err != nil {
-		return fmt.Errorf("error processing templates: %v", err)
+		return fmt.Errorf("failed to create output file: %v", err)
 	}
+	defer outputFile.Close()
 
-	log.Printf("Export saved as: %s", zipFilename)
-	return nil
-}
-
-// addBrandingAssets adds all folders from Branding to assets/ in the zip
-func addBrandingAssets(zipWriter *zip.Writer, brandingPath string) error {
-	return filepath.Walk(brandingPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Get relative path from branding directory
-		relPath, err := filepath.Rel(brandingPath, path)
-		if err != nil {
-			return err
-		}
-
-		// Skip the root branding directory
-		if relPath == "." {
-			return nil
-		}
-
-		// Create the path in assets folder
-		zipPath := filepath.Join("assets", relPath)
-		zipPath = filepath.ToSlash(zipPath) // Ensure forward slashes
-
-		// Create zip header
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
-
-		header.Name = zipPath
-		if info.IsDir() {
-			header.Name += "/"
-			header.Method = zip.Store
-		} else {
-			header.Method = zip.Deflate
-		}
-
-		// Create writer for the file
-		writer, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-
-		// If it's a directory, we're done
-		if info.IsDir() {
-			return nil
-		}
-
-		// Open and copy file contents
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-
-		_, err = io.Copy(writer, file)
-		return err
-	})
-}
-
-// addPhishingTemplates recursively finds template folders (containing *.html files) and adds them to templates/
-func addPhishingTemplates(zipWriter *zip.Writer, baseDir string) error {
-	return filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip if not a directory
-		if !info.IsDir() {
-			return nil
-		}
-
-		// Skip the assets directories as they're handled separately
-		if strings.Contains(path, "assets") {
-			return filepath.SkipDir
-		}
-
-		// Check if this directory contains any HTML files
-		hasHTML, err := containsHTMLFiles(path)
+	var sigFile *os.File
+	if exporter.SigningKey != nil {
+		sigFile, err = os.Create("manifest.json.sig")
 		if err != nil {
-			return err
-		}
-
-		// If this directory contains HTML files, it's a template directory
-		if hasHTML {
-			templateName := filepath.Base(path)
-			return addTemplateToZip(zipWriter, path, templateName)
+			return fmt.Errorf("failed to create signature file: %v", err)
 		}
+		defer sigFile.Close()
+	}
 
-		return nil
-	})
-}
-
-// containsHTMLFiles checks if a directory contains any *.html files
-func containsHTMLFiles(dirPath string) (bool, error) {
-	entries, err := os.ReadDir(dirPath)
+	manifest, err := exporter.Export(outputFile, sigFile)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".html") {
-			return true, nil
-		}
+	log.Printf("Export saved as: %s (%d files, manifest sha256 %s)", archiveFilename, len(manifest.Entries), manifest.SHA256)
+	if sigFile != nil {
+		log.Printf("Manifest signature saved as: manifest.json.sig")
 	}
-	return false, nil
+	return nil
 }
 
-// addTemplateToZip adds an entire template directory to the templates/ folder in the zip
-func addTemplateToZip(zipWriter *zip.Writer, templatePath, templateName string) error {
-	return filepath.Walk(templatePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Get relative path from template directory
-		relPath, err := filepath.Rel(templatePath, path)
-		if err != nil {
-			return err
-		}
-
-		// Skip the root template directory
-		if relPath == "." {
-			return nil
-		}
-
-		// Create the path in templates folder
-		zipPath := filepath.Join("templates", templateName, relPath)
-		zipPath = filepath.ToSlash(zipPath) // Ensure forward slashes
-
-		// Create zip header
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
-
-		header.Name = zipPath
-		if info.IsDir() {
-			header.Name += "/"
-			header.Method = zip.Store
-		} else {
-			header.Method = zip.Deflate
-		}
-
-		// Create writer for the file
-		writer, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-
-		// If it's a directory, we're done
-		if info.IsDir() {
-			return nil
-		}
-
-		// Open and copy file contents
-		file, err := os.Open(path)
-		if err != nil {
-			return err
+// splitCSV splits a comma-separated flag value into its trimmed, non-empty
+// parts, returning nil (rather than a single empty-string element) for an
+// empty value.
+func splitCSV(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var parts []string
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			parts = append(parts, p)
 		}
-		defer file.Close()
-
-		_, err = io.Copy(writer, file)
-		return err
-	})
+	}
+	return parts
 }